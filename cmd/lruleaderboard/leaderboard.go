@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+/******************************************************************************
+ *                             Leaderboard
+ ******************************************************************************/
+
+// Entry is one submission's latest result for the leaderboard benchmark
+type Entry struct {
+	Student  string
+	NsPerOp  float64
+	HitRatio float64 // percent, or -1 if the benchmark did not report one
+}
+
+// Throughput returns the operations per second implied by NsPerOp
+func (e *Entry) Throughput() float64 {
+	if e.NsPerOp <= 0 {
+		return 0
+	}
+	return 1e9 / e.NsPerOp
+}
+
+// Anonymize returns a stable pseudonym for a student. The pseudonym is keyed
+// by salt so that it cannot be reversed by hashing a list of known IDs, while
+// still letting students find themselves if we share their pseudonym privately
+func Anonymize(student, salt string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(student))
+	return "anon-" + hex.EncodeToString(mac.Sum(nil))[:8]
+}
+
+// Rank sorts entries by throughput, breaking ties by hit ratio
+func Rank(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		ti, tj := entries[i].Throughput(), entries[j].Throughput()
+		if ti != tj {
+			return ti > tj
+		}
+		return entries[i].HitRatio > entries[j].HitRatio
+	})
+}
+
+// Render writes the top entries of an already-ranked leaderboard to w, as
+// either a markdown table for publishing or aligned plain text
+func Render(w io.Writer, entries []Entry, salt string, top int, markdown bool) error {
+	if top > 0 && top < len(entries) {
+		entries = entries[:top]
+	}
+
+	row := "%d\t%s\t%.0f\t%.1f\t%s\n"
+	header := "Rank\tSubmission\tops/sec\tns/op\thit%\n"
+	if markdown {
+		row = "| %d | %s | %.0f | %.1f | %s |\n"
+		header = "| Rank | Submission | ops/sec | ns/op | hit% |\n|---:|---|---:|---:|---:|\n"
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	out := io.Writer(tw)
+	if markdown {
+		out = w
+	}
+
+	fmt.Fprint(out, header)
+	for i, e := range entries {
+		hit := "n/a"
+		if e.HitRatio >= 0 {
+			hit = fmt.Sprintf("%.2f", e.HitRatio)
+		}
+		fmt.Fprintf(out, row, i+1, Anonymize(e.Student, salt), e.Throughput(), e.NsPerOp, hit)
+	}
+
+	if markdown {
+		return nil
+	}
+	return tw.Flush()
+}
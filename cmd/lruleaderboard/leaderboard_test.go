@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRank(t *testing.T) {
+	entries := []Entry{
+		{"slow", 200, 90},
+		{"fast", 100, 80},
+		{"fast-accurate", 100, 95},
+		{"no-metric", 150, -1},
+	}
+	Rank(entries)
+
+	expected := []string{"fast-accurate", "fast", "no-metric", "slow"}
+	for i, student := range expected {
+		if entries[i].Student != student {
+			t.Errorf("Rank %d: expected %s, found %s", i+1, student, entries[i].Student)
+		}
+	}
+}
+
+func TestAnonymize(t *testing.T) {
+	a := Anonymize("netid", "salt")
+	if a != Anonymize("netid", "salt") {
+		t.Errorf("Pseudonyms must be stable across calls")
+	}
+	if a == Anonymize("netid", "pepper") || a == Anonymize("other", "salt") {
+		t.Errorf("Pseudonyms must depend on both the student and the salt")
+	}
+	if strings.Contains(a, "netid") {
+		t.Errorf("Pseudonym %s leaks the student ID", a)
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	entries := []Entry{{"a", 100, 95}, {"b", 200, -1}, {"c", 400, 50}}
+	var buf bytes.Buffer
+	if err := Render(&buf, entries, "salt", 2, true); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected header, separator and 2 rows, found:\n%s", buf.String())
+	}
+	expected := "| 2 | " + Anonymize("b", "salt") + " | 5000000 | 200.0 | n/a |"
+	if lines[3] != expected {
+		t.Errorf("Expected row\n%s\nfound\n%s", expected, lines[3])
+	}
+}
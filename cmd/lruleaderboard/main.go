@@ -0,0 +1,92 @@
+// Command lruleaderboard renders an anonymized performance leaderboard from the
+// benchmark results that lrureport appended to a SQLite database:
+//
+//	lruleaderboard -db results.db -salt "$SALT" -markdown > leaderboard.md
+//
+// Each submission is represented by its most recent passing run of the chosen
+// benchmark, ranked by throughput and then by hit ratio.
+//
+// Like lrureport's -sqlite backend, it needs cgo and the sqlite3 driver, which
+// is only linked in when building with the "sqlite" tag:
+//
+//	go build -tags sqlite ./cmd/lruleaderboard
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const latestResults = `
+SELECT r.student, r.ns_per_op, COALESCE(m.value, -1)
+FROM results r
+LEFT JOIN metrics m
+	ON m.student = r.student AND m.run = r.run AND m.package = r.package
+	AND m.test = r.test AND m.unit = 'hit%'
+WHERE r.test = ? AND r.result = 'pass' AND r.ns_per_op IS NOT NULL
+	AND r.run = (
+		SELECT MAX(run) FROM results
+		WHERE student = r.student AND test = r.test AND result = 'pass'
+	)`
+
+func main() {
+	dbPath := flag.String("db", "results.db", "SQLite database written by lrureport")
+	bench := flag.String("bench", "BenchmarkZipf", "benchmark to rank submissions by")
+	salt := flag.String("salt", "", "secret used to derive anonymous submission names")
+	top := flag.Int("top", 0, "only show the top N submissions (0 shows all)")
+	markdown := flag.Bool("markdown", false, "render a markdown table instead of plain text")
+	flag.Parse()
+
+	if *salt == "" {
+		fatalf("-salt is required, or pseudonyms could be reversed")
+	}
+
+	if !sqliteDriverLinked() {
+		fatalf("built without the sqlite3 driver; rebuild with -tags sqlite")
+	}
+	db, err := sql.Open("sqlite3", *dbPath)
+	if err != nil {
+		fatalf("opening %s: %v", *dbPath, err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(latestResults, *bench)
+	if err != nil {
+		fatalf("querying %s: %v", *dbPath, err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		if err := rows.Scan(&e.Student, &e.NsPerOp, &e.HitRatio); err != nil {
+			fatalf("reading results: %v", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		fatalf("reading results: %v", err)
+	}
+
+	Rank(entries)
+	if err := Render(os.Stdout, entries, *salt, *top, *markdown); err != nil {
+		fatalf("%v", err)
+	}
+}
+
+// sqliteDriverLinked reports whether the sqlite3 driver was built in
+func sqliteDriverLinked() bool {
+	for _, name := range sql.Drivers() {
+		if name == "sqlite3" {
+			return true
+		}
+	}
+	return false
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "lruleaderboard: "+format+"\n", args...)
+	os.Exit(2)
+}
@@ -0,0 +1,51 @@
+// Command lrumemcached serves an LRU over TCP with the memcached text
+// protocol, so that it can be benchmarked with standard memcached tools:
+//
+//	lrumemcached -addr :11211 -limit 67108864 -shards 16 &
+//	memtier_benchmark -p 11211 -P memcache_text --ratio 1:9
+//
+// With -shards 0, the server serializes requests to a single LRU. Otherwise
+// the LRU is made safe for concurrent use with NewSyncLru (one shard) or
+// NewStripedLru (more), so that the benchmark measures that implementation.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/cos316gradertest/assignment3-test/lru"
+	"github.com/cos316gradertest/assignment3-test/memcached"
+)
+
+func main() {
+	addr := flag.String("addr", ":11211", "TCP address to listen on")
+	limit := flag.Int("limit", 64<<20, "storage limit of the cache, in bytes")
+	shards := flag.Int("shards", 0, "shards to stripe the cache across, or 0 to serialize requests in the server")
+	flag.Parse()
+
+	if *limit < 0 || *shards < 0 {
+		fatalf("-limit and -shards must not be negative")
+	}
+
+	server := &memcached.Server{Concurrent: *shards > 0}
+	switch *shards {
+	case 0:
+		server.Cache = lru.NewLru(*limit)
+	case 1:
+		server.Cache = lru.NewSyncLru(lru.NewLru(*limit))
+	default:
+		server.Cache = lru.NewStripedLru(*limit, *shards, func(limit int) lru.ByteCache {
+			return lru.NewLru(limit)
+		})
+	}
+
+	if err := server.ListenAndServe(*addr); err != nil {
+		fatalf("%v", err)
+	}
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "lrumemcached: "+format+"\n", args...)
+	os.Exit(2)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/******************************************************************************
+ *                             Events
+ ******************************************************************************/
+
+// Event is a single line of `go test -json` output, as produced by test2json
+type Event struct {
+	Time    time.Time
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64 // seconds
+	Output  string
+}
+
+// Metric is one value/unit pair from a benchmark result line, e.g. 12.5 ns/op
+type Metric struct {
+	Value float64
+	Unit  string
+}
+
+// Result is the outcome of a single test, subtest, or benchmark
+type Result struct {
+	Package  string
+	Test     string
+	Action   string // pass, fail or skip; fail if the test never finished
+	Elapsed  time.Duration
+	Output   []string
+	Metrics  []Metric
+	finished bool
+}
+
+// IsBenchmark reports whether the result came from a benchmark function
+func (r *Result) IsBenchmark() bool {
+	return strings.HasPrefix(r.Test, "Benchmark")
+}
+
+// NsPerOp returns the ns/op reported by a benchmark, and false for tests or
+// benchmarks that did not report one
+func (r *Result) NsPerOp() (float64, bool) {
+	for _, m := range r.Metrics {
+		if m.Unit == "ns/op" {
+			return m.Value, true
+		}
+	}
+	return 0, false
+}
+
+// Run holds every result read from one invocation of `go test -json`
+type Run struct {
+	Student string
+	Started time.Time
+	Results []*Result
+}
+
+// ReadRun consumes test2json events from r until EOF, copying any test output
+// to echo so that the reporter can sit transparently in a pipeline. A test that
+// started but never passed, failed or skipped, as when it times out or the
+// test binary crashes, is recorded as a failure with whatever output it wrote.
+func ReadRun(r io.Reader, echo io.Writer, student string) (*Run, error) {
+	run := &Run{Student: student, Started: time.Now()}
+	byName := make(map[string]*Result)
+
+	result := func(pkg, test string) *Result {
+		id := pkg + " " + test
+		res, ok := byName[id]
+		if !ok {
+			res = &Result{Package: pkg, Test: test}
+			byName[id] = res
+			run.Results = append(run.Results, res)
+		}
+		return res
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			// Not an event (e.g. build errors); pass it through untouched
+			echo.Write(append(scanner.Bytes(), '\n'))
+			continue
+		}
+
+		if ev.Action == "output" {
+			io.WriteString(echo, ev.Output)
+		}
+
+		// Older toolchains attribute benchmark result lines to the package
+		// rather than to the benchmark, so recover the name from the line.
+		test := ev.Test
+		if name, metrics, ok := parseBenchLine(ev.Output); ok {
+			res := result(ev.Package, name)
+			res.Metrics = metrics
+			if !res.finished {
+				res.Action = "pass"
+			}
+			continue
+		}
+
+		if test == "" {
+			continue
+		}
+
+		res := result(ev.Package, test)
+		switch ev.Action {
+		case "output":
+			res.Output = append(res.Output, ev.Output)
+		case "pass", "fail", "skip":
+			res.Action = ev.Action
+			res.Elapsed = time.Duration(ev.Elapsed * float64(time.Second))
+			res.finished = true
+		}
+	}
+
+	for _, res := range run.Results {
+		if res.Action == "" {
+			res.Action = "fail"
+		}
+	}
+
+	return run, scanner.Err()
+}
+
+// parseBenchLine parses a benchmark result line of the form
+//
+//	BenchmarkSet-8   	 5000000	       251 ns/op	      40 B/op
+//
+// returning the benchmark name without its GOMAXPROCS suffix
+func parseBenchLine(line string) (string, []Metric, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 || len(fields)%2 != 0 || !strings.HasPrefix(fields[0], "Benchmark") {
+		return "", nil, false
+	}
+	if _, err := strconv.Atoi(fields[1]); err != nil {
+		return "", nil, false
+	}
+
+	metrics := make([]Metric, 0, len(fields)/2-1)
+	for i := 2; i < len(fields); i += 2 {
+		val, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return "", nil, false
+		}
+		metrics = append(metrics, Metric{val, fields[i+1]})
+	}
+
+	name := fields[0]
+	if i := strings.LastIndexByte(name, '-'); i > 0 {
+		if _, err := strconv.Atoi(name[i+1:]); err == nil {
+			name = name[:i]
+		}
+	}
+	return name, metrics, true
+}
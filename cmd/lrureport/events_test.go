@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+const sampleRun = `{"Action":"run","Package":"lru","Test":"TestSetBasic"}
+{"Action":"output","Package":"lru","Test":"TestSetBasic","Output":"--- FAIL: TestSetBasic (0.01s)\n"}
+{"Action":"fail","Package":"lru","Test":"TestSetBasic","Elapsed":0.01}
+{"Action":"run","Package":"lru","Test":"TestRemoveBasic"}
+{"Action":"pass","Package":"lru","Test":"TestRemoveBasic","Elapsed":0.5}
+{"Action":"output","Package":"lru","Output":"BenchmarkSet-8   \t 5000000\t       251 ns/op\t      40 B/op\n"}
+{"Action":"output","Package":"lru","Test":"BenchmarkSetGet","Output":"BenchmarkSetGet-8   \t 2000000\t       612.5 ns/op\t      97.50 hit%\n"}
+{"Action":"fail","Package":"lru","Elapsed":1.2}
+`
+
+func TestReadRun(t *testing.T) {
+	run, err := ReadRun(strings.NewReader(sampleRun), ioutil.Discard, "student")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		test   string
+		action string
+		ns     float64
+	}{
+		{"TestSetBasic", "fail", 0},
+		{"TestRemoveBasic", "pass", 0},
+		{"BenchmarkSet", "pass", 251},
+		{"BenchmarkSetGet", "pass", 612.5},
+	}
+
+	if len(run.Results) != len(expected) {
+		t.Fatalf("Expected %d results, found %d", len(expected), len(run.Results))
+	}
+	for i, exp := range expected {
+		res := run.Results[i]
+		ns, _ := res.NsPerOp()
+		if res.Test != exp.test || res.Action != exp.action || ns != exp.ns {
+			t.Errorf("Result %d: expected %s %s %v ns/op, found %s %s %v ns/op",
+				i, exp.test, exp.action, exp.ns, res.Test, res.Action, ns)
+		}
+	}
+
+	if m := run.Results[3].Metrics; len(m) != 2 || m[1] != (Metric{97.5, "hit%"}) {
+		t.Errorf("Expected custom hit%% metric, found %v", m)
+	}
+}
+
+// timeoutRun is a run whose binary panics when the test times out, so the
+// running test never gets a fail event of its own
+const timeoutRun = `{"Action":"run","Package":"lru","Test":"TestSetBasic"}
+{"Action":"pass","Package":"lru","Test":"TestSetBasic","Elapsed":0.01}
+{"Action":"run","Package":"lru","Test":"TestGetOrCompute"}
+{"Action":"output","Package":"lru","Test":"TestGetOrCompute","Output":"=== RUN   TestGetOrCompute\n"}
+{"Action":"output","Package":"lru","Test":"TestGetOrCompute","Output":"panic: test timed out after 10m0s\n"}
+{"Action":"output","Package":"lru","Output":"FAIL\tlru\t600.01s\n"}
+{"Action":"fail","Package":"lru","Elapsed":600.01}
+`
+
+func TestReadRunUnfinished(t *testing.T) {
+	run, err := ReadRun(strings.NewReader(timeoutRun), ioutil.Discard, "student")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(run.Results) != 2 {
+		t.Fatalf("Expected 2 results, found %d", len(run.Results))
+	}
+
+	res := run.Results[1]
+	if res.Test != "TestGetOrCompute" || res.Action != "fail" {
+		t.Errorf("Expected the unfinished TestGetOrCompute to fail, found %s %s", res.Test, res.Action)
+	}
+	if output := strings.Join(res.Output, ""); !strings.Contains(output, "panic: test timed out") {
+		t.Errorf("Expected the unfinished test to keep its output, found %q", output)
+	}
+}
+
+func TestParseBenchLine(t *testing.T) {
+	lines := []string{
+		"--- FAIL: TestSetBasic (0.01s)",
+		"BenchmarkSet",
+		"Benchmarking is fun 1 2",
+		"BenchmarkSet-8 \t 100 \t ten ns/op",
+	}
+	for _, line := range lines {
+		if _, _, ok := parseBenchLine(line); ok {
+			t.Errorf("Parsed non-benchmark line %q", line)
+		}
+	}
+
+	name, metrics, ok := parseBenchLine("BenchmarkParallel/procs=4-16 \t 100 \t 12 ns/op")
+	if !ok || name != "BenchmarkParallel/procs=4" || len(metrics) != 1 {
+		t.Errorf("Failed to parse sub-benchmark line: %s %v", name, metrics)
+	}
+}
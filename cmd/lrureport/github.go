@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+/******************************************************************************
+ *                             GitHub Checks backend
+ ******************************************************************************/
+// The GitHub backend publishes a check run on the student's submission commit,
+// with one annotation per failing scenario.
+//
+// There is no interface for localizing a bug inside student code yet, so each
+// annotation is attached to the declaration of the LRU method named by the
+// failing operation (e.g. the "Command:  lru.Get(...)" line of a failure), or
+// to the top of the file when the failure does not name a method.
+
+// GitHub rejects check run updates carrying more than 50 annotations
+const maxAnnotationsPerRequest = 50
+
+// Annotation messages are limited to 64KB
+const maxAnnotationMessage = 64 * 1024
+
+var commandPattern = regexp.MustCompile(`Command:\s+lru\.(\w+)\(`)
+
+// Annotation is a GitHub Checks annotation on a single range of lines
+type Annotation struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Level     string `json:"annotation_level"`
+	Title     string `json:"title"`
+	Message   string `json:"message"`
+}
+
+type checkOutput struct {
+	Title       string       `json:"title"`
+	Summary     string       `json:"summary"`
+	Annotations []Annotation `json:"annotations"`
+}
+
+type checkRun struct {
+	Name       string       `json:"name,omitempty"`
+	HeadSHA    string       `json:"head_sha,omitempty"`
+	Status     string       `json:"status,omitempty"`
+	Conclusion string       `json:"conclusion,omitempty"`
+	Output     *checkOutput `json:"output"`
+}
+
+// GitHubReporter creates a check run through the GitHub Checks API
+type GitHubReporter struct {
+	API    string // e.g. https://api.github.com
+	Repo   string // owner/name
+	SHA    string // submission commit to annotate
+	Token  string // required; GitHub refuses to create check runs without one
+	Source string // path of lru.go on disk
+	Path   string // path of lru.go within the repository
+	Client *http.Client
+}
+
+// errNoToken is returned by Report when the reporter has no API token
+var errNoToken = errors.New("no GitHub API token; set GITHUB_TOKEN")
+
+// Report creates a completed check run annotated with the run's failures
+func (r *GitHubReporter) Report(run *Run) error {
+	if r.Token == "" {
+		return errNoToken
+	}
+	methods := methodLines(r.Source)
+	annotations := r.annotate(failedLeaves(run), methods)
+
+	failed, passed := 0, 0
+	for _, res := range run.Results {
+		if strings.Contains(res.Test, "/") {
+			continue
+		}
+		switch res.Action {
+		case "pass":
+			passed++
+		case "fail":
+			failed++
+		}
+	}
+
+	conclusion := "success"
+	if failed > 0 {
+		conclusion = "failure"
+	}
+
+	output := &checkOutput{
+		Title:   fmt.Sprintf("%d passed, %d failed", passed, failed),
+		Summary: fmt.Sprintf("%d failing scenarios annotated in %s", len(annotations), r.Path),
+	}
+
+	// The first batch of annotations is sent when the check run is created,
+	// and any remainder is appended by updating it.
+	batch := func() []Annotation {
+		n := len(annotations)
+		if n > maxAnnotationsPerRequest {
+			n = maxAnnotationsPerRequest
+		}
+		b := annotations[:n]
+		annotations = annotations[n:]
+		return b
+	}
+
+	output.Annotations = batch()
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	err := r.do("POST", fmt.Sprintf("/repos/%s/check-runs", r.Repo), &checkRun{
+		Name:       "LRU tests",
+		HeadSHA:    r.SHA,
+		Status:     "completed",
+		Conclusion: conclusion,
+		Output:     output,
+	}, &created)
+	if err != nil {
+		return err
+	}
+
+	for len(annotations) > 0 {
+		output.Annotations = batch()
+		err := r.do("PATCH", fmt.Sprintf("/repos/%s/check-runs/%d", r.Repo, created.ID),
+			&checkRun{Output: output}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close is a no-op; the reporter holds no resources between requests
+func (r *GitHubReporter) Close() error {
+	return nil
+}
+
+func (r *GitHubReporter) do(method, path string, body, out interface{}) error {
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(r.API, "/")+path, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+r.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// annotate builds one annotation per failing scenario
+func (r *GitHubReporter) annotate(failures []*Result, methods map[string][2]int) []Annotation {
+	annotations := make([]Annotation, 0, len(failures))
+	for _, res := range failures {
+		message := failureMessage(res)
+		lines := [2]int{1, 1}
+		if m := commandPattern.FindStringSubmatch(message); m != nil {
+			if l, ok := methods[m[1]]; ok {
+				lines = l
+			}
+		}
+
+		if len(message) > maxAnnotationMessage {
+			message = message[:maxAnnotationMessage]
+		}
+		annotations = append(annotations, Annotation{
+			Path:      r.Path,
+			StartLine: lines[0],
+			EndLine:   lines[1],
+			Level:     "failure",
+			Title:     res.Test,
+			Message:   message,
+		})
+	}
+	return annotations
+}
+
+// failedLeaves returns failing results none of whose subtests failed, since a
+// failing subtest also fails every test that encloses it
+func failedLeaves(run *Run) []*Result {
+	var failed []*Result
+	for _, res := range run.Results {
+		if res.Action == "fail" {
+			failed = append(failed, res)
+		}
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].Test < failed[j].Test })
+
+	var leaves []*Result
+	for i, res := range failed {
+		if i+1 < len(failed) && strings.HasPrefix(failed[i+1].Test, res.Test+"/") {
+			continue
+		}
+		leaves = append(leaves, res)
+	}
+	return leaves
+}
+
+// failureMessage returns a result's output without go test's own bookkeeping
+func failureMessage(res *Result) string {
+	var sb strings.Builder
+	for _, line := range res.Output {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- ") {
+			continue
+		}
+		sb.WriteString(line)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// methodLines maps the name of each method declared in the Go file at path to
+// the first and last lines of its declaration. Files that fail to parse yield
+// an empty map, in which case annotations fall back to the top of the file.
+func methodLines(path string) map[string][2]int {
+	lines := make(map[string][2]int)
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, nil, 0)
+	if err != nil {
+		return lines
+	}
+
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil {
+			continue
+		}
+		lines[fn.Name.Name] = [2]int{
+			fset.Position(fn.Pos()).Line,
+			fset.Position(fn.End()).Line,
+		}
+	}
+	return lines
+}
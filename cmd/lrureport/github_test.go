@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const studentSource = `package lru
+
+type LRU struct{}
+
+func (lru *LRU) Get(key string) (value []byte, ok bool) {
+	return nil, false
+}
+`
+
+func writeSource(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "lrureport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "lru.go")
+	if err := ioutil.WriteFile(path, []byte(studentSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGitHubAnnotations(t *testing.T) {
+	var posted []checkRun
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var cr checkRun
+		if err := json.NewDecoder(r.Body).Decode(&cr); err != nil {
+			t.Errorf("Bad request body: %v", err)
+		}
+		posted = append(posted, cr)
+		fmt.Fprint(w, `{"id": 7}`)
+	}))
+	defer server.Close()
+
+	run := &Run{Results: []*Result{
+		{Test: "TestSetBasic", Action: "fail"},
+		{Test: "TestSetBasic/Get(\"key\")", Action: "fail", Output: []string{
+			"=== RUN   TestSetBasic/Get(\"key\")\n",
+			"    Command:  lru.Get(\"key\")\n",
+			"    Expected: cache hit:<'value'>\n",
+		}},
+		{Test: "TestRemoveBasic", Action: "pass"},
+		{Test: "TestNilValue", Action: "fail", Output: []string{"panic\n"}},
+	}}
+
+	r := &GitHubReporter{API: server.URL, Repo: "o/r", SHA: "abc", Token: "token", Source: writeSource(t), Path: "lru/lru.go"}
+	if err := r.Report(run); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(posted) != 1 {
+		t.Fatalf("Expected 1 request, found %d", len(posted))
+	}
+	cr := posted[0]
+	if cr.Conclusion != "failure" || cr.HeadSHA != "abc" {
+		t.Errorf("Unexpected check run %+v", cr)
+	}
+
+	anns := cr.Output.Annotations
+	if len(anns) != 2 {
+		t.Fatalf("Expected 2 annotations (failing leaves only), found %d", len(anns))
+	}
+	// sorted by test name: TestNilValue, then the TestSetBasic subtest
+	if anns[0].StartLine != 1 {
+		t.Errorf("Unlocalized failure should annotate line 1, found %d", anns[0].StartLine)
+	}
+	if anns[1].StartLine != 5 || anns[1].EndLine != 7 {
+		t.Errorf("Get failure should annotate lines 5-7, found %d-%d",
+			anns[1].StartLine, anns[1].EndLine)
+	}
+}
+
+func TestGitHubAnnotationBatches(t *testing.T) {
+	var methods []string
+	var counts []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var cr checkRun
+		json.NewDecoder(r.Body).Decode(&cr)
+		methods = append(methods, r.Method)
+		counts = append(counts, len(cr.Output.Annotations))
+		fmt.Fprint(w, `{"id": 7}`)
+	}))
+	defer server.Close()
+
+	run := &Run{}
+	for i := 0; i < 120; i++ {
+		run.Results = append(run.Results, &Result{Test: fmt.Sprintf("Test%03d", i), Action: "fail"})
+	}
+
+	r := &GitHubReporter{API: server.URL, Repo: "o/r", SHA: "abc", Token: "token", Source: writeSource(t), Path: "lru/lru.go"}
+	if err := r.Report(run); err != nil {
+		t.Fatal(err)
+	}
+
+	expMethods := []string{"POST", "PATCH", "PATCH"}
+	expCounts := []int{50, 50, 20}
+	if fmt.Sprint(methods) != fmt.Sprint(expMethods) || fmt.Sprint(counts) != fmt.Sprint(expCounts) {
+		t.Errorf("Expected requests %v with %v annotations, found %v with %v",
+			expMethods, expCounts, methods, counts)
+	}
+}
+
+func TestGitHubNoToken(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "Bad credentials", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	run := &Run{Results: []*Result{{Test: "TestSetBasic", Action: "fail"}}}
+	r := &GitHubReporter{API: server.URL, Repo: "o/r", SHA: "abc", Source: writeSource(t), Path: "lru/lru.go"}
+	if err := r.Report(run); err != errNoToken {
+		t.Errorf("Expected Report without a token to return %v, found %v", errNoToken, err)
+	}
+	if requests != 0 {
+		t.Errorf("Expected no requests without a token, found %d", requests)
+	}
+}
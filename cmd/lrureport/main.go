@@ -0,0 +1,87 @@
+// Command lrureport reads the output of `go test -json` on standard input,
+// echoes the test output to standard output, and records the results with
+// each of the configured reporter backends:
+//
+//	go test -json -bench . ./lru | lrureport -student netid -sqlite results.db
+//
+// The -sqlite backend is only available when built with -tags sqlite.
+//
+// With -github-repo and -github-sha, failures are also published as a GitHub
+// check run annotating the student's lru.go. The API token is read from the
+// GITHUB_TOKEN environment variable, which must be set.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// A Reporter records the results of a single graded run
+type Reporter interface {
+	Report(run *Run) error
+	Close() error
+}
+
+func main() {
+	student := flag.String("student", "", "ID of the student whose submission was tested")
+	sqlitePath := flag.String("sqlite", "", "append results to the SQLite database at this path")
+	githubRepo := flag.String("github-repo", "", "publish a check run to this owner/name repository")
+	githubSHA := flag.String("github-sha", "", "commit the check run annotates")
+	githubAPI := flag.String("github-api", "https://api.github.com", "GitHub API base URL")
+	source := flag.String("source", "lru/lru.go", "path of the student's lru.go, relative to the repository root")
+	flag.Parse()
+
+	var reporters []Reporter
+
+	if *sqlitePath != "" {
+		if *student == "" {
+			fatalf("-sqlite requires -student")
+		}
+		r, err := NewSQLiteReporter(*sqlitePath)
+		if err != nil {
+			fatalf("opening %s: %v", *sqlitePath, err)
+		}
+		reporters = append(reporters, r)
+	}
+
+	if *githubRepo != "" {
+		if *githubSHA == "" {
+			fatalf("-github-repo requires -github-sha")
+		}
+		if os.Getenv("GITHUB_TOKEN") == "" {
+			fatalf("-github-repo requires a token in GITHUB_TOKEN")
+		}
+		reporters = append(reporters, &GitHubReporter{
+			API:    *githubAPI,
+			Repo:   *githubRepo,
+			SHA:    *githubSHA,
+			Token:  os.Getenv("GITHUB_TOKEN"),
+			Source: *source,
+			Path:   filepath.ToSlash(*source),
+		})
+	}
+
+	run, err := ReadRun(os.Stdin, os.Stdout, *student)
+	if err != nil {
+		fatalf("reading test output: %v", err)
+	}
+
+	failed := false
+	for _, r := range reporters {
+		if err := r.Report(run); err != nil {
+			fmt.Fprintf(os.Stderr, "lrureport: %v\n", err)
+			failed = true
+		}
+		r.Close()
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func fatalf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "lrureport: "+format+"\n", args...)
+	os.Exit(2)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+/******************************************************************************
+ *                             SQLite backend
+ ******************************************************************************/
+// The SQLite backend appends every run to a shared database, so that staff can
+// query results across the whole class, e.g. the most-failed tests:
+//
+//	SELECT test, COUNT(DISTINCT student) AS failing
+//	FROM results
+//	WHERE result = 'fail' AND test NOT LIKE '%/%'
+//	GROUP BY test
+//	ORDER BY failing DESC;
+//
+// The driver needs cgo and github.com/mattn/go-sqlite3, so it is only linked
+// in when building with the "sqlite" tag (see sqlite_driver.go):
+//
+//	go build -tags sqlite ./cmd/lrureport
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS results (
+	student   TEXT NOT NULL,
+	run       TIMESTAMP NOT NULL,
+	package   TEXT NOT NULL,
+	test      TEXT NOT NULL,
+	result    TEXT NOT NULL,
+	duration  REAL NOT NULL, -- seconds
+	ns_per_op REAL,          -- NULL for tests
+	output    TEXT           -- NULL unless the test failed
+);
+CREATE TABLE IF NOT EXISTS metrics (
+	student TEXT NOT NULL,
+	run     TIMESTAMP NOT NULL,
+	package TEXT NOT NULL,
+	test    TEXT NOT NULL,
+	unit    TEXT NOT NULL,
+	value   REAL NOT NULL
+);
+CREATE INDEX IF NOT EXISTS results_test ON results (test, result);
+`
+
+// SQLiteReporter appends results to the SQLite database at a given path
+type SQLiteReporter struct {
+	db *sql.DB
+}
+
+// NewSQLiteReporter opens (creating if necessary) the database at path
+func NewSQLiteReporter(path string) (*SQLiteReporter, error) {
+	if !sqliteDriverLinked() {
+		return nil, fmt.Errorf("built without the sqlite3 driver; rebuild with -tags sqlite")
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteReporter{db}, nil
+}
+
+// Report inserts every result of the run in a single transaction, so that a
+// failed run never leaves a partial submission in the database
+func (r *SQLiteReporter) Report(run *Run) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insResult, err := tx.Prepare(`INSERT INTO results
+		(student, run, package, test, result, duration, ns_per_op, output)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insResult.Close()
+
+	insMetric, err := tx.Prepare(`INSERT INTO metrics
+		(student, run, package, test, unit, value)
+		VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insMetric.Close()
+
+	for _, res := range run.Results {
+		var nsPerOp interface{}
+		if ns, ok := res.NsPerOp(); ok {
+			nsPerOp = ns
+		}
+		var output interface{}
+		if res.Action == "fail" {
+			output = strings.Join(res.Output, "")
+		}
+
+		_, err := insResult.Exec(run.Student, run.Started, res.Package, res.Test,
+			res.Action, res.Elapsed.Seconds(), nsPerOp, output)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range res.Metrics {
+			_, err := insMetric.Exec(run.Student, run.Started, res.Package,
+				res.Test, m.Unit, m.Value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// sqliteDriverLinked reports whether the sqlite3 driver was built in
+func sqliteDriverLinked() bool {
+	for _, name := range sql.Drivers() {
+		if name == "sqlite3" {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes the underlying database
+func (r *SQLiteReporter) Close() error {
+	return r.db.Close()
+}
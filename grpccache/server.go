@@ -0,0 +1,127 @@
+// Package grpccache serves a cache as the gRPC service lru.Cache, with unary
+// methods Get, Set, Remove and Stats, declared in cachepb/cache.proto:
+//
+//	server := grpc.NewServer()
+//	cachepb.RegisterCacheServer(server, &grpccache.Server{Cache: cache})
+//
+//	client := grpccache.NewClient(conn)
+//	val, ok, err := client.Get(ctx, "k1")
+//
+// Clients in other languages can be generated from the same .proto file.
+package grpccache
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative cachepb/cache.proto
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+
+	"github.com/cos316gradertest/assignment3-test/grpccache/cachepb"
+)
+
+// Cache is the subset of lru.ByteCache that the service uses
+type Cache interface {
+	MaxStorage() int
+	RemainingStorage() int
+	Get(key string) ([]byte, bool)
+	Remove(key string) ([]byte, bool)
+	Set(key string, value []byte) bool
+	Len() int
+}
+
+// Server implements cachepb.CacheServer with Cache
+type Server struct {
+	cachepb.UnimplementedCacheServer
+
+	Cache Cache
+
+	// Concurrent says that Cache is safe for concurrent use, so the calls
+	// that grpc serves on separate goroutines may reach it at once
+	Concurrent bool
+
+	mu sync.Mutex // serializes requests to Cache unless Concurrent
+}
+
+func (s *Server) Get(ctx context.Context, req *cachepb.GetRequest) (*cachepb.GetResponse, error) {
+	resp := new(cachepb.GetResponse)
+	s.withCache(func(c Cache) { resp.Value, resp.Found = c.Get(req.GetKey()) })
+	return resp, nil
+}
+
+func (s *Server) Set(ctx context.Context, req *cachepb.SetRequest) (*cachepb.SetResponse, error) {
+	resp := new(cachepb.SetResponse)
+	s.withCache(func(c Cache) { resp.Stored = c.Set(req.GetKey(), req.GetValue()) })
+	return resp, nil
+}
+
+func (s *Server) Remove(ctx context.Context, req *cachepb.RemoveRequest) (*cachepb.RemoveResponse, error) {
+	resp := new(cachepb.RemoveResponse)
+	s.withCache(func(c Cache) { resp.Value, resp.Found = c.Remove(req.GetKey()) })
+	return resp, nil
+}
+
+func (s *Server) Stats(ctx context.Context, req *cachepb.StatsRequest) (*cachepb.StatsResponse, error) {
+	resp := new(cachepb.StatsResponse)
+	s.withCache(func(c Cache) {
+		resp.Len = int64(c.Len())
+		resp.MaxStorage = int64(c.MaxStorage())
+		resp.RemainingStorage = int64(c.RemainingStorage())
+	})
+	return resp, nil
+}
+
+// withCache calls f with the cache, holding mu for the call unless the Server
+// is Concurrent
+func (s *Server) withCache(f func(Cache)) {
+	if !s.Concurrent {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+	f(s.Cache)
+}
+
+// Client calls the lru.Cache service over a gRPC connection, returning plain
+// Go values rather than cachepb messages
+type Client struct {
+	rpc cachepb.CacheClient
+}
+
+// NewClient returns a Client that calls the service on the other end of conn
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{cachepb.NewCacheClient(conn)}
+}
+
+// Get returns the value bound to key, and whether there was one
+func (c *Client) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	resp, err := c.rpc.Get(ctx, &cachepb.GetRequest{Key: key})
+	if err != nil {
+		return nil, false, err
+	}
+	return resp.GetValue(), resp.GetFound(), nil
+}
+
+// Set binds key to value, returning whether the cache stored it
+func (c *Client) Set(ctx context.Context, key string, value []byte) (bool, error) {
+	resp, err := c.rpc.Set(ctx, &cachepb.SetRequest{Key: key, Value: value})
+	if err != nil {
+		return false, err
+	}
+	return resp.GetStored(), nil
+}
+
+// Remove removes the binding for key, returning its value and whether there
+// was one
+func (c *Client) Remove(ctx context.Context, key string) ([]byte, bool, error) {
+	resp, err := c.rpc.Remove(ctx, &cachepb.RemoveRequest{Key: key})
+	if err != nil {
+		return nil, false, err
+	}
+	return resp.GetValue(), resp.GetFound(), nil
+}
+
+// Stats returns the number of bindings in the cache and its storage
+func (c *Client) Stats(ctx context.Context) (*cachepb.StatsResponse, error) {
+	return c.rpc.Stats(ctx, &cachepb.StatsRequest{})
+}
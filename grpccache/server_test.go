@@ -0,0 +1,123 @@
+package grpccache
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/cos316gradertest/assignment3-test/grpccache/cachepb"
+	"github.com/cos316gradertest/assignment3-test/internal/cachetest"
+)
+
+// dial serves cache over an in-memory connection and returns a client for it.
+// Any options are passed to the server.
+func dial(t *testing.T, cache Cache, opts ...grpc.ServerOption) *Client {
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(opts...)
+	cachepb.RegisterCacheServer(server, &Server{Cache: cache})
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewClient(conn)
+}
+
+func TestGetSetRemove(t *testing.T) {
+	ctx := context.Background()
+	client := dial(t, cachetest.NewMapCache(64))
+
+	if _, ok, err := client.Get(ctx, "k1"); ok || err != nil {
+		t.Errorf("Get(k1): expected a miss, found %v, %v", ok, err)
+	}
+	for key, val := range map[string]string{"k1": "v1", "k2": ""} {
+		if ok, err := client.Set(ctx, key, []byte(val)); !ok || err != nil {
+			t.Errorf("Set(%s): expected it to be stored, found %v, %v", key, ok, err)
+		}
+	}
+	if val, ok, err := client.Get(ctx, "k1"); !ok || err != nil || string(val) != "v1" {
+		t.Errorf("Get(k1): expected v1, found %q, %v, %v", val, ok, err)
+	}
+	if val, ok, err := client.Get(ctx, "k2"); !ok || err != nil || len(val) != 0 {
+		t.Errorf("Get(k2): expected an empty value, found %q, %v, %v", val, ok, err)
+	}
+
+	if val, ok, err := client.Remove(ctx, "k1"); !ok || err != nil || string(val) != "v1" {
+		t.Errorf("Remove(k1): expected v1, found %q, %v, %v", val, ok, err)
+	}
+	if _, ok, err := client.Remove(ctx, "k1"); ok || err != nil {
+		t.Errorf("Remove(k1) again: expected a miss, found %v, %v", ok, err)
+	}
+}
+
+func TestSetTooLarge(t *testing.T) {
+	ctx := context.Background()
+	client := dial(t, cachetest.NewMapCache(8))
+
+	if ok, err := client.Set(ctx, "k1", []byte("1234567")); ok || err != nil {
+		t.Errorf("Set(k1): expected it not to be stored, found %v, %v", ok, err)
+	}
+	if _, ok, _ := client.Get(ctx, "k1"); ok {
+		t.Errorf("Get(k1): expected a miss after the failed Set")
+	}
+}
+
+func TestLargeValue(t *testing.T) {
+	ctx := context.Background()
+	client := dial(t, cachetest.NewMapCache(1<<20))
+	val := bytes.Repeat([]byte{0, 1, 2, 0xff}, 64<<10)
+
+	if ok, err := client.Set(ctx, "big", val); !ok || err != nil {
+		t.Fatalf("Set(big): expected it to be stored, found %v, %v", ok, err)
+	}
+	if got, ok, err := client.Get(ctx, "big"); !ok || err != nil || !bytes.Equal(got, val) {
+		t.Errorf("Get(big): expected %d bytes back, found %d, %v, %v", len(val), len(got), ok, err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	ctx := context.Background()
+	client := dial(t, cachetest.NewMapCache(64))
+	client.Set(ctx, "k1", []byte("v1"))
+	client.Set(ctx, "k2", []byte("v2"))
+
+	st, err := client.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.GetLen() != 2 || st.GetMaxStorage() != 64 || st.GetRemainingStorage() != 56 {
+		t.Errorf("Stats: expected len:2 max_storage:64 remaining_storage:56, found %v", st)
+	}
+}
+
+func TestInterceptor(t *testing.T) {
+	ctx := context.Background()
+	var methods []string
+	client := dial(t, cachetest.NewMapCache(64), grpc.UnaryInterceptor(
+		func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			methods = append(methods, info.FullMethod)
+			return handler(ctx, req)
+		}))
+
+	client.Set(ctx, "k1", []byte("v1"))
+	if val, ok, err := client.Get(ctx, "k1"); !ok || err != nil || string(val) != "v1" {
+		t.Errorf("Get(k1) through an interceptor: expected v1, found %q, %v, %v", val, ok, err)
+	}
+
+	expected := []string{cachepb.Cache_Set_FullMethodName, cachepb.Cache_Get_FullMethodName}
+	if len(methods) != len(expected) || methods[0] != expected[0] || methods[1] != expected[1] {
+		t.Errorf("Expected the interceptor to see %v, found %v", expected, methods)
+	}
+}
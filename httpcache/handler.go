@@ -0,0 +1,207 @@
+// Package httpcache serves a cache over HTTP:
+//
+//	GET    /cache/{key}  the value bound to key, or 404 Not Found
+//	PUT    /cache/{key}  binds key to the request body, or 413 if it cannot fit
+//	DELETE /cache/{key}  removes the binding for key, or 404 Not Found
+//	GET    /stats        a JSON object of Stats
+//
+// Keys may contain slashes. PUT reads the body into a single value of its
+// declared length, refusing bodies longer than the cache before reading them,
+// and GET streams the value straight from the cache, honoring Range requests,
+// so that large values need not be copied more than once.
+package httpcache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache is the subset of lru.ByteCache that the handler uses
+type Cache interface {
+	MaxStorage() int
+	RemainingStorage() int
+	Get(key string) ([]byte, bool)
+	Remove(key string) ([]byte, bool)
+	Set(key string, value []byte) bool
+	Len() int
+}
+
+// Stats describes a Handler's cache and counts the requests it has handled
+type Stats struct {
+	Len              int `json:"len"`
+	MaxStorage       int `json:"max_storage"`
+	RemainingStorage int `json:"remaining_storage"`
+	Hits             int `json:"hits"`
+	Misses           int `json:"misses"`
+	Sets             int `json:"sets"`
+	Rejected         int `json:"rejected"`
+	Deletes          int `json:"deletes"`
+}
+
+// Handler serves Cache over HTTP
+type Handler struct {
+	Cache Cache
+
+	// Concurrent says that Cache is safe for concurrent use, so the requests
+	// that net/http serves on separate goroutines may reach it at once
+	Concurrent bool
+
+	cacheMu sync.Mutex // serializes requests to Cache unless Concurrent
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Routing by hand, rather than with a ServeMux, lets a Handler be mounted
+	// as it is, without a mux of its own
+	if r.URL.Path == "/stats" {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			notAllowed(w, "GET, HEAD")
+			return
+		}
+		h.serveStats(w)
+		return
+	}
+	key, ok := strings.CutPrefix(r.URL.Path, "/cache/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		h.get(w, r, key)
+	case http.MethodPut:
+		h.put(w, r, key)
+	case http.MethodDelete:
+		h.delete(w, r, key)
+	default:
+		notAllowed(w, "GET, HEAD, PUT, DELETE")
+	}
+}
+
+func notAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, key string) {
+	var val []byte
+	var ok bool
+	h.withCache(func(c Cache) { val, ok = c.Get(key) })
+	h.count(func(st *Stats) {
+		if ok {
+			st.Hits++
+		} else {
+			st.Misses++
+		}
+	})
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(val))
+}
+
+func (h *Handler) put(w http.ResponseWriter, r *http.Request, key string) {
+	var limit int
+	h.withCache(func(c Cache) { limit = c.MaxStorage() })
+	if r.ContentLength > int64(limit) {
+		h.reject(w)
+		return
+	}
+
+	// A value can never be longer than the cache, so read no more than that
+	body := http.MaxBytesReader(w, r.Body, int64(limit))
+	var val []byte
+	var err error
+	if r.ContentLength >= 0 {
+		// Read straight into a value of the declared length, as a buffer
+		// would grow, and copy, once more on reaching it
+		val = make([]byte, r.ContentLength)
+		_, err = io.ReadFull(body, val)
+	} else {
+		var buf bytes.Buffer
+		_, err = buf.ReadFrom(body)
+		val = buf.Bytes()
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		h.reject(w)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var ok bool
+	h.withCache(func(c Cache) { ok = c.Set(key, val) })
+	if !ok {
+		h.reject(w)
+		return
+	}
+	h.count(func(st *Stats) { st.Sets++ })
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// reject responds that a value is too large for the cache
+func (h *Handler) reject(w http.ResponseWriter) {
+	h.count(func(st *Stats) { st.Rejected++ })
+	http.Error(w, "value too large for cache", http.StatusRequestEntityTooLarge)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, key string) {
+	var ok bool
+	h.withCache(func(c Cache) { _, ok = c.Remove(key) })
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	h.count(func(st *Stats) { st.Deletes++ })
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) serveStats(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Stats())
+}
+
+// Stats returns the state of the cache and the counts of the requests the
+// handler has handled
+func (h *Handler) Stats() Stats {
+	var st Stats
+	h.withCache(func(c Cache) {
+		st.Len, st.MaxStorage, st.RemainingStorage = c.Len(), c.MaxStorage(), c.RemainingStorage()
+	})
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	st.Hits, st.Misses = h.stats.Hits, h.stats.Misses
+	st.Sets, st.Rejected, st.Deletes = h.stats.Sets, h.stats.Rejected, h.stats.Deletes
+	return st
+}
+
+func (h *Handler) count(f func(*Stats)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f(&h.stats)
+}
+
+// withCache calls f with the cache, holding cacheMu for the call unless
+// Concurrent
+func (h *Handler) withCache(f func(Cache)) {
+	if !h.Concurrent {
+		h.cacheMu.Lock()
+		defer h.cacheMu.Unlock()
+	}
+	f(h.Cache)
+}
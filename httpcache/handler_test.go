@@ -0,0 +1,152 @@
+package httpcache
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cos316gradertest/assignment3-test/internal/cachetest"
+)
+
+// do sends a request to h and checks the status and body of its response
+func do(t *testing.T, h http.Handler, req *http.Request, status int, body string) *http.Response {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	resp := rec.Result()
+	got, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != status || (body != "" && string(got) != body) {
+		t.Errorf("%s %s: expected %d %q, found %d %q",
+			req.Method, req.URL, status, body, resp.StatusCode, got)
+	}
+	return resp
+}
+
+func TestGetPutDelete(t *testing.T) {
+	h := &Handler{Cache: cachetest.NewMapCache(64)}
+	do(t, h, httptest.NewRequest("GET", "/cache/k1", nil), http.StatusNotFound, "")
+	do(t, h, httptest.NewRequest("PUT", "/cache/k1", strings.NewReader("v1")), http.StatusNoContent, "")
+	do(t, h, httptest.NewRequest("PUT", "/cache/a/b", strings.NewReader("v2")), http.StatusNoContent, "")
+
+	resp := do(t, h, httptest.NewRequest("GET", "/cache/k1", nil), http.StatusOK, "v1")
+	if ct := resp.Header.Get("Content-Type"); ct != "application/octet-stream" {
+		t.Errorf("Expected Content-Type application/octet-stream, found %s", ct)
+	}
+	do(t, h, httptest.NewRequest("GET", "/cache/a/b", nil), http.StatusOK, "v2")
+
+	do(t, h, httptest.NewRequest("DELETE", "/cache/k1", nil), http.StatusNoContent, "")
+	do(t, h, httptest.NewRequest("DELETE", "/cache/k1", nil), http.StatusNotFound, "")
+	do(t, h, httptest.NewRequest("GET", "/cache/k1", nil), http.StatusNotFound, "")
+	do(t, h, httptest.NewRequest("POST", "/cache/k1", nil), http.StatusMethodNotAllowed, "")
+}
+
+func TestPutTooLarge(t *testing.T) {
+	h := &Handler{Cache: cachetest.NewMapCache(8)}
+
+	// Declared too long, streamed too long, and too long once the key is counted
+	req := httptest.NewRequest("PUT", "/cache/k1", strings.NewReader("123456789"))
+	do(t, h, req, http.StatusRequestEntityTooLarge, "")
+	req = httptest.NewRequest("PUT", "/cache/k1", io.MultiReader(strings.NewReader("123456789")))
+	req.ContentLength = -1
+	do(t, h, req, http.StatusRequestEntityTooLarge, "")
+	do(t, h, httptest.NewRequest("PUT", "/cache/k1", strings.NewReader("1234567")),
+		http.StatusRequestEntityTooLarge, "")
+
+	do(t, h, httptest.NewRequest("GET", "/cache/k1", nil), http.StatusNotFound, "")
+	if st := h.Stats(); st.Rejected != 3 || st.Sets != 0 {
+		t.Errorf("Expected 3 rejected and no sets, found %d and %d", st.Rejected, st.Sets)
+	}
+}
+
+// setRecorder is a Cache that remembers the last value Set in it
+type setRecorder struct {
+	Cache
+	value []byte
+}
+
+func (r *setRecorder) Set(key string, value []byte) bool {
+	r.value = value
+	return r.Cache.Set(key, value)
+}
+
+func TestPutValueExact(t *testing.T) {
+	cache := &setRecorder{Cache: cachetest.NewMapCache(1 << 20)}
+	h := &Handler{Cache: cache}
+	val := bytes.Repeat([]byte("0123456789abcdef"), 4096)
+
+	do(t, h, httptest.NewRequest("PUT", "/cache/big", bytes.NewReader(val)), http.StatusNoContent, "")
+	if !bytes.Equal(cache.value, val) || cap(cache.value) != len(val) {
+		t.Errorf("Expected a value of exactly %d bytes, found %d with room for %d",
+			len(val), len(cache.value), cap(cache.value))
+	}
+
+	// A body shorter than its declared length is not stored
+	req := httptest.NewRequest("PUT", "/cache/short", strings.NewReader("v1"))
+	req.ContentLength = 4
+	do(t, h, req, http.StatusBadRequest, "")
+	do(t, h, httptest.NewRequest("GET", "/cache/short", nil), http.StatusNotFound, "")
+}
+
+func TestLargeValues(t *testing.T) {
+	limit := 8 << 20
+	server := httptest.NewServer(&Handler{Cache: cachetest.NewMapCache(limit)})
+	defer server.Close()
+	val := bytes.Repeat([]byte("0123456789abcdef"), (limit-16)/16)
+
+	req, _ := http.NewRequest("PUT", server.URL+"/cache/big", bytes.NewReader(val))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("PUT of %d bytes: expected 204, found %d", len(val), resp.StatusCode)
+	}
+
+	resp, err = http.Get(server.URL + "/cache/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !bytes.Equal(got, val) {
+		t.Errorf("GET: expected %d bytes back, found %d that differ", len(val), len(got))
+	}
+
+	// Ranges fetch a value in pieces
+	req, _ = http.NewRequest("GET", server.URL+"/cache/big", nil)
+	req.Header.Set("Range", "bytes=16-19")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || string(got) != "0123" {
+		t.Errorf("GET bytes=16-19: expected 206 \"0123\", found %d %q", resp.StatusCode, got)
+	}
+}
+
+func TestStats(t *testing.T) {
+	h := &Handler{Cache: cachetest.NewMapCache(64)}
+	do(t, h, httptest.NewRequest("PUT", "/cache/k1", strings.NewReader("v1")), http.StatusNoContent, "")
+	do(t, h, httptest.NewRequest("GET", "/cache/k1", nil), http.StatusOK, "v1")
+	do(t, h, httptest.NewRequest("GET", "/cache/k2", nil), http.StatusNotFound, "")
+	do(t, h, httptest.NewRequest("DELETE", "/cache/k2", nil), http.StatusNotFound, "")
+
+	expected := Stats{Len: 1, MaxStorage: 64, RemainingStorage: 60, Hits: 1, Misses: 1, Sets: 1}
+	if got := h.Stats(); got != expected {
+		t.Errorf("Expected stats %+v, found %+v", expected, got)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/stats", nil))
+	var got Stats
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil || got != expected {
+		t.Errorf("GET /stats: expected %+v, found %+v (%v)", expected, got, err)
+	}
+}
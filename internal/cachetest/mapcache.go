@@ -0,0 +1,50 @@
+// Package cachetest provides fixtures for testing the packages that serve a
+// cache over a network, independently of any LRU.
+package cachetest
+
+// MapCache is a cache without eviction, charging each binding the lengths of
+// its key and value
+type MapCache struct {
+	limit    int
+	bindings map[string][]byte
+}
+
+// NewMapCache returns an empty MapCache with room for limit bytes
+func NewMapCache(limit int) *MapCache {
+	return &MapCache{limit, make(map[string][]byte)}
+}
+
+func (c *MapCache) MaxStorage() int { return c.limit }
+
+func (c *MapCache) RemainingStorage() int {
+	remaining := c.limit
+	for key, val := range c.bindings {
+		remaining -= len(key) + len(val)
+	}
+	return remaining
+}
+
+func (c *MapCache) Get(key string) ([]byte, bool) {
+	val, ok := c.bindings[key]
+	return val, ok
+}
+
+func (c *MapCache) Remove(key string) ([]byte, bool) {
+	val, ok := c.bindings[key]
+	delete(c.bindings, key)
+	return val, ok
+}
+
+func (c *MapCache) Set(key string, value []byte) bool {
+	remaining := c.RemainingStorage()
+	if old, ok := c.bindings[key]; ok {
+		remaining += len(key) + len(old)
+	}
+	if len(key)+len(value) > remaining {
+		return false
+	}
+	c.bindings[key] = append([]byte(nil), value...)
+	return true
+}
+
+func (c *MapCache) Len() int { return len(c.bindings) }
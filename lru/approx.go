@@ -0,0 +1,38 @@
+package lru
+
+// ApproxLru is an experimental cache that is safe for concurrent use without a
+// global lock. Each binding records when it was last used with an atomic
+// timestamp, so that Gets never need exclusive access, and evictions take the
+// least recently used of a small random sample of bindings, as Redis does. It
+// therefore only approximates an LRU.
+type ApproxLru struct {
+	// whatever fields you want here
+}
+
+func NewApproxLru(limit, samples int) *ApproxLru {
+	return new(ApproxLru)
+}
+
+func (c *ApproxLru) MaxStorage() int {
+	return 0
+}
+
+func (c *ApproxLru) RemainingStorage() int {
+	return 0
+}
+
+func (c *ApproxLru) Get(key string) (value []byte, ok bool) {
+	return nil, false
+}
+
+func (c *ApproxLru) Remove(key string) (value []byte, ok bool) {
+	return nil, false
+}
+
+func (c *ApproxLru) Set(key string, value []byte) bool {
+	return false
+}
+
+func (c *ApproxLru) Len() int {
+	return 0
+}
@@ -0,0 +1,100 @@
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Approximate LRU tests
+ ******************************************************************************/
+
+var _ ByteCache = (*ApproxLru)(nil)
+
+func TestApproxLruBasic(t *testing.T) {
+	// desc := "Check that an approximate LRU stores, finds and removes bindings"
+	limit := 1024
+	c := NewApproxLru(limit, 5)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Max, limit),
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k1", b("v11"), true),
+		NewOp(Get, "k1", &Record{b("v11"), true}),
+		NewOp(Get, "k3", &Record{nil, false}),
+		NewOp(Remove, "k2", &Record{b("v2"), true}),
+		NewOp(Remove, "k2", &Record{nil, false}),
+		NewOp(Set, "toolarge", make([]byte, limit), false),
+		NewOp(Remaining, limit-len("k1v11")),
+		NewOp(Len, 1),
+	})
+}
+
+func TestApproxLruExact(t *testing.T) {
+	// desc := "Check that sampling at least every binding evicts exactly as an LRU would"
+	limit := 16 // room for 4 bindings
+	c := NewApproxLru(limit, 16)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "k5", b("v5"), true), // evicts k2
+		NewOp(Set, "k3", b("33"), true),
+		NewOp(Set, "k6", b("v6v6"), true), // evicts k4 and k1
+		NewOp(Get, "k1", &Record{nil, false}),
+		NewOp(Get, "k2", &Record{nil, false}),
+		NewOp(Get, "k4", &Record{nil, false}),
+		NewOp(Get, "k3", &Record{b("33"), true}),
+		NewOp(Get, "k5", &Record{b("v5"), true}),
+		NewOp(Len, 3),
+		NewOp(Remaining, 2),
+	})
+}
+
+func TestApproxLruApproximation(t *testing.T) {
+	// desc := "Check that recently used bindings usually survive sampled eviction"
+	// Sampling may evict the odd recently used binding, so only most need survive
+	limit := 100 * 8 // room for 100 bindings
+	c := NewApproxLru(limit, 8)
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("c%03d", i)
+		c.Set(key, []byte(key))
+	}
+	for i := 0; i < 10; i++ {
+		c.Get(fmt.Sprintf("c%03d", i))
+	}
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("n%03d", i)
+		if !c.Set(key, []byte(key)) {
+			t.Fatalf(operationFailMessage, Set, key, Expected{true}, Expected{false})
+		}
+	}
+
+	survivors := 0
+	for i := 0; i < 10; i++ {
+		if _, ok := c.Get(fmt.Sprintf("c%03d", i)); ok {
+			survivors++
+		}
+	}
+	if survivors < 7 {
+		t.Errorf("Only %d of 10 recently used bindings survived 50 evictions", survivors)
+	}
+	if c.Len() != 100 || c.RemainingStorage() != 0 {
+		t.Errorf("Expected 100 bindings filling the cache, found %d with %d bytes remaining",
+			c.Len(), c.RemainingStorage())
+	}
+}
+
+func TestApproxLruConcurrent(t *testing.T) {
+	// desc := "Check that an approximate LRU survives concurrent use"
+	hammer(t, NewApproxLru(capacityOr(64*8), 5), 8, opsOr(2000))
+}
+
+func BenchmarkApproxLruReadHeavy(b *testing.B) {
+	benchmarkReadHeavy(b, NewApproxLru(1<<12*16, 5))
+}
@@ -0,0 +1,42 @@
+package lru
+
+// ArcPolicy is the Adaptive Replacement Cache policy, which balances recency
+// against frequency by adapting how much storage it targets for bindings
+// used only once (T1) rather than repeatedly (T2), learning from ghost lists
+// of bindings recently evicted from each (B1 and B2)
+type ArcPolicy struct {
+	// whatever fields you want here
+}
+
+// NewArcPolicy returns an ARC policy for a cache with capacity to store limit
+// bytes
+func NewArcPolicy(limit int) *ArcPolicy {
+	return new(ArcPolicy)
+}
+
+// NewArc returns a new ARC cache with capacity to store limit bytes
+func NewArc(limit int) *PolicyCache {
+	return NewPolicyCache(limit, NewArcPolicy(limit))
+}
+
+func (p *ArcPolicy) Add(key string, size int) {
+}
+
+func (p *ArcPolicy) Update(key string, size int) {
+}
+
+func (p *ArcPolicy) Access(key string) {
+}
+
+func (p *ArcPolicy) Remove(key string) {
+}
+
+func (p *ArcPolicy) Evict() string {
+	return ""
+}
+
+// Target returns the storage, in bytes, that the policy currently targets for
+// bindings in T1
+func (p *ArcPolicy) Target() int {
+	return 0
+}
@@ -0,0 +1,112 @@
+package lru
+
+import "testing"
+
+/******************************************************************************
+ *                             ARC tests
+ ******************************************************************************/
+
+// CheckTarget fails the test unless p's target size for T1 is expected
+func CheckTarget(t *testing.T, p *ArcPolicy, expected int) {
+	if got := p.Target(); got != expected {
+		t.Errorf(operationFailMessage, "Target", "", Expected{expected}, Expected{got})
+	}
+}
+
+func TestArcScanResistance(t *testing.T) {
+	// desc := "Check that ARC keeps bindings used twice through a scan, where LRU would not"
+	ops := []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k2", &Record{b("v2"), true}),
+	}
+	for _, key := range []string{"s1", "s2", "s3", "s4", "s5", "s6"} {
+		ops = append(ops, NewOp(Set, key, b("vv"), true))
+	}
+	RunPolicyTrace(t, PolicyTrace{
+		limit: 16, // room for 4 bindings
+		ops:   ops,
+		present: map[string][]string{
+			"ARC": {"k1", "k2", "s5", "s6"},
+			"LRU": {"s3", "s4", "s5", "s6"},
+		},
+		absent: map[string][]string{
+			"ARC": {"s1", "s2", "s3", "s4"},
+			"LRU": {"k1", "k2"},
+		},
+	}, map[string]func() Policy{
+		"ARC": func() Policy { return NewArcPolicy(16) },
+		"LRU": func() Policy { return NewLruPolicy() },
+	})
+}
+
+func TestArcAdaptation(t *testing.T) {
+	// desc := "Check that ghost hits move ARC's target towards the list that missed"
+	limit := 16 // room for 4 bindings
+	p := NewArcPolicy(limit)
+	c := NewPolicyCache(limit, p)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}), // k1 and k2 move to T2
+		NewOp(Get, "k2", &Record{b("v2"), true}),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Set, "k5", b("v5"), true), // evicts k3 from T1 to B1
+	})
+	CheckTarget(t, p, 0)
+	CheckResident(t, c, []string{"k1", "k2", "k4", "k5"}, []string{"k3"})
+
+	// A hit in B1 means T1 was too small, so the target grows by k3's size
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k3", b("v3"), true), // evicts k4 from T1 to B1
+	})
+	CheckTarget(t, p, 4)
+	CheckResident(t, c, []string{"k1", "k2", "k3", "k5"}, []string{"k4"})
+
+	// Once T1 is within its target, eviction turns to T2
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k4", b("v4"), true), // evicts k1 from T2 to B2
+	})
+	CheckTarget(t, p, 8)
+	CheckResident(t, c, []string{"k2", "k3", "k4", "k5"}, []string{"k1"})
+
+	// A hit in B2 means T2 was too small, so the target shrinks again, and
+	// with T1 exactly at its target, T1 gives up a binding
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true), // evicts k5 from T1 to B1
+	})
+	CheckTarget(t, p, 4)
+	CheckResident(t, c, []string{"k1", "k2", "k3", "k4"}, []string{"k5"})
+}
+
+func TestArcRemove(t *testing.T) {
+	// desc := "Check that a removed binding leaves no ghost behind"
+	limit := 16 // room for 4 bindings
+	p := NewArcPolicy(limit)
+	c := NewPolicyCache(limit, p)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Remove, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "k1", b("v1"), true), // a new binding, not a ghost hit
+		NewOp(Len, 1),
+	})
+	CheckTarget(t, p, 0)
+}
+
+func TestArcHitRatio(t *testing.T) {
+	// desc := "Check that ARC beats LRU on a workload mixing hot keys with scans"
+	// Hot keys are used twice per round, but a round uses more keys than fit
+	limit := 64 * 8 // room for 64 bindings
+	trace := ScanTrace(32, 2, 48, 50)
+
+	arc := ReplayHits(NewArc(limit), trace)
+	lru := ReplayHits(NewPolicyCache(limit, NewLruPolicy()), trace)
+	if 2*arc < 3*lru || 2*arc < len(trace) {
+		t.Errorf("ARC hit %d and LRU hit %d of %d Gets; expected ARC to hit at least "+
+			"1.5 times as often as LRU, and at least half of the time", arc, lru, len(trace))
+	}
+}
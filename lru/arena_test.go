@@ -0,0 +1,192 @@
+package lru
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Arena tests
+ ******************************************************************************/
+
+// arenaValue returns a value of n bytes that differs for each i
+func arenaValue(i, n int) []byte {
+	val := make([]byte, n)
+	for j := range val {
+		val[j] = byte(i + j)
+	}
+	return val
+}
+
+// CheckModelChurn fails the test unless an LRU made with opts agrees with the
+// model on a long random trace of Sets, Gets and Removes of values large and
+// small
+func CheckModelChurn(t *testing.T, opts Options) {
+	limit := capacityOr(64 << 10)
+	lru := NewLruWithOptions(limit, opts)
+	model := NewModel(limit)
+	evicted := map[string][]byte{}
+	lru.SetEvictedCallback(func(key string, val []byte) {
+		evicted[key] = val
+	})
+
+	rng := rand.New(rand.NewSource(316))
+	sizes := []int{0, 1, 15, 16, 17, 100, 1000, 4096, 4097, 6000}
+	for i := 0; i < opsOr(20000); i++ {
+		key := fmt.Sprintf("k%03d", rng.Intn(200))
+		switch rng.Intn(4) {
+		case 0, 1:
+			val := arenaValue(i, sizes[rng.Intn(len(sizes))])
+			expected, expectedEvicted := model.Set(key, val)
+			for k := range evicted {
+				delete(evicted, k)
+			}
+			if got := lru.Set(key, val); got != expected {
+				t.Fatalf(operationFailMessage, "Set", fmt.Sprintf("%q, %d bytes", key, len(val)),
+					Expected{expected}, Expected{got})
+			}
+			if len(evicted) != len(expectedEvicted) {
+				t.Fatalf("Set(%q) evicted %d bindings, expected %v", key, len(evicted), quoteKeys(expectedEvicted))
+			}
+		case 2:
+			expected, _ := model.Get(key)
+			if got, _ := lru.Get(key); !bytes.Equal(got, expected) {
+				t.Fatalf(operationFailMessage, "Get", fmt.Sprintf("%q", key),
+					fmt.Sprintf("%d bytes", len(expected)), fmt.Sprintf("%d bytes, different", len(got)))
+			}
+		default:
+			expected, _ := model.Remove(key)
+			if got, _ := lru.Remove(key); !bytes.Equal(got, expected) {
+				t.Fatalf(operationFailMessage, "Remove", fmt.Sprintf("%q", key),
+					fmt.Sprintf("%d bytes", len(expected)), fmt.Sprintf("%d bytes, different", len(got)))
+			}
+		}
+		if got := lru.RemainingStorage(); got != model.RemainingStorage() {
+			t.Fatalf(operationFailMessage, "RemainingStorage", &Args{},
+				Expected{model.RemainingStorage()}, Expected{got})
+		}
+	}
+
+	// Every binding left is intact, wherever it was kept
+	for _, key := range model.Keys() {
+		expected, _ := model.Peek(key)
+		if got, _ := lru.Peek(key); !bytes.Equal(got, expected) {
+			t.Errorf(operationFailMessage, "Peek", fmt.Sprintf("%q", key),
+				fmt.Sprintf("%d bytes", len(expected)), fmt.Sprintf("%d bytes, different", len(got)))
+		}
+	}
+}
+
+func TestArenaModel(t *testing.T) {
+	// desc := "Check that an LRU with an arena agrees with the model under churn"
+	CheckModelChurn(t, Options{Arena: true})
+}
+
+func TestArenaCopies(t *testing.T) {
+	// desc := "Check that an arena implies copying values in and out"
+	CheckAliases(t, NewLruWithOptions(64, Options{Arena: true}), false, false)
+}
+
+func TestArenaEvictedValues(t *testing.T) {
+	// desc := "Check that values handed out stay intact once their chunks are reused"
+	lru := NewLruWithOptions(64, Options{Arena: true})
+	var evicted [][]byte
+	lru.SetEvictedCallback(func(key string, val []byte) {
+		evicted = append(evicted, val)
+	})
+
+	lru.Set("k1", arenaValue(1, 20))
+	lru.Set("k2", arenaValue(2, 20))
+	removed, _ := lru.Remove("k1")
+	lru.Set("k3", arenaValue(3, 20)) // may reuse k1's chunk
+	lru.Set("k4", arenaValue(4, 20)) // evicts k2
+	lru.Set("k5", arenaValue(5, 20)) // may reuse k2's chunk, and evicts k3
+
+	if !bytes.Equal(removed, arenaValue(1, 20)) {
+		t.Errorf("Value removed for k1 changed to %v", removed)
+	}
+	if len(evicted) != 2 || !bytes.Equal(evicted[0], arenaValue(2, 20)) || !bytes.Equal(evicted[1], arenaValue(3, 20)) {
+		t.Errorf("Values evicted for k2 and k3 changed to %v", evicted)
+	}
+}
+
+func TestArenaRange(t *testing.T) {
+	// desc := "Check that Range sees the values bound when it began, even once they are replaced"
+	lru := NewLruWithOptions(1024, Options{Arena: true})
+	lru.Set("k1", arenaValue(1, 20))
+	lru.Set("k2", arenaValue(2, 20))
+
+	expected := []Binding{{"k1", arenaValue(1, 20)}, {"k2", arenaValue(2, 20)}}
+	var walked []Binding
+	lru.Range(func(key string, val []byte) bool {
+		walked = append(walked, Binding{key, val})
+		if key == "k1" {
+			lru.Purge()
+			for i := 0; i < 8; i++ {
+				lru.Set(fmt.Sprintf("n%d", i), arenaValue(10+i, 20))
+			}
+		}
+		return true
+	})
+	CheckRange(t, walked, expected)
+}
+
+func TestArenaStorage(t *testing.T) {
+	// desc := "Check that an arena charges for values, not chunks, compressed or not"
+	for _, opts := range []Options{{Arena: true}, {Arena: true, CompressAbove: 64}} {
+		t.Run(fmt.Sprintf("%+v", opts), func(t *testing.T) {
+			limit := 1024
+			lru := NewLruWithOptions(limit, opts)
+			plain := NewLruWithOptions(limit, Options{CompressAbove: opts.CompressAbove})
+			vals := [][]byte{b("v"), arenaValue(1, 17), bytes.Repeat(b("a"), 500), arenaValue(2, 200)}
+			for i, val := range vals {
+				key := fmt.Sprintf("k%d", i)
+				lru.Set(key, val)
+				plain.Set(key, val)
+			}
+			ExecuteOperations(t, lru, []Operation{
+				NewOp(Remaining, plain.RemainingStorage()),
+				NewOp(Get, "k2", &Record{bytes.Repeat(b("a"), 500), true}),
+				NewOp(Get, "k3", &Record{arenaValue(2, 200), true}),
+			})
+		})
+	}
+}
+
+// benchmarkArenaChurn keeps about 16,000 small values bound in an LRU made
+// with opts while replacing them, reporting the garbage collector's work
+// along with the allocations
+func benchmarkArenaChurn(b *testing.B, opts Options) {
+	rng := rand.New(rand.NewSource(316))
+	vals := make([][]byte, 256)
+	for i := range vals {
+		vals[i] = arenaValue(i, 16+rng.Intn(240))
+	}
+	keys := make([]string, 1<<16)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%05d", i)
+	}
+	lru := NewLruWithOptions(16000*(8+136), opts)
+	for i := range keys {
+		lru.Set(keys[i], vals[i%len(vals)])
+	}
+
+	gc := startGCSampler()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lru.Set(keys[rng.Intn(len(keys))], vals[i%len(vals)])
+	}
+	b.StopTimer()
+	gc.report(b)
+}
+
+func BenchmarkArenaChurnHeap(b *testing.B) {
+	benchmarkArenaChurn(b, Options{CopyOnWrite: true})
+}
+
+func BenchmarkArenaChurnArena(b *testing.B) {
+	benchmarkArenaChurn(b, Options{Arena: true})
+}
@@ -0,0 +1,169 @@
+//go:build baselines
+
+package lru
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+
+	ristretto "github.com/dgraph-io/ristretto/v2"
+	groupcache "github.com/golang/groupcache/lru"
+	hashicorp "github.com/hashicorp/golang-lru/v2"
+)
+
+/******************************************************************************
+ *                          Library baseline benchmarks
+ ******************************************************************************/
+// These benchmarks only build with the "baselines" tag, since they need
+// popular LRU libraries that the assignment itself does not:
+//
+//	go test -tags baselines -run XXX -bench Baseline
+//
+// Each replays BenchmarkZipf's workload on one library, sized to hold as many
+// bindings as the LRU does, and reports its hit ratio as "hit%" and its
+// throughput relative to the LRU's as "x-lru" (above 1 is faster).
+
+// baselineCache is what the workload needs of a cache
+type baselineCache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte) bool
+}
+
+type groupcacheBaseline struct{ c *groupcache.Cache }
+
+func (g groupcacheBaseline) Get(key string) ([]byte, bool) {
+	val, ok := g.c.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return val.([]byte), true
+}
+
+func (g groupcacheBaseline) Set(key string, value []byte) bool {
+	g.c.Add(key, value)
+	return true
+}
+
+type hashicorpBaseline struct {
+	c *hashicorp.Cache[string, []byte]
+}
+
+func (h hashicorpBaseline) Get(key string) ([]byte, bool) {
+	return h.c.Get(key)
+}
+
+func (h hashicorpBaseline) Set(key string, value []byte) bool {
+	h.c.Add(key, value)
+	return true
+}
+
+// ristrettoBaseline does not wait for Sets to be applied, so, as in real use,
+// some are dropped under load and cost ristretto hits
+type ristrettoBaseline struct {
+	c *ristretto.Cache[string, []byte]
+}
+
+func (r ristrettoBaseline) Get(key string) ([]byte, bool) {
+	return r.c.Get(key)
+}
+
+func (r ristrettoBaseline) Set(key string, value []byte) bool {
+	r.c.Set(key, value, int64(len(key)+len(value)))
+	return true
+}
+
+// Every binding in the workload is an 8-byte key bound to itself
+const baselineBinding = 16
+
+// baselineKeys is the number of distinct keys in the workload. The caches
+// have room for half of them.
+const baselineKeys = 1 << 16
+
+var baselineTrace = sync.OnceValue(func() []string {
+	keys := make([]string, baselineKeys)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%08x", i)
+	}
+
+	rng := rand.New(rand.NewSource(316))
+	zipf := rand.NewZipf(rng, 1.1, 1, uint64(baselineKeys-1))
+	trace := make([]string, 1<<20)
+	for i := range trace {
+		trace[i] = keys[zipf.Uint64()]
+	}
+	return trace
+})
+
+// replayBaseline runs the workload on c for b.N operations, once c is warm,
+// reporting the hit ratio, and returns the time per operation
+func replayBaseline(b *testing.B, c baselineCache) float64 {
+	trace := baselineTrace()
+	hitRatio := zipfHitRatio(b, c, trace)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		key := trace[i%len(trace)]
+		if _, ok := c.Get(key); !ok && !c.Set(key, []byte(key)) {
+			b.FailNow()
+		}
+	}
+
+	b.StopTimer()
+	b.ReportMetric(hitRatio, "hit%")
+	return float64(b.Elapsed().Nanoseconds()) / float64(b.N)
+}
+
+// lruNsPerOp is the LRU's time per operation on the workload, measured once
+var lruNsPerOp = sync.OnceValue(func() float64 {
+	var ns float64
+	testing.Benchmark(func(b *testing.B) {
+		ns = replayBaseline(b, NewLru(baselineKeys/2*baselineBinding))
+	})
+	return ns
+})
+
+func benchmarkBaseline(b *testing.B, newCache func() baselineCache) {
+	reference := lruNsPerOp()
+	ns := replayBaseline(b, newCache())
+	b.ReportMetric(reference/ns, "x-lru")
+}
+
+func BenchmarkBaselineLru(b *testing.B) {
+	benchmarkBaseline(b, func() baselineCache {
+		return NewLru(baselineKeys / 2 * baselineBinding)
+	})
+}
+
+func BenchmarkBaselineGroupcache(b *testing.B) {
+	benchmarkBaseline(b, func() baselineCache {
+		return groupcacheBaseline{groupcache.New(baselineKeys / 2)}
+	})
+}
+
+func BenchmarkBaselineHashicorp(b *testing.B) {
+	benchmarkBaseline(b, func() baselineCache {
+		c, err := hashicorp.New[string, []byte](baselineKeys / 2)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return hashicorpBaseline{c}
+	})
+}
+
+func BenchmarkBaselineRistretto(b *testing.B) {
+	benchmarkBaseline(b, func() baselineCache {
+		c, err := ristretto.NewCache(&ristretto.Config[string, []byte]{
+			NumCounters:        10 * baselineKeys, // ristretto's recommendation
+			MaxCost:            baselineKeys / 2 * baselineBinding,
+			BufferItems:        64,
+			IgnoreInternalCost: true,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(c.Close)
+		return ristrettoBaseline{c}
+	})
+}
@@ -0,0 +1,27 @@
+package lru
+
+// BloomFilter remembers a set of keys in a fixed number of bits. It may claim
+// to contain keys it was never given, but never forgets one it was.
+type BloomFilter struct {
+	// whatever fields you want here
+}
+
+// NewBloomFilter returns a BloomFilter sized to hold expected keys with a
+// false positive rate of about fpRate
+func NewBloomFilter(expected int, fpRate float64) *BloomFilter {
+	return new(BloomFilter)
+}
+
+func (f *BloomFilter) Add(key string) {
+}
+
+func (f *BloomFilter) MightContain(key string) bool {
+	return false
+}
+
+func (lru *LRU) SetBloomFilter(filter *BloomFilter) {
+}
+
+func (lru *LRU) MightContain(key string) bool {
+	return false
+}
@@ -0,0 +1,91 @@
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Bloom filter tests
+ ******************************************************************************/
+
+func TestBloomFilterNoFalseNegatives(t *testing.T) {
+	// desc := "Check that a bloom filter never forgets a key, even past its expected size"
+	f := NewBloomFilter(100, 0.01)
+	for i := 0; i < 1000; i++ {
+		f.Add(fmt.Sprintf("key%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		if key := fmt.Sprintf("key%d", i); !f.MightContain(key) {
+			t.Fatalf(operationFailMessage, "MightContain", fmt.Sprintf("%q", key), Expected{true}, Expected{false})
+		}
+	}
+}
+
+func TestBloomFilterFalsePositives(t *testing.T) {
+	// desc := "Check that a full bloom filter has about the false positive rate it was sized for"
+	for _, fpRate := range []float64{0.1, 0.01, 0.001} {
+		n := 10000
+		f := NewBloomFilter(n, fpRate)
+		for i := 0; i < n; i++ {
+			f.Add(fmt.Sprintf("in%d", i))
+		}
+
+		trials, positives := 100000, 0
+		for i := 0; i < trials; i++ {
+			if f.MightContain(fmt.Sprintf("out%d", i)) {
+				positives++
+			}
+		}
+		// Allow half as many again, for hashes that are not quite ideal
+		if rate := float64(positives) / float64(trials); rate > 1.5*fpRate {
+			t.Errorf("Expected a false positive rate of about %v, found %v", fpRate, rate)
+		}
+	}
+}
+
+func TestBloomFilterEmpty(t *testing.T) {
+	// desc := "Check that an empty bloom filter contains nothing"
+	f := NewBloomFilter(1000, 0.01)
+	for i := 0; i < 100; i++ {
+		if f.MightContain(fmt.Sprintf("key%d", i)) {
+			t.Fatalf("Expected an empty filter to contain nothing")
+		}
+	}
+}
+
+// CheckMightContain asserts what lru.MightContain returns for each key
+func CheckMightContain(t *testing.T, lru *LRU, expected map[string]bool) {
+	t.Helper()
+	for key, exp := range expected {
+		if got := lru.MightContain(key); got != exp {
+			t.Errorf(operationFailMessage, "MightContain", fmt.Sprintf("%q", key), Expected{exp}, Expected{got})
+		}
+	}
+}
+
+func TestLruMightContain(t *testing.T) {
+	// desc := "Check that an LRU's filter remembers every key it has held, bound or not"
+	lru := NewLru(8)
+	lru.Set("k1", b("v1"))
+	CheckMightContain(t, lru, map[string]bool{"k1": true, "k9": true}) // no filter yet
+
+	lru.SetBloomFilter(NewBloomFilter(100, 0.001))
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true), // evicts k1
+		NewOp(Remove, "k2", &Record{b("v2"), true}),
+		NewOp(Set, "toolarge", b("v"), false),
+	})
+	lru.SetLoader(NewFakeLoader(map[string][]byte{"k4": b("v4")}))
+	lru.Get("k4")
+	lru.Purge()
+
+	CheckMightContain(t, lru, map[string]bool{
+		"k1": true, "k2": true, "k3": true, "k4": true,
+		"k9": false, "toolarge": false,
+	})
+
+	lru.SetBloomFilter(nil)
+	CheckMightContain(t, lru, map[string]bool{"k9": true})
+}
@@ -0,0 +1,30 @@
+package lru
+
+// Budget caps the storage used by every LRU registered with it together
+type Budget struct {
+	// whatever fields you want here
+}
+
+func NewBudget(limit int) *Budget {
+	return new(Budget)
+}
+
+func (b *Budget) Register(lru *LRU) bool {
+	return false
+}
+
+func (b *Budget) Unregister(lru *LRU) bool {
+	return false
+}
+
+func (b *Budget) MaxStorage() int {
+	return 0
+}
+
+func (b *Budget) RemainingStorage() int {
+	return 0
+}
+
+func (b *Budget) Len() int {
+	return 0
+}
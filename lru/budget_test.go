@@ -0,0 +1,152 @@
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+/******************************************************************************
+ *                              Budget tests
+ ******************************************************************************/
+
+// CheckBudget asserts how much storage b has left, and how many LRUs are
+// registered with it
+func CheckBudget(t *testing.T, budget *Budget, remaining, registered int) {
+	t.Helper()
+	if budget.RemainingStorage() != remaining {
+		t.Errorf(operationFailMessage, "RemainingStorage", &Args{}, Expected{remaining}, Expected{budget.RemainingStorage()})
+	}
+	if budget.Len() != registered {
+		t.Errorf(operationFailMessage, "Len", &Args{}, Expected{registered}, Expected{budget.Len()})
+	}
+}
+
+func TestBudgetBasic(t *testing.T) {
+	// desc := "Check that LRUs sharing a budget never use more than it together"
+	budget := NewBudget(16)
+	first, second := NewLru(16), NewLru(16)
+	budget.Register(first)
+	budget.Register(second)
+
+	ExecuteOperations(t, first, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+	})
+	ExecuteOperations(t, second, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true), // the larger, second evicts its own k1
+		NewOp(Get, "k1", &Record{nil, false}),
+		NewOp(Remaining, 8),
+	})
+	CheckKeys(t, first, []string{"k1", "k2"})
+	CheckBudget(t, budget, 0, 2)
+	if budget.MaxStorage() != 16 {
+		t.Errorf(operationFailMessage, "MaxStorage", &Args{}, Expected{16}, Expected{budget.MaxStorage()})
+	}
+}
+
+func TestBudgetFairness(t *testing.T) {
+	// desc := "Check that the largest LRU gives up storage first, so churn in one cannot starve another"
+	budget := NewBudget(16)
+	quiet, noisy := NewLru(16), NewLru(16)
+	budget.Register(quiet)
+	budget.Register(noisy)
+
+	for i := 1; i <= 4; i++ {
+		quiet.Set(fmt.Sprintf("q%d", i), b("v1"))
+	}
+	// quiet, the larger, evicts q1 then q2; from then on noisy evicts its own
+	for i := 0; i < 100; i++ {
+		ExecuteOperations(t, noisy, []Operation{NewOp(Set, fmt.Sprintf("n%02d", i), b("v"), true)})
+	}
+	CheckKeys(t, quiet, []string{"q3", "q4"})
+	CheckKeys(t, noisy, []string{"n98", "n99"})
+	CheckBudget(t, budget, 0, 2)
+}
+
+func TestBudgetTies(t *testing.T) {
+	// desc := "Check that LRUs using equal storage give it up in the order they were registered"
+	budget := NewBudget(16)
+	caches := []*LRU{NewLru(16), NewLru(16), NewLru(16)}
+	var evicted []string
+	for i, lru := range caches {
+		budget.Register(lru)
+		i := i
+		lru.SetEvictedCallback(func(key string, val []byte) {
+			evicted = append(evicted, fmt.Sprintf("%d:%s", i, key))
+		})
+	}
+	caches[1].Set("k1", b("v1"))
+	caches[1].Set("k2", b("v2"))
+	caches[0].Set("k1", b("v1"))
+	caches[0].Set("k2", b("v2"))
+
+	caches[2].Set("k1", b("v1")) // cache 0 and 1 are tied
+	caches[2].Set("k2", b("v2")) // cache 1 is the largest
+	expected := []string{"0:k1", "1:k1"}
+	if fmt.Sprint(evicted) != fmt.Sprint(expected) {
+		t.Errorf("Expected evictions %v, found %v", expected, evicted)
+	}
+	for _, lru := range caches {
+		stats := lru.Stats()
+		if lru != caches[2] && stats.Evictions != 1 {
+			t.Errorf(operationFailMessage, "Stats().Evictions", &Args{}, Expected{1}, Expected{stats.Evictions})
+		}
+	}
+}
+
+func TestBudgetRegister(t *testing.T) {
+	// desc := "Check that registering evicts until the LRUs fit, and unregistering frees their storage"
+	budget := NewBudget(12)
+	first, second := NewLru(16), NewLru(16)
+	first.Set("k1", b("v1"))
+	first.Set("k2", b("v2"))
+	second.Set("k1", b("v1"))
+	second.Set("k2", b("v2"))
+
+	if !budget.Register(first) || budget.Register(first) || NewBudget(64).Register(first) {
+		t.Errorf("Expected an LRU to be registered with only one budget, once")
+	}
+	CheckBudget(t, budget, 4, 1)
+	budget.Register(second) // first evicts k1
+	CheckKeys(t, first, []string{"k2"})
+	CheckKeys(t, second, []string{"k1", "k2"})
+	CheckBudget(t, budget, 0, 2)
+
+	if !budget.Unregister(second) || budget.Unregister(second) {
+		t.Errorf("Expected Unregister to succeed once")
+	}
+	CheckKeys(t, second, []string{"k1", "k2"})
+	CheckBudget(t, budget, 8, 1)
+	ExecuteOperations(t, second, []Operation{NewOp(Set, "k3", b("v3"), true)})
+	CheckKeys(t, first, []string{"k2"})
+}
+
+func TestBudgetLimits(t *testing.T) {
+	// desc := "Check that bindings larger than the budget are refused, and pinned bindings kept"
+	budget := NewBudget(8)
+	first, second := NewLru(16), NewLru(16)
+	budget.Register(first)
+	budget.Register(second)
+
+	ExecuteOperations(t, first, []Operation{
+		NewOp(SetE, "toolarge", b("v"), ErrTooLarge),
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+	})
+	first.Pin("k1")
+	ExecuteOperations(t, second, []Operation{
+		NewOp(Set, "k1", b("v1"), true), // first evicts k2, skipping pinned k1
+		NewOp(Set, "k2", b("v2"), true), // second evicts its own k1
+	})
+	CheckKeys(t, first, []string{"k1"})
+	CheckKeys(t, second, []string{"k2"})
+
+	// With only pinned bindings left to evict, the budget is overrun
+	second.Pin("k2")
+	ExecuteOperations(t, second, []Operation{NewOp(Set, "k3", b("v3"), true)})
+	CheckKeys(t, first, []string{"k1"})
+	CheckKeys(t, second, []string{"k2", "k3"})
+	CheckBudget(t, budget, 0, 2)
+}
@@ -0,0 +1,217 @@
+package lru
+
+import (
+	"fmt"
+	"log"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Generic cache harness
+ ******************************************************************************/
+// The generic Cache is exercised through a port of the core of the LRU
+// harness: a Cache[string, []byte] must pass the same operations as an LRU.
+
+var (
+	_ ByteCache = (*LRU)(nil)
+	_ ByteCache = (*Cache[string, []byte])(nil)
+)
+
+// ExecuteCacheOperation is ExecuteOperation for any ByteCache, supporting
+// only the methods that ByteCache has
+func ExecuteCacheOperation(t testing.TB, c ByteCache, op Operation) {
+	ValidateOperation(op)
+
+	fail := false
+	var result interface{}
+
+	// Name the section of a failing operation after its failure message
+	defer sectionOnFailure(t, op, t.Failed())
+	// Catch panics raised by student code so all tests will finish running
+	defer CatchPanic(t, op)
+
+	switch op.method {
+	case Get, Remove:
+		var val []byte
+		var ok bool
+		if op.method == Get {
+			val, ok = c.Get(op.args.Key())
+		} else {
+			val, ok = c.Remove(op.args.Key())
+		}
+		result = &Record{val, ok}
+		fail = !op.expected.Record().Equals(result.(*Record))
+
+	case Set:
+		result = c.Set(op.args.Key(), op.args.Val())
+		fail = result.(bool) != op.expected.Bool()
+
+	case Max, Remaining, Len:
+		switch op.method {
+		case Max:
+			result = c.MaxStorage()
+		case Remaining:
+			result = c.RemainingStorage()
+		default:
+			result = c.Len()
+		}
+		fail = result.(int) != op.expected.Int()
+
+	default:
+		log.Fatalf("Unit Test Fatal Error: %s is not supported by a ByteCache\n", op.method)
+	}
+
+	if fail {
+		t.Errorf(operationFailMessage, op.method, op.args, op.expected, Expected{result})
+	}
+}
+
+// ExecuteCacheOperations is ExecuteOperations for any ByteCache
+func ExecuteCacheOperations(t *testing.T, c ByteCache, ops []Operation) {
+	for _, op := range ops {
+		RunOperation(t, op, func(t *testing.T) {
+			ExecuteCacheOperation(t, c, op)
+		})
+	}
+}
+
+/******************************************************************************
+ *                             Byte cache tests
+ ******************************************************************************/
+
+func TestByteCacheBasic(t *testing.T) {
+	// desc := "Check that a byte cache stores bindings like an LRU"
+	limit := 1024
+	c := NewByteCache(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Max, limit),
+		NewOp(Remaining, limit),
+		NewOp(Get, "k1", &Record{nil, false}),
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "", b(""), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "", &Record{b(""), true}),
+		NewOp(Len, 2),
+		NewOp(Remaining, limit-4),
+		NewOp(Set, "k1", b("v11"), true),
+		NewOp(Remaining, limit-5),
+		NewOp(Remove, "k1", &Record{b("v11"), true}),
+		NewOp(Remove, "k1", &Record{nil, false}),
+		NewOp(Len, 1),
+		NewOp(Set, "toolarge", make([]byte, limit), false),
+	})
+}
+
+func TestByteCacheEvictionOrder(t *testing.T) {
+	// desc := "Check that a byte cache evicts the least recently used binding"
+	limit := 12 // room for 3 bindings
+	ops := []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "k4", b("v4"), true), // evicts k2
+		NewOp(Get, "k2", &Record{nil, false}),
+		NewOp(Set, "k3", b("v33"), true), // evicts k1
+		NewOp(Get, "k1", &Record{nil, false}),
+		NewOp(Len, 2),
+		NewOp(Remaining, 3),
+	}
+
+	// An LRU and a byte cache must agree on every operation
+	t.Run("LRU", func(t *testing.T) {
+		ExecuteCacheOperations(t, NewLru(limit), ops)
+	})
+	t.Run("Cache", func(t *testing.T) {
+		ExecuteCacheOperations(t, NewByteCache(limit), ops)
+	})
+}
+
+/******************************************************************************
+ *                             Typed cache tests
+ ******************************************************************************/
+
+// point is a struct value for exercising caches of non-byte types
+type point struct {
+	x, y int
+}
+
+// pointSize charges 8 bytes each for an int key and the two fields of a point
+func pointSize(key int, val point) int {
+	return 8 * 3
+}
+
+// CheckCacheGet fails the test unless c.Get(key) returns the expected result
+func CheckCacheGet[K comparable, V comparable](t *testing.T, c *Cache[K, V], key K, expected V, expectedOk bool) {
+	got, ok := c.Get(key)
+	if got != expected || ok != expectedOk {
+		t.Errorf(operationFailMessage, "Get", fmt.Sprint(key),
+			fmt.Sprint(expected, expectedOk), fmt.Sprint(got, ok))
+	}
+}
+
+func TestCacheTypedBasic(t *testing.T) {
+	// desc := "Check that a cache stores keys and values of other types"
+	limit := 24 * 4
+	c := NewCache(limit, pointSize)
+
+	CheckCacheGet(t, c, 1, point{}, false)
+	for i := 0; i < 3; i++ {
+		if !c.Set(i, point{i, -i}) {
+			t.Errorf(operationFailMessage, "Set", fmt.Sprint(i, point{i, -i}), Expected{true}, Expected{false})
+		}
+	}
+	CheckCacheGet(t, c, 1, point{1, -1}, true)
+	if rem := c.RemainingStorage(); rem != limit-3*24 {
+		t.Errorf(operationFailMessage, "RemainingStorage", "", Expected{limit - 3*24}, Expected{rem})
+	}
+	if val, ok := c.Remove(2); val != (point{2, -2}) || !ok {
+		t.Errorf(operationFailMessage, "Remove", "2", Expected{point{2, -2}}, Expected{val})
+	}
+	CheckCacheGet(t, c, 2, point{}, false)
+	if n := c.Len(); n != 2 {
+		t.Errorf(operationFailMessage, "Len", "", Expected{2}, Expected{n})
+	}
+}
+
+func TestCacheTypedEviction(t *testing.T) {
+	// desc := "Check that a cache of other types evicts in LRU order"
+	limit := 24 * 3 // room for 3 bindings
+	c := NewCache(limit, pointSize)
+
+	c.Set(1, point{1, 1})
+	c.Set(2, point{2, 2})
+	c.Set(3, point{3, 3})
+	CheckCacheGet(t, c, 1, point{1, 1}, true)
+	c.Set(4, point{4, 4}) // evicts 2
+
+	CheckCacheGet(t, c, 2, point{}, false)
+	CheckCacheGet(t, c, 3, point{3, 3}, true)
+	CheckCacheGet(t, c, 1, point{1, 1}, true)
+	CheckCacheGet(t, c, 4, point{4, 4}, true)
+}
+
+func TestCacheSizeFunc(t *testing.T) {
+	// desc := "Check that a cache charges each binding what its SizeFunc returns"
+	limit := 10
+	c := NewCache(limit, func(key string, val int) int { return val })
+
+	CheckCacheGet(t, c, "zero", 0, false)
+	for _, b := range []struct {
+		key string
+		val int
+		ok  bool
+	}{{"a", 4, true}, {"b", 5, true}, {"c", 11, false}, {"zero", 0, true}, {"d", 3, true}} {
+		if ok := c.Set(b.key, b.val); ok != b.ok {
+			t.Errorf(operationFailMessage, "Set", fmt.Sprint(b.key, b.val), Expected{b.ok}, Expected{ok})
+		}
+	}
+
+	// d only fits once a is evicted
+	CheckCacheGet(t, c, "a", 0, false)
+	CheckCacheGet(t, c, "zero", 0, true)
+	if rem := c.RemainingStorage(); rem != 2 {
+		t.Errorf(operationFailMessage, "RemainingStorage", "", Expected{2}, Expected{rem})
+	}
+}
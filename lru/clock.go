@@ -0,0 +1,34 @@
+package lru
+
+// ClockPolicy approximates LRU with the CLOCK (second-chance) algorithm: a
+// hand sweeps a ring of bindings, sparing and clearing the reference bit of
+// each binding used since the hand last passed, and evicting the first
+// binding it finds unused
+type ClockPolicy struct {
+	// whatever fields you want here
+}
+
+func NewClockPolicy() *ClockPolicy {
+	return new(ClockPolicy)
+}
+
+// NewClock returns a new CLOCK cache with capacity to store limit bytes
+func NewClock(limit int) *PolicyCache {
+	return NewPolicyCache(limit, NewClockPolicy())
+}
+
+func (p *ClockPolicy) Add(key string, size int) {
+}
+
+func (p *ClockPolicy) Update(key string, size int) {
+}
+
+func (p *ClockPolicy) Access(key string) {
+}
+
+func (p *ClockPolicy) Remove(key string) {
+}
+
+func (p *ClockPolicy) Evict() string {
+	return ""
+}
@@ -0,0 +1,124 @@
+package lru
+
+import "testing"
+
+/******************************************************************************
+ *                             CLOCK tests
+ ******************************************************************************/
+
+// clockAndLru are the policies compared by traces distinguishing CLOCK from LRU
+var clockAndLru = map[string]func() Policy{
+	"CLOCK": func() Policy { return NewClockPolicy() },
+	"LRU":   func() Policy { return NewLruPolicy() },
+}
+
+func TestClockUnreferencedIsFifo(t *testing.T) {
+	// desc := "Check that CLOCK evicts in insertion order when nothing is accessed"
+	limit := 12 // room for 3 bindings
+	c := NewClock(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true), // evicts k1
+		NewOp(Set, "k5", b("v5"), true), // evicts k2
+		NewOp(Set, "k6", b("v6"), true), // evicts k3
+		NewOp(Set, "k7", b("v7"), true), // evicts k4
+	})
+	CheckResident(t, c, []string{"k5", "k6", "k7"}, []string{"k1", "k2", "k3", "k4"})
+}
+
+func TestClockSecondChance(t *testing.T) {
+	// desc := "Check that an accessed binding is spared once, but not twice"
+	ops := []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "k4", b("v4"), true), // both evict k2
+		NewOp(Set, "k5", b("v5"), true), // both evict k3
+		NewOp(Set, "k6", b("v6"), true), // CLOCK evicts k4, LRU evicts k1
+	}
+	RunPolicyTrace(t, PolicyTrace{
+		limit: 12, // room for 3 bindings
+		ops:   ops,
+		present: map[string][]string{
+			"CLOCK": {"k1", "k5", "k6"},
+			"LRU":   {"k4", "k5", "k6"},
+		},
+		absent: map[string][]string{
+			"CLOCK": {"k2", "k3", "k4"},
+			"LRU":   {"k1", "k2", "k3"},
+		},
+	}, clockAndLru)
+
+	// k1's reference bit was cleared as the hand passed it, so it goes next
+	ops = append(ops, NewOp(Set, "k7", b("v7"), true))
+	RunPolicyTrace(t, PolicyTrace{
+		limit: 12,
+		ops:   ops,
+		present: map[string][]string{
+			"CLOCK": {"k5", "k6", "k7"},
+			"LRU":   {"k5", "k6", "k7"},
+		},
+		absent: map[string][]string{
+			"CLOCK": {"k1", "k4"},
+			"LRU":   {"k1", "k4"},
+		},
+	}, clockAndLru)
+}
+
+func TestClockFullSweep(t *testing.T) {
+	// desc := "Check that when every binding is referenced, CLOCK evicts where the hand started"
+	limit := 12 // room for 3 bindings
+	c := NewClock(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Get, "k3", &Record{b("v3"), true}),
+		NewOp(Get, "k2", &Record{b("v2"), true}),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "k4", b("v4"), true), // clears every bit, then evicts k1
+		NewOp(Set, "k5", b("v5"), true), // evicts k2
+	})
+	CheckResident(t, c, []string{"k3", "k4", "k5"}, []string{"k1", "k2"})
+}
+
+func TestClockUpdateReferences(t *testing.T) {
+	// desc := "Check that overwriting a binding sets its reference bit"
+	limit := 12 // room for 3 bindings
+	c := NewClock(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k1", b("11"), true),
+		NewOp(Set, "k4", b("v4"), true), // spares k1, evicts k2
+	})
+	CheckResident(t, c, []string{"k1", "k3", "k4"}, []string{"k2"})
+}
+
+func TestClockRemoveAtHand(t *testing.T) {
+	// desc := "Check that removing the binding under the hand moves the hand on"
+	limit := 12 // room for 3 bindings
+	c := NewClock(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Remove, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Set, "k5", b("v5"), true), // evicts k2
+		NewOp(Set, "k6", b("v6"), true), // evicts k3
+	})
+	CheckResident(t, c, []string{"k4", "k5", "k6"}, []string{"k1", "k2", "k3"})
+}
+
+func BenchmarkPolicyZipfClock(b *testing.B) {
+	benchmarkPolicyZipf(b, NewClockPolicy())
+}
@@ -0,0 +1,212 @@
+package lru
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+/******************************************************************************
+ *                             Policy comparison
+ ******************************************************************************/
+// This file is provided for lecture demos and the policy analysis assignment.
+// It replays a trace on a PolicyCache for every registered policy, so it works
+// as soon as your PolicyCache and policies do.
+
+// Request is one use of a key in a trace: a Get, followed on a miss by a Set
+// binding the key to a value of Size bytes
+type Request struct {
+	Key  string
+	Size int
+}
+
+// PolicyFactory returns a new Policy for a cache with capacity to store limit
+// bytes. Policies that tell the time must use clock, which the replay advances
+// by one second per request so that results do not depend on the machine.
+type PolicyFactory func(limit int, clock Clock) Policy
+
+var policies = map[string]PolicyFactory{
+	"ARC":        func(limit int, _ Clock) Policy { return NewArcPolicy(limit) },
+	"CLOCK":      func(int, Clock) Policy { return NewClockPolicy() },
+	"FIFO":       func(int, Clock) Policy { return NewFifoPolicy() },
+	"GDS":        func(int, Clock) Policy { return NewGdsPolicy() },
+	"GDSF":       func(int, Clock) Policy { return NewGdsfPolicy() },
+	"Hyperbolic": func(_ int, clock Clock) Policy { return NewHyperbolicPolicy(clock, 8, 316) },
+	"LFU":        func(int, Clock) Policy { return NewLfuPolicy() },
+	"LIRS":       func(limit int, _ Clock) Policy { return NewLirsPolicy(limit) },
+	"LRU":        func(int, Clock) Policy { return NewLruPolicy() },
+	"MRU":        func(int, Clock) Policy { return NewMruPolicy() },
+	"Random":     func(int, Clock) Policy { return NewRandomPolicy(316) },
+	"S3-FIFO":    func(limit int, _ Clock) Policy { return NewS3FifoPolicy(limit) },
+	"SIEVE":      func(int, Clock) Policy { return NewSievePolicy() },
+	"SLRU":       func(limit int, _ Clock) Policy { return NewSlruPolicy(limit, 0.8) },
+}
+
+// RegisterPolicy adds a policy to those compared by ComparePolicies, replacing
+// any policy already registered under the same name
+func RegisterPolicy(name string, factory PolicyFactory) {
+	policies[name] = factory
+}
+
+// RegisteredPolicies returns the names of the registered policies, sorted
+func RegisteredPolicies() []string {
+	names := make([]string, 0, len(policies))
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PolicyResult summarises one policy's replay of a trace
+type PolicyResult struct {
+	Policy     string
+	Hits       int
+	Misses     int
+	Evictions  int
+	BytesMoved int // storage bound on misses, plus storage evicted
+}
+
+// HitRatio returns Hits / (Hits + Misses), or 0 for an empty trace
+func (r PolicyResult) HitRatio() float64 {
+	if r.Hits+r.Misses == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Hits+r.Misses)
+}
+
+// replayClock is the Clock given to policies during a replay
+type replayClock struct {
+	now time.Time
+}
+
+func (c *replayClock) Now() time.Time {
+	return c.now
+}
+
+// countingPolicy passes calls through to a Policy, counting its evictions
+type countingPolicy struct {
+	Policy
+	sizes     map[string]int
+	evictions int
+	evicted   int // bytes
+}
+
+func (p *countingPolicy) Add(key string, size int) {
+	p.sizes[key] = size
+	p.Policy.Add(key, size)
+}
+
+func (p *countingPolicy) Update(key string, size int) {
+	p.sizes[key] = size
+	p.Policy.Update(key, size)
+}
+
+func (p *countingPolicy) Remove(key string) {
+	delete(p.sizes, key)
+	p.Policy.Remove(key)
+}
+
+func (p *countingPolicy) Evict() string {
+	key := p.Policy.Evict()
+	p.evictions++
+	p.evicted += p.sizes[key]
+	delete(p.sizes, key)
+	return key
+}
+
+// ReplayPolicy replays trace on a new PolicyCache with capacity to store limit
+// bytes, evicting by a policy made by factory
+func ReplayPolicy(limit int, factory PolicyFactory, trace []Request) PolicyResult {
+	clock := &replayClock{time.Date(2020, time.February, 1, 0, 0, 0, 0, time.UTC)}
+	policy := &countingPolicy{Policy: factory(limit, clock), sizes: make(map[string]int)}
+	c := NewPolicyCache(limit, policy)
+
+	var res PolicyResult
+	for _, req := range trace {
+		clock.now = clock.now.Add(time.Second)
+		if _, ok := c.Get(req.Key); ok {
+			res.Hits++
+			continue
+		}
+		res.Misses++
+		if c.Set(req.Key, make([]byte, req.Size)) {
+			res.BytesMoved += len(req.Key) + req.Size
+		}
+	}
+	res.Evictions = policy.evictions
+	res.BytesMoved += policy.evicted
+	return res
+}
+
+// ComparePolicies replays trace once for every registered policy, on caches
+// with capacity to store limit bytes, and returns the results sorted by name
+func ComparePolicies(limit int, trace []Request) []PolicyResult {
+	var results []PolicyResult
+	for _, name := range RegisteredPolicies() {
+		res := ReplayPolicy(limit, policies[name], trace)
+		res.Policy = name
+		results = append(results, res)
+	}
+	return results
+}
+
+// ReadTrace reads a trace with one request per line: a key, optionally
+// followed by whitespace and the size of its value (by default, the length of
+// the key). Blank lines and lines starting with # are ignored.
+func ReadTrace(r io.Reader) ([]Request, error) {
+	var trace []Request
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		req := Request{Key: fields[0], Size: len(fields[0])}
+		switch len(fields) {
+		case 1:
+		case 2:
+			size, err := strconv.Atoi(fields[1])
+			if err != nil || size < 0 {
+				return nil, fmt.Errorf("line %d: bad size %q", line, fields[1])
+			}
+			req.Size = size
+		default:
+			return nil, fmt.Errorf("line %d: expected a key and an optional size", line)
+		}
+		trace = append(trace, req)
+	}
+	return trace, scanner.Err()
+}
+
+// RenderComparison writes results to w, as either a markdown table for
+// publishing or aligned plain text
+func RenderComparison(w io.Writer, results []PolicyResult, markdown bool) error {
+	row := "%s\t%.2f\t%d\t%d\t%d\t\n"
+	header := "Policy\thit%\tHits\tEvictions\tBytes moved\t\n"
+	if markdown {
+		row = "| %s | %.2f | %d | %d | %d |\n"
+		header = "| Policy | hit% | Hits | Evictions | Bytes moved |\n|---|---:|---:|---:|---:|\n"
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	out := io.Writer(tw)
+	if markdown {
+		out = w
+	}
+
+	fmt.Fprint(out, header)
+	for _, r := range results {
+		fmt.Fprintf(out, row, r.Policy, 100*r.HitRatio(), r.Hits, r.Evictions, r.BytesMoved)
+	}
+
+	if markdown {
+		return nil
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,163 @@
+package lru
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Policy comparison tests
+ ******************************************************************************/
+// TestCompareTrace doubles as the comparison tool used in lecture, printing a
+// table for the trace it is given:
+//
+//	go test -run CompareTrace -lru.compare-trace trace.txt -lru.compare-limit 4096
+
+var compareTrace = flag.String("lru.compare-trace", "",
+	"trace file for TestCompareTrace to replay across every registered policy")
+
+var compareLimit = flag.Int("lru.compare-limit", 1<<20,
+	"capacity in bytes of the caches TestCompareTrace replays on")
+
+var compareMarkdown = flag.Bool("lru.compare-markdown", false,
+	"print TestCompareTrace's table in markdown")
+
+// requests returns a trace using each of keys in turn, with 2-byte values
+func requests(keys ...string) []Request {
+	trace := make([]Request, len(keys))
+	for i, key := range keys {
+		trace[i] = Request{key, 2}
+	}
+	return trace
+}
+
+// CheckPolicyResult asserts that a replay produced exp
+func CheckPolicyResult(t *testing.T, name string, got, exp PolicyResult) {
+	if got != exp {
+		t.Errorf("Replaying on %s, expected %+v, found %+v", name, exp, got)
+	}
+}
+
+func TestReplayPolicy(t *testing.T) {
+	// desc := "Check that a replay counts hits, misses, evictions and bytes moved"
+	limit := 12 // room for 3 bindings
+	trace := append(requests("k1", "k2", "k3", "k1", "k4", "k5", "k1"), Request{"k6", 20})
+
+	// LRU evicts k2 and k3; FIFO evicts k1, k2 and k3. Every binding uses 4
+	// bytes, except k6, which never fits.
+	lru := ReplayPolicy(limit, policies["LRU"], trace)
+	CheckPolicyResult(t, "LRU", lru, PolicyResult{Hits: 2, Misses: 6, Evictions: 2, BytesMoved: 28})
+	fifo := ReplayPolicy(limit, policies["FIFO"], trace)
+	CheckPolicyResult(t, "FIFO", fifo, PolicyResult{Hits: 1, Misses: 7, Evictions: 3, BytesMoved: 36})
+
+	if ratio := lru.HitRatio(); ratio != 0.25 {
+		t.Errorf("Expected LRU's hit ratio to be 0.25, found %v", ratio)
+	}
+	if ratio := (PolicyResult{}).HitRatio(); ratio != 0 {
+		t.Errorf("Expected an empty trace's hit ratio to be 0, found %v", ratio)
+	}
+}
+
+func TestComparePolicies(t *testing.T) {
+	// desc := "Check that every registered policy is compared, in order of name"
+	RegisterPolicy("LRU again", policies["LRU"])
+	defer delete(policies, "LRU again")
+
+	trace := requests("k1", "k2", "k3", "k1", "k4", "k5", "k1")
+	results := ComparePolicies(12, trace)
+
+	names := RegisteredPolicies()
+	if len(results) != len(names) {
+		t.Fatalf("Expected %d results, found %d", len(names), len(results))
+	}
+	for i, res := range results {
+		if res.Policy != names[i] {
+			t.Errorf("Result %d: expected policy %s, found %s", i, names[i], res.Policy)
+		}
+		if res.Hits+res.Misses != len(trace) {
+			t.Errorf("%s: expected %d Gets, found %d", res.Policy, len(trace), res.Hits+res.Misses)
+		}
+		if res.Policy == "LRU again" {
+			CheckPolicyResult(t, res.Policy, res, PolicyResult{"LRU again", 2, 5, 2, 28})
+		}
+	}
+}
+
+func TestReadTrace(t *testing.T) {
+	input := "# a comment\nk1\n\nk2 10\n  k3\t0  \n"
+	trace, err := ReadTrace(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Request{{"k1", 2}, {"k2", 10}, {"k3", 0}}
+	if len(trace) != len(expected) {
+		t.Fatalf("Expected %v, found %v", expected, trace)
+	}
+	for i := range expected {
+		if trace[i] != expected[i] {
+			t.Errorf("Request %d: expected %v, found %v", i, expected[i], trace[i])
+		}
+	}
+
+	for _, bad := range []string{"k1 ten\n", "k1 -1\n", "k1 1 2\n"} {
+		if _, err := ReadTrace(strings.NewReader(bad)); err == nil {
+			t.Errorf("Expected an error reading %q", bad)
+		}
+	}
+}
+
+func TestRenderComparisonMarkdown(t *testing.T) {
+	results := []PolicyResult{{"FIFO", 1, 3, 2, 24}, {"LRU", 0, 0, 0, 0}}
+
+	var buf bytes.Buffer
+	if err := RenderComparison(&buf, results, true); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "| Policy | hit% | Hits | Evictions | Bytes moved |\n" +
+		"|---|---:|---:|---:|---:|\n" +
+		"| FIFO | 25.00 | 1 | 2 | 24 |\n" +
+		"| LRU | 0.00 | 0 | 0 | 0 |\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nFound:\n%s", expected, buf.String())
+	}
+}
+
+func TestCompareTrace(t *testing.T) {
+	if *compareTrace == "" {
+		t.Skip("no -lru.compare-trace given")
+	}
+
+	f, err := os.Open(*compareTrace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	s, err := ReadScenario(f)
+	if err != nil {
+		t.Fatalf("%s: %v", *compareTrace, err)
+	}
+	results := ComparePolicies(*compareLimit, s.Trace)
+
+	// A trace that targets a constructor, or -lru.constructor, adds a row for
+	// that constructor's cache
+	if *constructor != "" {
+		s.Constructor = *constructor
+	}
+	if s.Constructor != "" {
+		factory, err := LookupConstructor(s.Constructor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := ReplayCache(*compareLimit, factory, s.Trace)
+		res.Policy = s.Constructor
+		results = append(results, res)
+	}
+	if err := RenderComparison(os.Stdout, results, *compareMarkdown); err != nil {
+		t.Fatal(err)
+	}
+}
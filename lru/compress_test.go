@@ -0,0 +1,131 @@
+package lru
+
+import (
+	"bytes"
+	"compress/gzip"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Compression tests
+ ******************************************************************************/
+
+// gzipped returns value compressed as an LRU with compression compresses it
+func gzipped(value []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(value)
+	w.Close()
+	return buf.Bytes()
+}
+
+// repeated returns a highly compressible value of n bytes
+func repeated(n int) []byte {
+	return b(strings.Repeat("a", n))
+}
+
+// incompressible returns a value of n random bytes
+func incompressible(n int) []byte {
+	val := make([]byte, n)
+	rand.New(rand.NewSource(316)).Read(val)
+	return val
+}
+
+func TestCompressTransparent(t *testing.T) {
+	// desc := "Check that compressed values are returned exactly as they were set"
+	limit := 1024
+	lru := NewLruWithOptions(limit, Options{CompressAbove: 16})
+	val := repeated(200)
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", val, true),
+		NewOp(Get, "k1", &Record{val, true}),
+		NewOp(Peek, "k1", &Record{val, true}),
+		NewOp(Remaining, limit-len("k1")-len(gzipped(val))),
+		NewOp(Remove, "k1", &Record{val, true}),
+		NewOp(Remaining, limit),
+	})
+}
+
+func TestCompressThreshold(t *testing.T) {
+	// desc := "Check that only values longer than the threshold, that shrink, are compressed"
+	limit := 1024
+	lru := NewLruWithOptions(limit, Options{CompressAbove: 64})
+	short, random := repeated(64), incompressible(100)
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", short, true),
+		NewOp(Remaining, limit-len("k1")-len(short)),
+		NewOp(Set, "k2", random, true),
+		NewOp(Remaining, limit-len("k1k2")-len(short)-len(random)),
+		NewOp(Get, "k2", &Record{random, true}),
+	})
+}
+
+func TestCompressEvictionPressure(t *testing.T) {
+	// desc := "Check that compression lets more compressible bindings fit before evicting"
+	limit := 256
+	plain := NewLru(limit)
+	compressed := NewLruWithOptions(limit, Options{CompressAbove: 16})
+	keys := []string{"k1", "k2", "k3", "k4", "k5"}
+	val := repeated(100)
+
+	var evictions int
+	compressed.SetEvictedCallback(func(string, []byte) { evictions++ })
+	for _, key := range keys {
+		plain.Set(key, val)
+		compressed.Set(key, val)
+	}
+	ExecuteOperations(t, plain, []Operation{NewOp(Len, 2)})
+	ExecuteOperations(t, compressed, []Operation{
+		NewOp(Len, len(keys)),
+		NewOp(Remaining, limit-len(keys)*(2+len(gzipped(val)))),
+	})
+	if evictions != 0 {
+		t.Errorf("Expected no evictions with compression, found %d", evictions)
+	}
+
+	// Incompressible values get no such help
+	random := NewLruWithOptions(limit, Options{CompressAbove: 16})
+	for _, key := range keys {
+		random.Set(key, incompressible(100))
+	}
+	ExecuteOperations(t, random, []Operation{NewOp(Len, 2)})
+
+	// A value too large to store plainly may fit compressed
+	ExecuteOperations(t, plain, []Operation{NewOp(Set, "big", repeated(1000), false)})
+	ExecuteOperations(t, compressed, []Operation{NewOp(Set, "big", repeated(1000), true)})
+}
+
+func TestCompressOperations(t *testing.T) {
+	// desc := "Check that methods that read or change values see them uncompressed"
+	limit := 1024
+	lru := NewLruWithOptions(limit, Options{CompressAbove: 16, MaxValueSize: 300})
+	val := repeated(200)
+	more := repeated(250)
+
+	var evicted []byte
+	lru.SetEvictedCallback(func(key string, val []byte) { evicted = val })
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", val, true),
+		NewOp(Set, "k2", repeated(301), false), // MaxValueSize applies before compression
+	})
+	if !lru.CAS("k1", val, repeated(210)) {
+		t.Errorf(operationFailMessage, "CAS", "\"k1\", ...", Expected{true}, Expected{false})
+	}
+	if !lru.Append("k1", repeated(40)) {
+		t.Errorf(operationFailMessage, "Append", "\"k1\", ...", Expected{true}, Expected{false})
+	}
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Peek, "k1", &Record{more, true}),
+		NewOp(Remaining, limit-len("k1")-len(gzipped(more))),
+	})
+
+	lru.Resize(0)
+	if !bytes.Equal(evicted, more) {
+		t.Errorf("Expected the eviction callback to get %d bytes of 'a', found %q", len(more), evicted)
+	}
+}
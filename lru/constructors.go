@@ -0,0 +1,119 @@
+package lru
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+/******************************************************************************
+ *                             Constructor registry
+ ******************************************************************************/
+// This file is provided for grading. It names the constructors of every cache
+// in the assignment, so that one harness can run the same scenario against any
+// part: a trace file or the -lru.constructor test flag says which to build.
+
+// CacheFactory returns a new ByteCache with capacity to store limit bytes
+type CacheFactory func(limit int) ByteCache
+
+var constructors = map[string]CacheFactory{
+	"NewByteCache": func(limit int) ByteCache { return NewByteCache(limit) },
+	"NewFifo":      func(limit int) ByteCache { return NewFifo(limit) },
+	"NewLru":       func(limit int) ByteCache { return NewLru(limit) },
+	"NewSyncLru":   func(limit int) ByteCache { return NewSyncLru(NewLru(limit)) },
+	"NewStripedLru": func(limit int) ByteCache {
+		return NewStripedLru(limit, 8, func(limit int) ByteCache { return NewLru(limit) })
+	},
+}
+
+// RegisterConstructor makes factory available to graders under name, replacing
+// any constructor already registered under that name
+func RegisterConstructor(name string, factory CacheFactory) {
+	constructors[name] = factory
+}
+
+// RegisteredConstructors returns the names of the registered constructors,
+// sorted
+func RegisteredConstructors() []string {
+	names := make([]string, 0, len(constructors))
+	for name := range constructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupConstructor returns the constructor registered under name
+func LookupConstructor(name string) (CacheFactory, error) {
+	factory, ok := constructors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown constructor %q (have %s)",
+			name, strings.Join(RegisteredConstructors(), ", "))
+	}
+	return factory, nil
+}
+
+// Scenario is a trace, together with the constructor it targets
+type Scenario struct {
+	Constructor string // "" if the trace names none
+	Trace       []Request
+}
+
+// constructorDirective starts the comment line naming a scenario's constructor
+const constructorDirective = "# constructor:"
+
+// ReadScenario reads a trace in the format of ReadTrace, in which a comment of
+// the form "# constructor: NewFifo" names the constructor the trace targets
+func ReadScenario(r io.Reader) (Scenario, error) {
+	var s Scenario
+	var lines strings.Builder
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(text, constructorDirective) {
+			name := strings.TrimSpace(strings.TrimPrefix(text, constructorDirective))
+			if _, err := LookupConstructor(name); err != nil {
+				return Scenario{}, fmt.Errorf("line %d: %v", line, err)
+			}
+			s.Constructor = name
+		}
+		// keep every line, so that ReadTrace reports the same line numbers
+		lines.WriteString(text)
+		lines.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return Scenario{}, err
+	}
+
+	trace, err := ReadTrace(strings.NewReader(lines.String()))
+	if err != nil {
+		return Scenario{}, err
+	}
+	s.Trace = trace
+	return s, nil
+}
+
+// ReplayCache replays trace on a new ByteCache made by factory, with capacity
+// to store limit bytes. A ByteCache does not report its evictions, so they are
+// inferred from the changes in its length and remaining storage on each Set.
+func ReplayCache(limit int, factory CacheFactory, trace []Request) PolicyResult {
+	c := factory(limit)
+
+	var res PolicyResult
+	for _, req := range trace {
+		if _, ok := c.Get(req.Key); ok {
+			res.Hits++
+			continue
+		}
+		res.Misses++
+		length, remaining := c.Len(), c.RemainingStorage()
+		if c.Set(req.Key, make([]byte, req.Size)) {
+			bound := len(req.Key) + req.Size
+			res.Evictions += length + 1 - c.Len()
+			res.BytesMoved += bound + c.RemainingStorage() - (remaining - bound)
+		}
+	}
+	return res
+}
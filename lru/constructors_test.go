@@ -0,0 +1,118 @@
+package lru
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Constructor registry tests
+ ******************************************************************************/
+// The tests here run against every registered constructor, or against the one
+// named on the command line, so that one harness grades every part:
+//
+//	go test -run Constructor -lru.constructor NewSyncLru
+
+var constructor = flag.String("lru.constructor", "",
+	"constructor the constructor tests target (by default, every registered one)")
+
+// ForEachConstructor runs test once for the constructor named by
+// -lru.constructor, or else once for every registered constructor
+func ForEachConstructor(t *testing.T, test func(t *testing.T, factory CacheFactory)) {
+	names := RegisteredConstructors()
+	if *constructor != "" {
+		names = []string{*constructor}
+	}
+	for _, name := range names {
+		factory, err := LookupConstructor(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(name, func(t *testing.T) {
+			test(t, factory)
+		})
+	}
+}
+
+func TestConstructorBasic(t *testing.T) {
+	// desc := "Check that every constructor's cache stores, replaces and removes bindings"
+	limit := 1024
+	ForEachConstructor(t, func(t *testing.T, factory CacheFactory) {
+		ExecuteCacheOperations(t, factory(limit), []Operation{
+			NewOp(Max, limit),
+			NewOp(Remaining, limit),
+			NewOp(Get, "k1", &Record{nil, false}),
+			NewOp(Set, "k1", b("v1"), true),
+			NewOp(Set, "", b(""), true),
+			NewOp(Get, "k1", &Record{b("v1"), true}),
+			NewOp(Get, "", &Record{b(""), true}),
+			NewOp(Len, 2),
+			NewOp(Remaining, limit-4),
+			NewOp(Set, "k1", b("v11"), true),
+			NewOp(Remaining, limit-5),
+			NewOp(Remove, "k1", &Record{b("v11"), true}),
+			NewOp(Remove, "k1", &Record{nil, false}),
+			NewOp(Len, 1),
+			NewOp(Set, "toolarge", make([]byte, limit), false),
+		})
+	})
+}
+
+func TestLookupConstructor(t *testing.T) {
+	// desc := "Check that constructors are found by name, and unknown names are errors"
+	RegisterConstructor("NewLru again", constructors["NewLru"])
+	defer delete(constructors, "NewLru again")
+
+	for _, name := range []string{"NewLru", "NewSyncLru", "NewFifo", "NewLru again"} {
+		if _, err := LookupConstructor(name); err != nil {
+			t.Errorf("LookupConstructor(%q): %v", name, err)
+		}
+	}
+
+	_, err := LookupConstructor("NewLruN")
+	if err == nil {
+		t.Fatal("Expected an error looking up an unknown constructor")
+	}
+	if !strings.Contains(err.Error(), "NewLru again") {
+		t.Errorf("Expected the error to name the registered constructors, found %q", err)
+	}
+}
+
+func TestReadScenario(t *testing.T) {
+	// desc := "Check that a trace file names the constructor it targets"
+	input := "# a comment\n  # constructor: NewFifo\nk1\nk2 10\n"
+	s, err := ReadScenario(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Constructor != "NewFifo" {
+		t.Errorf("Expected constructor NewFifo, found %q", s.Constructor)
+	}
+	if len(s.Trace) != 2 || s.Trace[1] != (Request{"k2", 10}) {
+		t.Errorf("Expected [{k1 2} {k2 10}], found %v", s.Trace)
+	}
+
+	s, err = ReadScenario(strings.NewReader("k1\n"))
+	if err != nil || s.Constructor != "" {
+		t.Errorf("Expected no constructor and no error, found %q and %v", s.Constructor, err)
+	}
+
+	for _, bad := range []string{"# constructor: NewLruN\n", "k1\nk1 ten\n"} {
+		if _, err := ReadScenario(strings.NewReader(bad)); err == nil {
+			t.Errorf("Expected an error reading %q", bad)
+		}
+	}
+}
+
+func TestReplayCache(t *testing.T) {
+	// desc := "Check that replaying on a cache counts what replaying on its policy does"
+	limit := 12 // room for 3 bindings
+	trace := append(requests("k1", "k2", "k3", "k1", "k4", "k5", "k1"), Request{"k6", 20})
+
+	for _, pair := range [][2]string{{"NewLru", "LRU"}, {"NewFifo", "FIFO"}} {
+		got := ReplayCache(limit, constructors[pair[0]], trace)
+		exp := ReplayPolicy(limit, policies[pair[1]], trace)
+		CheckPolicyResult(t, pair[0], got, exp)
+	}
+}
@@ -0,0 +1,191 @@
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Copy tests
+ ******************************************************************************/
+
+// CheckAliases fails the test unless changing the slice passed to Set changes
+// the bound value exactly when write is true, and changing the slice returned
+// by Get does exactly when read is true
+func CheckAliases(t *testing.T, lru *LRU, write, read bool) {
+	val := b("value")
+	lru.Set("key", val)
+	val[0] = 'V'
+	got, _ := lru.Peek("key")
+	if aliased := string(got) == "Value"; aliased != write {
+		t.Errorf("Changing the slice passed to Set: expected bound value aliased %v, found %v (%q)",
+			write, aliased, got)
+	}
+
+	lru.Set("key", b("value"))
+	got, ok := lru.Get("key")
+	if !ok || len(got) == 0 {
+		t.Errorf(operationFailMessage, Get, &Args{[]interface{}{"key"}},
+			Expected{&Record{b("value"), true}}, Expected{&Record{got, ok}})
+		return
+	}
+	got[0] = 'V'
+	again, _ := lru.Get("key")
+	if aliased := string(again) == "Value"; aliased != read {
+		t.Errorf("Changing the slice returned by Get: expected bound value aliased %v, found %v (%q)",
+			read, aliased, again)
+	}
+}
+
+func TestCopyDefault(t *testing.T) {
+	// desc := "Check that by default the LRU neither copies stored nor returned values"
+	CheckAliases(t, NewLru(64), true, true)
+	CheckAliases(t, NewLruWithOptions(64, Options{}), true, true)
+}
+
+func TestCopyOnWrite(t *testing.T) {
+	// desc := "Check that CopyOnWrite keeps callers from changing stored values"
+	CheckAliases(t, NewLruWithOptions(64, Options{CopyOnWrite: true}), false, true)
+}
+
+func TestCopyOnRead(t *testing.T) {
+	// desc := "Check that CopyOnRead keeps callers from changing returned values"
+	CheckAliases(t, NewLruWithOptions(64, Options{CopyOnRead: true}), true, false)
+}
+
+func TestCopyBoth(t *testing.T) {
+	// desc := "Check that with both options, stored values never change under the LRU"
+	lru := NewLruWithOptions(64, Options{CopyOnWrite: true, CopyOnRead: true})
+	CheckAliases(t, lru, false, false)
+
+	// The other ways in and out copy too
+	vals := [][]byte{b("v1"), b("v2")}
+	lru.SetMulti([]KeyValue{{"k1", vals[0]}, {"k2", vals[1]}}, false)
+	vals[0][0], vals[1][0] = 'V', 'V'
+	// Scribble on whatever comes back, however wrong; the Gets below catch
+	// anything missing
+	scribble := func(val []byte) {
+		if len(val) > 0 {
+			val[0] = 'V'
+		}
+	}
+	got, _ := lru.GetMulti([]string{"k1", "k2"})
+	for _, val := range got {
+		scribble(val)
+	}
+	lru.Range(func(key string, val []byte) bool {
+		scribble(val)
+		return true
+	})
+	peeked, _ := lru.Peek("k2")
+	scribble(peeked)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k2", &Record{b("v2"), true}),
+	})
+}
+
+func TestCopyStorage(t *testing.T) {
+	// desc := "Check that copies are not charged for"
+	for _, opts := range []Options{{}, {CopyOnWrite: true}, {CopyOnRead: true}, {CopyOnWrite: true, CopyOnRead: true}} {
+		t.Run(fmt.Sprintf("%+v", opts), func(t *testing.T) {
+			limit := 16
+			lru := NewLruWithOptions(limit, opts)
+			ExecuteOperations(t, lru, []Operation{
+				NewOp(Set, "k1", b("v1"), true),
+				NewOp(Set, "k2", b("v2"), true),
+				NewOp(Get, "k1", &Record{b("v1"), true}),
+				NewOp(Remaining, limit-8),
+				NewOp(Set, "k3", b("v3"), true),
+				NewOp(Set, "k4", b("v4"), true),
+				NewOp(Set, "k5", b("v5"), true), // evicts k2
+				NewOp(Peek, "k2", &Record{nil, false}),
+				NewOp(Remaining, 0),
+			})
+		})
+	}
+}
+
+// TestCopySpareCapacity passes Set slices with far more capacity than length,
+// then re-slices and appends to them through the shared backing array, as
+// callers reusing buffers do. Only the bytes within the slice's length belong
+// to the binding, so none of this may change the bound value or its storage.
+func TestCopySpareCapacity(t *testing.T) {
+	// desc := "Check that re-slicing and appending to a stored slice leaves the binding alone"
+	for _, opts := range []Options{{}, {CopyOnWrite: true}, {Arena: true}, {OffHeap: true}} {
+		t.Run(fmt.Sprintf("%+v", opts), func(t *testing.T) {
+			limit := 16
+			lru := NewLruWithOptions(limit, opts)
+
+			buf := make([]byte, 0, 1024)
+			v1 := append(buf, "v1"...)
+			v2 := append(v1[len(v1):], "v2"...) // the same backing array
+			ExecuteOperations(t, lru, []Operation{
+				NewOp(Set, "k1", v1, true),
+				NewOp(Set, "k2", v2, true),
+				NewOp(Remaining, limit-8),
+			})
+
+			// Neither appending past v1 nor re-slicing it over v2 reaches k1
+			_ = append(v1, "overwritten"...)
+			v1 = v1[:cap(v1)]
+			got, _ := lru.Get("k1")
+			_ = append(got, "clobbered"...)
+			ExecuteOperations(t, lru, []Operation{
+				NewOp(Get, "k1", &Record{b("v1"), true}),
+				NewOp(Set, "k3", b("v3"), true),
+				NewOp(Remaining, limit-12),
+			})
+
+			// Reusing the buffer for the next value must not change this one
+			// unless the LRU aliases the slices it stores
+			copy(v1, "XX")
+			expected := b("v1")
+			if !opts.CopyOnWrite && !opts.Arena && !opts.OffHeap {
+				expected = b("XX")
+			}
+			ExecuteOperations(t, lru, []Operation{
+				NewOp(Get, "k1", &Record{expected, true}),
+				NewOp(Remaining, limit-12),
+				NewOp(Len, 3),
+			})
+		})
+	}
+}
+
+// benchmarkCopy Sets and Gets 64-byte values in an LRU made with opts, to
+// show what the copies cost
+func benchmarkCopy(b *testing.B, opts Options) {
+	val := make([]byte, 64)
+	lru := NewLruWithOptions(1024*(64+8), opts)
+	keys := make([]string, 2048)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%04d", i)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := keys[i%len(keys)]
+		lru.Set(key, val)
+		if _, ok := lru.Get(key); !ok {
+			b.FailNow()
+		}
+	}
+}
+
+func BenchmarkCopyNone(b *testing.B) {
+	benchmarkCopy(b, Options{})
+}
+
+func BenchmarkCopyOnWrite(b *testing.B) {
+	benchmarkCopy(b, Options{CopyOnWrite: true})
+}
+
+func BenchmarkCopyOnRead(b *testing.B) {
+	benchmarkCopy(b, Options{CopyOnRead: true})
+}
+
+func BenchmarkCopyBoth(b *testing.B) {
+	benchmarkCopy(b, Options{CopyOnWrite: true, CopyOnRead: true})
+}
@@ -0,0 +1,5 @@
+package lru
+
+func (lru *LRU) SetEncryptionKey(key []byte) error {
+	return nil
+}
@@ -0,0 +1,145 @@
+package lru
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Encryption tests
+ ******************************************************************************/
+
+// sealOverhead is the storage AES-GCM adds to each value: a 12-byte nonce and
+// a 16-byte tag
+const sealOverhead = 12 + 16
+
+// testKey returns an AES key of n bytes
+func testKey(n int) []byte {
+	return bytes.Repeat([]byte{0x31}, n)
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	// desc := "Check that encrypted values are returned exactly as they were set"
+	limit := 1024
+	lru := NewLru(limit)
+	if err := lru.SetEncryptionKey(testKey(32)); err != nil {
+		t.Fatal(err)
+	}
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b(""), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Peek, "k2", &Record{b(""), true}),
+		NewOp(Remaining, limit-len("k1v1k2")-2*sealOverhead),
+		NewOp(Set, "k1", b("w1"), true),
+		NewOp(Remove, "k1", &Record{b("w1"), true}),
+		NewOp(Remaining, limit-len("k2")-sealOverhead),
+	})
+	lru.Set("k3", b("41"))
+	if n, err := lru.Incr("k3", 1); n != 42 || err != nil {
+		t.Errorf(operationFailMessage, "Incr", "\"k3\", 1",
+			Expected{42}, fmt.Sprintf("%d, %v", n, err))
+	}
+}
+
+func TestEncryptNoPlaintext(t *testing.T) {
+	// desc := "Check that the LRU never stores a value in plaintext"
+	lru := NewLru(1024)
+	secret := b("the secret value")
+	lru.Set("k1", secret) // before the key is set
+	if err := lru.SetEncryptionKey(testKey(16)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cost function sees every value exactly as the LRU stores it
+	var stored [][]byte
+	lru.SetCostFunc(func(key string, val []byte) int {
+		stored = append(stored, append([]byte(nil), val...))
+		return len(key) + len(val)
+	})
+	lru.Set("k2", secret)
+	lru.Append("k2", b("!"))
+	lru.Set("k3", secret)
+
+	if len(stored) < 4 {
+		t.Fatalf("Expected the cost function to see at least 4 values, found %d", len(stored))
+	}
+	for _, val := range stored {
+		if bytes.Contains(val, secret) {
+			t.Errorf("Found a value stored in plaintext: %q", val)
+		}
+	}
+
+	// The same value never encrypts the same way twice
+	last := len(stored) - 1
+	if bytes.Equal(stored[last], stored[0]) {
+		t.Errorf("Expected a fresh nonce for every value, found the same ciphertext twice")
+	}
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Peek, "k1", &Record{secret, true}),
+		NewOp(Peek, "k2", &Record{b("the secret value!"), true}),
+	})
+}
+
+func TestEncryptKeys(t *testing.T) {
+	// desc := "Check that changing or removing the key keeps every binding readable"
+	limit := 64
+	lru := NewLru(limit)
+	lru.Set("k1", b("v1"))
+
+	for _, n := range []int{0, 15, 33} {
+		if err := lru.SetEncryptionKey(testKey(n)); err == nil {
+			t.Errorf(operationFailMessage, "SetEncryptionKey", fmt.Sprintf("%d bytes", n),
+				Expected{"an error"}, Expected{err})
+		}
+	}
+	ExecuteOperations(t, lru, []Operation{NewOp(Remaining, limit-4)})
+
+	for _, key := range [][]byte{testKey(16), testKey(24), testKey(32), nil} {
+		if err := lru.SetEncryptionKey(key); err != nil {
+			t.Fatalf("SetEncryptionKey(%d bytes): %v", len(key), err)
+		}
+		overhead := sealOverhead
+		if key == nil {
+			overhead = 0
+		}
+		ExecuteOperations(t, lru, []Operation{
+			NewOp(Peek, "k1", &Record{b("v1"), true}),
+			NewOp(Remaining, limit-4-overhead),
+		})
+	}
+}
+
+func TestEncryptEvicts(t *testing.T) {
+	// desc := "Check that the storage encryption adds can evict bindings"
+	limit := 4 * (4 + sealOverhead)
+	lru := NewLru(limit)
+	for _, key := range []string{"k1", "k2", "k3", "k4", "k5", "k6"} {
+		lru.Set(key, b("v"+key[1:]))
+	}
+	lru.Get("k1")
+
+	if err := lru.SetEncryptionKey(testKey(32)); err != nil {
+		t.Fatal(err)
+	}
+	CheckKeys(t, lru, []string{"k4", "k5", "k6", "k1"})
+	ExecuteOperations(t, lru, []Operation{NewOp(Remaining, 0)})
+}
+
+func TestEncryptCompressed(t *testing.T) {
+	// desc := "Check that values are compressed before they are encrypted"
+	limit := 1024
+	lru := NewLruWithOptions(limit, Options{CompressAbove: 16})
+	if err := lru.SetEncryptionKey(testKey(32)); err != nil {
+		t.Fatal(err)
+	}
+	val := repeated(200)
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", val, true),
+		NewOp(Get, "k1", &Record{val, true}),
+		NewOp(Remaining, limit-len("k1")-len(gzipped(val))-sealOverhead),
+	})
+}
@@ -0,0 +1,46 @@
+package lru
+
+// EventType says what an Event reports
+type EventType int
+
+const (
+	EventSet    EventType = iota // a binding was stored
+	EventHit                     // a Get found a binding
+	EventMiss                    // a Get did not
+	EventEvict                   // a binding was passed to the eviction callback
+	EventRemove                  // a binding was removed with Remove
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventHit:
+		return "hit"
+	case EventMiss:
+		return "miss"
+	case EventEvict:
+		return "evict"
+	case EventRemove:
+		return "remove"
+	}
+	return "unknown"
+}
+
+// Event reports one operation on one binding
+type Event struct {
+	Type EventType
+	Key  string
+	Size int // storage charged for the binding, or 0 for a miss
+}
+
+// EventBuffer is the capacity of the channel returned by Events
+const EventBuffer = 256
+
+func (lru *LRU) Events() <-chan Event {
+	return nil
+}
+
+func (lru *LRU) DroppedEvents() int {
+	return 0
+}
@@ -0,0 +1,156 @@
+package lru
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+/******************************************************************************
+ *                               Event tests
+ ******************************************************************************/
+
+// drainEvents returns the events waiting on events, without blocking
+func drainEvents(events <-chan Event) []Event {
+	var got []Event
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return got
+			}
+			got = append(got, ev)
+		default:
+			return got
+		}
+	}
+}
+
+// CheckEvents asserts that exactly expected are waiting on events
+func CheckEvents(t *testing.T, events <-chan Event, expected []Event) {
+	t.Helper()
+	got := drainEvents(events)
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf(operationFailMessage, "Events", &Args{}, Expected{expected}, Expected{got})
+	}
+}
+
+func TestEventsOperations(t *testing.T) {
+	// desc := "Check that each kind of operation reports the documented event"
+	lru := NewLru(8)
+	events := lru.Events()
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k9", &Record{nil, false}),
+		NewOp(Peek, "k1", &Record{b("v1"), true}), // not reported
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true), // evicts k1
+		NewOp(Remove, "k2", &Record{b("v2"), true}),
+		NewOp(Remove, "k2", &Record{nil, false}),
+	})
+	CheckEvents(t, events, []Event{
+		{EventSet, "k1", 4},
+		{EventHit, "k1", 4},
+		{EventMiss, "k9", 0},
+		{EventSet, "k2", 4},
+		{EventSet, "k3", 4},
+		{EventEvict, "k1", 4},
+		{EventRemove, "k2", 4},
+	})
+
+	lru.GetMulti([]string{"k3", "k4"})
+	lru.Append("k3", b("3"))
+	lru.Purge()
+	CheckEvents(t, events, []Event{
+		{EventHit, "k3", 4},
+		{EventMiss, "k4", 0},
+		{EventSet, "k3", 5},
+		{EventEvict, "k3", 5},
+	})
+	if lru.DroppedEvents() != 0 {
+		t.Errorf(operationFailMessage, "DroppedEvents", &Args{}, Expected{0}, Expected{lru.DroppedEvents()})
+	}
+}
+
+func TestEventsSubscription(t *testing.T) {
+	// desc := "Check that events are only reported once Events is called, always on the same channel"
+	lru := NewLru(64)
+	lru.Set("k1", b("v1"))
+	lru.Get("k1")
+
+	events := lru.Events()
+	if lru.Events() != events {
+		t.Errorf("Expected every call to Events to return the same channel")
+	}
+	if cap(events) != EventBuffer {
+		t.Errorf("Expected the channel to have capacity %d, found %d", EventBuffer, cap(events))
+	}
+	CheckEvents(t, events, nil)
+
+	lru.Get("k1")
+	CheckEvents(t, events, []Event{{EventHit, "k1", 4}})
+}
+
+func TestEventsBackpressure(t *testing.T) {
+	// desc := "Check that a full channel drops and counts events rather than blocking"
+	lru := NewLru(1 << 20)
+	events := lru.Events()
+	extra := 10
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < EventBuffer+extra; i++ {
+			lru.Set(fmt.Sprintf("k%d", i), b("v"))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Set blocked on a full event channel")
+	}
+
+	if lru.DroppedEvents() != extra {
+		t.Errorf(operationFailMessage, "DroppedEvents", &Args{}, Expected{extra}, Expected{lru.DroppedEvents()})
+	}
+	got := drainEvents(events)
+	if len(got) != EventBuffer || got[0].Key != "k0" || got[EventBuffer-1].Key != fmt.Sprintf("k%d", EventBuffer-1) {
+		t.Errorf("Expected the first %d events to be kept, found %d", EventBuffer, len(got))
+	}
+
+	// Once there is room again, events are reported again
+	lru.Get("k0")
+	CheckEvents(t, events, []Event{{EventHit, "k0", 3}})
+	if lru.DroppedEvents() != extra {
+		t.Errorf(operationFailMessage, "DroppedEvents", &Args{}, Expected{extra}, Expected{lru.DroppedEvents()})
+	}
+}
+
+func TestEventsClose(t *testing.T) {
+	// desc := "Check that Close closes the channel after the events already sent"
+	lru := NewLru(64)
+	events := lru.Events()
+	lru.Set("k1", b("v1"))
+	lru.Close()
+
+	var got []Event
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev, ok := <-events:
+			if ok {
+				got = append(got, ev)
+				continue
+			}
+		case <-timeout:
+			t.Fatalf("Expected Close to close the event channel")
+		}
+		break
+	}
+	expected := []Event{{EventSet, "k1", 4}}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf(operationFailMessage, "Events", &Args{}, Expected{expected}, Expected{got})
+	}
+}
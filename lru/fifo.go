@@ -0,0 +1,32 @@
+package lru
+
+// FifoPolicy evicts bindings in the order they were first added, no matter
+// how they have been used since
+type FifoPolicy struct {
+	// whatever fields you want here
+}
+
+func NewFifoPolicy() *FifoPolicy {
+	return new(FifoPolicy)
+}
+
+// NewFifo returns a new FIFO cache with capacity to store limit bytes
+func NewFifo(limit int) *PolicyCache {
+	return NewPolicyCache(limit, NewFifoPolicy())
+}
+
+func (p *FifoPolicy) Add(key string, size int) {
+}
+
+func (p *FifoPolicy) Update(key string, size int) {
+}
+
+func (p *FifoPolicy) Access(key string) {
+}
+
+func (p *FifoPolicy) Remove(key string) {
+}
+
+func (p *FifoPolicy) Evict() string {
+	return ""
+}
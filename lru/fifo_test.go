@@ -0,0 +1,101 @@
+package lru
+
+import "testing"
+
+/******************************************************************************
+ *                             FIFO tests
+ ******************************************************************************/
+
+func TestFifoInsertionOrder(t *testing.T) {
+	// desc := "Check that a FIFO cache evicts bindings in the order they were added"
+	limit := 12 // room for 3 bindings
+	c := NewFifo(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true), // evicts k1
+		NewOp(Set, "k5", b("v5"), true), // evicts k2
+		NewOp(Len, 3),
+		NewOp(Remaining, 0),
+	})
+	CheckResident(t, c, []string{"k3", "k4", "k5"}, []string{"k1", "k2"})
+}
+
+func TestFifoGetDoesNotReorder(t *testing.T) {
+	// desc := "Check that Gets do not save bindings from FIFO eviction"
+	limit := 12 // room for 3 bindings
+	c := NewFifo(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "k4", b("v4"), true), // evicts k1 regardless
+		NewOp(Get, "k1", &Record{nil, false}),
+		NewOp(Get, "k2", &Record{b("v2"), true}),
+		NewOp(Set, "k5", b("v5"), true), // evicts k2
+		NewOp(Get, "k2", &Record{nil, false}),
+	})
+	CheckResident(t, c, []string{"k3", "k4", "k5"}, nil)
+}
+
+func TestFifoOverwriteKeepsOrder(t *testing.T) {
+	// desc := "Check that overwriting a binding does not move it in FIFO order"
+	limit := 12 // room for 3 bindings
+	c := NewFifo(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k1", b("11"), true),
+		NewOp(Set, "k4", b("v4"), true), // evicts k1, though it was just set
+	})
+	CheckResident(t, c, []string{"k2", "k3", "k4"}, []string{"k1"})
+
+	// A growing binding is never evicted by its own Set, even when oldest
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k2", b("v2!"), true), // evicts k3
+		NewOp(Get, "k2", &Record{b("v2!"), true}),
+	})
+	CheckResident(t, c, []string{"k2", "k4"}, []string{"k3"})
+}
+
+func TestFifoRemoveReadd(t *testing.T) {
+	// desc := "Check that a removed and re-added binding joins the back of the queue"
+	limit := 12 // room for 3 bindings
+	c := NewFifo(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Remove, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k4", b("v4"), true), // evicts k2
+		NewOp(Set, "k5", b("v5"), true), // evicts k3
+	})
+	CheckResident(t, c, []string{"k1", "k4", "k5"}, []string{"k2", "k3"})
+}
+
+func TestFifoManyEvictions(t *testing.T) {
+	// desc := "Check that a large binding evicts as many of the oldest as needed"
+	limit := 16 // room for 4 bindings
+	c := NewFifo(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "big", b("vvvvvvv"), true), // evicts k1, k2 and k3
+		NewOp(Len, 2),
+		NewOp(Remaining, 2),
+	})
+	CheckResident(t, c, []string{"k4", "big"}, []string{"k1", "k2", "k3"})
+}
@@ -0,0 +1,45 @@
+package lru
+
+// GdsPolicy is the GreedyDual-Size policy of Cao and Irani, or, with
+// frequency counting, the GreedyDual-Size-Frequency policy of Cherkasova. It
+// gives each binding a priority that grows with how often it is used and
+// shrinks with its size, and evicts the binding with the lowest priority
+type GdsPolicy struct {
+	// whatever fields you want here
+}
+
+// NewGdsPolicy returns a GDS policy, which ignores how often bindings are used
+func NewGdsPolicy() *GdsPolicy {
+	return new(GdsPolicy)
+}
+
+// NewGdsfPolicy returns a GDSF policy, which counts how often bindings are used
+func NewGdsfPolicy() *GdsPolicy {
+	return new(GdsPolicy)
+}
+
+// NewGds returns a new GDS cache with capacity to store limit bytes
+func NewGds(limit int) *PolicyCache {
+	return NewPolicyCache(limit, NewGdsPolicy())
+}
+
+// NewGdsf returns a new GDSF cache with capacity to store limit bytes
+func NewGdsf(limit int) *PolicyCache {
+	return NewPolicyCache(limit, NewGdsfPolicy())
+}
+
+func (p *GdsPolicy) Add(key string, size int) {
+}
+
+func (p *GdsPolicy) Update(key string, size int) {
+}
+
+func (p *GdsPolicy) Access(key string) {
+}
+
+func (p *GdsPolicy) Remove(key string) {
+}
+
+func (p *GdsPolicy) Evict() string {
+	return ""
+}
@@ -0,0 +1,94 @@
+package lru
+
+import "testing"
+
+/******************************************************************************
+ *                             GreedyDual-Size tests
+ ******************************************************************************/
+
+// gdsAndLru are the policies compared by the GreedyDual-Size traces
+var gdsAndLru = map[string]func() Policy{
+	"GDS":  func() Policy { return NewGdsPolicy() },
+	"GDSF": func() Policy { return NewGdsfPolicy() },
+	"LRU":  func() Policy { return NewLruPolicy() },
+}
+
+func TestGdsPrefersSmall(t *testing.T) {
+	// desc := "Check that a large binding is evicted before older small ones"
+	ops := []Operation{
+		NewOp(Set, "k1", b("vvvvvvvvvvvvvv"), true), // 16 bytes
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Get, "k1", &Record{b("vvvvvvvvvvvvvv"), true}),
+		NewOp(Set, "k5", b("v5"), true),
+		NewOp(Set, "k6", b("v6"), true), // GDS and GDSF evict k1, LRU evicts k2
+	}
+	RunPolicyTrace(t, PolicyTrace{
+		limit: 32,
+		ops:   ops,
+		present: map[string][]string{
+			"GDS":  {"k2", "k3", "k4", "k5", "k6"},
+			"GDSF": {"k2", "k3", "k4", "k5", "k6"},
+			"LRU":  {"k1", "k3", "k4", "k5", "k6"},
+		},
+		absent: map[string][]string{
+			"GDS":  {"k1"},
+			"GDSF": {"k1"},
+			"LRU":  {"k2"},
+		},
+	}, gdsAndLru)
+}
+
+func TestGdsfFrequency(t *testing.T) {
+	// desc := "Check that GDSF keeps a large binding that is used often enough"
+	ops := []Operation{
+		NewOp(Set, "k1", b("v1v1v1"), true), // 8 bytes
+		NewOp(Get, "k1", &Record{b("v1v1v1"), true}),
+		NewOp(Get, "k1", &Record{b("v1v1v1"), true}),
+		NewOp(Get, "k1", &Record{b("v1v1v1"), true}),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true), // GDSF evicts k2, GDS and LRU evict k1
+	}
+	RunPolicyTrace(t, PolicyTrace{
+		limit: 16,
+		ops:   ops,
+		present: map[string][]string{
+			"GDS":  {"k2", "k3", "k4"},
+			"GDSF": {"k1", "k3", "k4"},
+			"LRU":  {"k2", "k3", "k4"},
+		},
+		absent: map[string][]string{
+			"GDS":  {"k1"},
+			"GDSF": {"k2"},
+			"LRU":  {"k1"},
+		},
+	}, gdsAndLru)
+}
+
+func TestGdsfInflation(t *testing.T) {
+	// desc := "Check that evictions inflate new priorities, so old frequent bindings age out"
+	limit := 16 // room for 4 bindings
+	c := NewGdsf(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}), // priority 2/4
+		NewOp(Set, "k5", b("v5"), true),          // priority 1/4, evicts k2, inflating to 1/4
+		NewOp(Set, "k6", b("v6"), true),          // priority 1/4 + 1/4, evicts k3
+		NewOp(Set, "k7", b("v7"), true),          // evicts k4
+		NewOp(Set, "k8", b("v8"), true),          // evicts k5
+	})
+	CheckResident(t, c, []string{"k1", "k6", "k7", "k8"}, []string{"k2", "k3", "k4", "k5"})
+
+	// k1 now ties with the newer bindings, but reached its priority first
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k9", b("v9"), true), // evicts k1, inflating to 2/4
+		NewOp(Set, "k0", b("v0"), true), // evicts k6
+	})
+	CheckResident(t, c, []string{"k7", "k8", "k9", "k0"}, []string{"k1", "k6"})
+}
@@ -0,0 +1,28 @@
+//go:build golanglru
+
+package lru
+
+import (
+	"testing"
+
+	hashicorp "github.com/hashicorp/golang-lru/v2"
+)
+
+/******************************************************************************
+ *                        golang-lru reference tests
+ ******************************************************************************/
+// This test only builds with the "golanglru" tag, since it needs
+// github.com/hashicorp/golang-lru/v2. It runs the behavioral tests that
+// HashicorpCache must pass against golang-lru itself, to keep them honest:
+//
+//	go test -tags golanglru -run Hashicorp
+
+func TestHashicorpLibrary(t *testing.T) {
+	testHashicorpBehavior(t, func(size int) (hashicorpAPI[int, int], error) {
+		c, err := hashicorp.New[int, int](size)
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	})
+}
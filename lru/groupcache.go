@@ -0,0 +1,42 @@
+package lru
+
+// Key may be any value that is comparable, as in groupcache's lru package
+type Key interface{}
+
+// GroupCache has the API of groupcache's lru.Cache, limiting the number of
+// entries rather than their size
+type GroupCache struct {
+	// MaxEntries is the maximum number of entries before an item is evicted.
+	// Zero means no limit.
+	MaxEntries int
+
+	// OnEvicted optionally specifies a callback function to be executed when
+	// an entry is purged from the cache.
+	OnEvicted func(key Key, value interface{})
+
+	// whatever fields you want here
+}
+
+func NewGroupCache(maxEntries int) *GroupCache {
+	return new(GroupCache)
+}
+
+func (c *GroupCache) Add(key Key, value interface{}) {
+}
+
+func (c *GroupCache) Get(key Key) (value interface{}, ok bool) {
+	return nil, false
+}
+
+func (c *GroupCache) Remove(key Key) {
+}
+
+func (c *GroupCache) RemoveOldest() {
+}
+
+func (c *GroupCache) Len() int {
+	return 0
+}
+
+func (c *GroupCache) Clear() {
+}
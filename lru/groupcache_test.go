@@ -0,0 +1,163 @@
+package lru
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+/******************************************************************************
+ *                          groupcache adapter tests
+ ******************************************************************************/
+// These follow the behavior of github.com/golang/groupcache/lru's own tests.
+
+type simpleStruct struct {
+	int
+	string
+}
+
+type complexStruct struct {
+	int
+	simpleStruct
+}
+
+// groupEvictions records the entries a GroupCache passes to OnEvicted
+type groupEvictions []string
+
+func (e *groupEvictions) record(key Key, value interface{}) {
+	*e = append(*e, fmt.Sprintf("%v=%v", key, value))
+}
+
+func TestGroupCacheGet(t *testing.T) {
+	// desc := "Check that keys of any comparable type find what was added for them"
+	tests := []struct {
+		name       string
+		keyToAdd   interface{}
+		keyToGet   interface{}
+		expectedOk bool
+	}{
+		{"string_hit", "myKey", "myKey", true},
+		{"string_miss", "myKey", "nonsense", false},
+		{"simple_struct_hit", simpleStruct{1, "two"}, simpleStruct{1, "two"}, true},
+		{"simple_struct_miss", simpleStruct{1, "two"}, simpleStruct{0, "noway"}, false},
+		{"complex_struct_hit", complexStruct{1, simpleStruct{2, "three"}},
+			complexStruct{1, simpleStruct{2, "three"}}, true},
+		{"int_not_string", 1, "1", false},
+	}
+	for _, tt := range tests {
+		c := NewGroupCache(0)
+		c.Add(tt.keyToAdd, 1234)
+		val, ok := c.Get(tt.keyToGet)
+		if ok != tt.expectedOk {
+			t.Errorf(operationFailMessage, "Get", fmt.Sprintf("%s: %#v", tt.name, tt.keyToGet),
+				Expected{tt.expectedOk}, Expected{ok})
+		} else if ok && val != 1234 {
+			t.Errorf(operationFailMessage, "Get", fmt.Sprintf("%s: %#v", tt.name, tt.keyToGet),
+				Expected{1234}, Expected{val})
+		}
+	}
+}
+
+func TestGroupCacheRemove(t *testing.T) {
+	// desc := "Check that a removed key is no longer found"
+	c := NewGroupCache(0)
+	c.Add("myKey", 1234)
+	if val, ok := c.Get("myKey"); !ok || val != 1234 {
+		t.Errorf(operationFailMessage, "Get", "\"myKey\"", Expected{1234}, Expected{val})
+	}
+
+	c.Remove("myKey")
+	if _, ok := c.Get("myKey"); ok {
+		t.Errorf(operationFailMessage, "Get", "\"myKey\" after Remove", Expected{false}, Expected{ok})
+	}
+	c.Remove("myKey") // removing a missing key does nothing
+	if c.Len() != 0 {
+		t.Errorf(operationFailMessage, "Len", &Args{}, Expected{0}, Expected{c.Len()})
+	}
+}
+
+func TestGroupCacheEvict(t *testing.T) {
+	// desc := "Check that adding past MaxEntries evicts the least recently used entries"
+	var evicted groupEvictions
+	c := NewGroupCache(20)
+	c.OnEvicted = evicted.record
+	for i := 0; i < 22; i++ {
+		c.Add(fmt.Sprintf("myKey%d", i), 1234)
+	}
+
+	expected := []string{"myKey0=1234", "myKey1=1234"}
+	if fmt.Sprint(evicted) != fmt.Sprint(expected) {
+		t.Errorf("Expected evictions %v, found %v", expected, evicted)
+	}
+	if c.Len() != 20 {
+		t.Errorf(operationFailMessage, "Len", &Args{}, Expected{20}, Expected{c.Len()})
+	}
+}
+
+func TestGroupCacheRecency(t *testing.T) {
+	// desc := "Check that Get and Add refresh an entry, and RemoveOldest takes the stalest"
+	var evicted groupEvictions
+	c := NewGroupCache(3)
+	c.OnEvicted = evicted.record
+	c.Add("k1", "v1")
+	c.Add("k2", "v2")
+	c.Add("k3", "v3")
+	c.Get("k1")
+	c.Add("k2", "w2") // replaces, without an eviction
+	c.Add("k4", "v4") // evicts k3
+
+	c.RemoveOldest() // k1
+	c.RemoveOldest() // k2
+	expected := []string{"k3=v3", "k1=v1", "k2=w2"}
+	if fmt.Sprint(evicted) != fmt.Sprint(expected) {
+		t.Errorf("Expected evictions %v, found %v", expected, evicted)
+	}
+	if val, ok := c.Get("k4"); !ok || val != "v4" || c.Len() != 1 {
+		t.Errorf(operationFailMessage, "Get", "\"k4\"", Expected{"v4"}, Expected{val})
+	}
+}
+
+func TestGroupCacheClear(t *testing.T) {
+	// desc := "Check that Clear empties the cache, passing every entry to OnEvicted"
+	var evicted groupEvictions
+	c := NewGroupCache(0)
+	c.OnEvicted = evicted.record
+	for i := 0; i < 5; i++ {
+		c.Add(i, i*i)
+	}
+	c.Clear()
+
+	sort.Strings(evicted)
+	expected := []string{"0=0", "1=1", "2=4", "3=9", "4=16"}
+	sort.Strings(expected)
+	if fmt.Sprint(evicted) != fmt.Sprint(expected) {
+		t.Errorf("Expected evictions %v, found %v", expected, evicted)
+	}
+	if _, ok := c.Get(1); ok || c.Len() != 0 {
+		t.Errorf("Expected an empty cache after Clear, found %d entries", c.Len())
+	}
+
+	// The cache is usable again after Clear
+	c.Add("k1", "v1")
+	if val, ok := c.Get("k1"); !ok || val != "v1" {
+		t.Errorf(operationFailMessage, "Get", "\"k1\" after Clear", Expected{"v1"}, Expected{val})
+	}
+}
+
+func TestGroupCacheZeroValue(t *testing.T) {
+	// desc := "Check that the zero GroupCache is an empty cache with no limit"
+	var c GroupCache
+	if _, ok := c.Get("k1"); ok || c.Len() != 0 {
+		t.Errorf("Expected the zero GroupCache to be empty")
+	}
+	c.Remove("k1")
+	c.RemoveOldest()
+	c.Clear()
+
+	for i := 0; i < 1000; i++ {
+		c.Add(i, i)
+	}
+	if c.Len() != 1000 {
+		t.Errorf(operationFailMessage, "Len", &Args{}, Expected{1000}, Expected{c.Len()})
+	}
+}
@@ -0,0 +1,42 @@
+package lru
+
+// HashicorpCache has the API of hashicorp/golang-lru/v2's Cache, limiting the
+// number of entries rather than their size
+type HashicorpCache[K comparable, V any] struct {
+	// whatever fields you want here
+}
+
+func NewHashicorpCache[K comparable, V any](size int) (*HashicorpCache[K, V], error) {
+	return new(HashicorpCache[K, V]), nil
+}
+
+func (c *HashicorpCache[K, V]) Add(key K, value V) (evicted bool) {
+	return false
+}
+
+func (c *HashicorpCache[K, V]) Get(key K) (value V, ok bool) {
+	return value, false
+}
+
+func (c *HashicorpCache[K, V]) Contains(key K) bool {
+	return false
+}
+
+func (c *HashicorpCache[K, V]) Peek(key K) (value V, ok bool) {
+	return value, false
+}
+
+func (c *HashicorpCache[K, V]) Remove(key K) (present bool) {
+	return false
+}
+
+func (c *HashicorpCache[K, V]) Keys() []K {
+	return nil
+}
+
+func (c *HashicorpCache[K, V]) Purge() {
+}
+
+func (c *HashicorpCache[K, V]) Len() int {
+	return 0
+}
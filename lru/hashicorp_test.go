@@ -0,0 +1,151 @@
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+/******************************************************************************
+ *                           hashicorp adapter tests
+ ******************************************************************************/
+// The behavioral tests here run against any cache with hashicorp's API, so
+// that they can also be run against golang-lru itself (see golanglru_test.go)
+// to check that the two agree.
+
+// hashicorpAPI is the part of golang-lru/v2's Cache that HashicorpCache has
+type hashicorpAPI[K comparable, V any] interface {
+	Add(key K, value V) bool
+	Get(key K) (V, bool)
+	Contains(key K) bool
+	Peek(key K) (V, bool)
+	Remove(key K) bool
+	Keys() []K
+	Purge()
+	Len() int
+}
+
+// newHashicorpShim makes a HashicorpCache for the behavioral tests
+func newHashicorpShim(size int) (hashicorpAPI[int, int], error) {
+	return NewHashicorpCache[int, int](size)
+}
+
+func TestHashicorpShim(t *testing.T) {
+	testHashicorpBehavior(t, newHashicorpShim)
+}
+
+// checkHashicorpKeys asserts that c holds exactly expected, from least to most
+// recently used
+func checkHashicorpKeys(t *testing.T, c hashicorpAPI[int, int], expected []int) {
+	t.Helper()
+	if keys := c.Keys(); fmt.Sprint(keys) != fmt.Sprint(expected) {
+		t.Errorf(operationFailMessage, "Keys", &Args{}, Expected{expected}, Expected{keys})
+	}
+	if c.Len() != len(expected) {
+		t.Errorf(operationFailMessage, "Len", &Args{}, Expected{len(expected)}, Expected{c.Len()})
+	}
+}
+
+// testHashicorpBehavior checks that caches made by newCache behave as
+// golang-lru's do
+func testHashicorpBehavior(t *testing.T, newCache func(size int) (hashicorpAPI[int, int], error)) {
+	t.Run("Size", func(t *testing.T) {
+		// desc := "Check that a cache must have room for at least one entry"
+		for _, size := range []int{0, -1} {
+			if _, err := newCache(size); err == nil {
+				t.Errorf(operationFailMessage, "New", fmt.Sprint(size), "an error", Expected{err})
+			}
+		}
+	})
+
+	t.Run("Evict", func(t *testing.T) {
+		// desc := "Check that adding past the size evicts least recently used entries"
+		c, err := newCache(128)
+		if err != nil {
+			t.Fatal(err)
+		}
+		evictions := 0
+		for i := 0; i < 256; i++ {
+			if c.Add(i, i) {
+				evictions++
+			}
+		}
+		if evictions != 128 {
+			t.Errorf("Expected 128 evictions, found %d", evictions)
+		}
+
+		expected := make([]int, 0, 128)
+		for i := 128; i < 256; i++ {
+			expected = append(expected, i)
+		}
+		checkHashicorpKeys(t, c, expected)
+		for i := 0; i < 256; i++ {
+			val, ok := c.Get(i)
+			if ok != (i >= 128) || (ok && val != i) {
+				t.Errorf(operationFailMessage, "Get", fmt.Sprint(i),
+					Expected{i >= 128}, Expected{ok})
+			}
+		}
+	})
+
+	t.Run("Recency", func(t *testing.T) {
+		// desc := "Check that Get and Add refresh entries, while Contains and Peek do not"
+		c, err := newCache(3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.Add(1, 1)
+		c.Add(2, 2)
+		c.Add(3, 3)
+		c.Get(1)
+		if c.Add(2, 20) {
+			t.Errorf(operationFailMessage, "Add", "2, 20", Expected{false}, Expected{true})
+		}
+		checkHashicorpKeys(t, c, []int{3, 1, 2})
+
+		if !c.Contains(3) {
+			t.Errorf(operationFailMessage, "Contains", "3", Expected{true}, Expected{false})
+		}
+		if val, ok := c.Peek(3); !ok || val != 3 {
+			t.Errorf(operationFailMessage, "Peek", "3", Expected{3}, Expected{val})
+		}
+		checkHashicorpKeys(t, c, []int{3, 1, 2})
+		if val, _ := c.Get(2); val != 20 {
+			t.Errorf(operationFailMessage, "Get", "2", Expected{20}, Expected{val})
+		}
+
+		c.Add(4, 4) // evicts 3, which Contains and Peek did not refresh
+		checkHashicorpKeys(t, c, []int{1, 2, 4})
+		if c.Contains(3) {
+			t.Errorf(operationFailMessage, "Contains", "3", Expected{false}, Expected{true})
+		}
+		if _, ok := c.Peek(3); ok {
+			t.Errorf(operationFailMessage, "Peek", "3", Expected{false}, Expected{ok})
+		}
+	})
+
+	t.Run("RemovePurge", func(t *testing.T) {
+		// desc := "Check that Remove reports whether the key was present, and Purge empties the cache"
+		c, err := newCache(8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 4; i++ {
+			c.Add(i, i)
+		}
+		if !c.Remove(1) {
+			t.Errorf(operationFailMessage, "Remove", "1", Expected{true}, Expected{false})
+		}
+		if c.Remove(1) {
+			t.Errorf(operationFailMessage, "Remove", "1 again", Expected{false}, Expected{true})
+		}
+		checkHashicorpKeys(t, c, []int{0, 2, 3})
+
+		c.Purge()
+		checkHashicorpKeys(t, c, []int{})
+		if _, ok := c.Get(0); ok {
+			t.Errorf(operationFailMessage, "Get", "0 after Purge", Expected{false}, Expected{ok})
+		}
+		c.Add(5, 5)
+		checkHashicorpKeys(t, c, []int{5})
+	})
+}
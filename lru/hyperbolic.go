@@ -0,0 +1,38 @@
+package lru
+
+// HyperbolicPolicy is the hyperbolic caching policy of Blankstein et al. Each
+// binding's priority is the number of times it has been used divided by how
+// long it has been cached, and evictions take the binding with the lowest
+// priority among a random sample
+type HyperbolicPolicy struct {
+	// whatever fields you want here
+}
+
+// NewHyperbolicPolicy returns a hyperbolic policy that tells the time with
+// clock (or the system clock, if clock is nil) and samples bindings with a
+// pseudo-random generator seeded with seed
+func NewHyperbolicPolicy(clock Clock, samples int, seed int64) *HyperbolicPolicy {
+	return new(HyperbolicPolicy)
+}
+
+// NewHyperbolic returns a new hyperbolic cache with capacity to store limit
+// bytes
+func NewHyperbolic(limit int, clock Clock, samples int, seed int64) *PolicyCache {
+	return NewPolicyCache(limit, NewHyperbolicPolicy(clock, samples, seed))
+}
+
+func (p *HyperbolicPolicy) Add(key string, size int) {
+}
+
+func (p *HyperbolicPolicy) Update(key string, size int) {
+}
+
+func (p *HyperbolicPolicy) Access(key string) {
+}
+
+func (p *HyperbolicPolicy) Remove(key string) {
+}
+
+func (p *HyperbolicPolicy) Evict() string {
+	return ""
+}
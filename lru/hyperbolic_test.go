@@ -0,0 +1,108 @@
+package lru
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+/******************************************************************************
+ *                             Hyperbolic caching tests
+ ******************************************************************************/
+
+// Enough samples that the tests' caches always consider every binding
+const hyperbolicAll = 16
+
+func TestHyperbolicFrequency(t *testing.T) {
+	// desc := "Check that a new binding used once loses to old bindings used often"
+	limit := 16 // room for 4 bindings
+	clock := NewFakeClock()
+	c := NewHyperbolic(limit, clock, hyperbolicAll, 316)
+
+	ops := []Operation{}
+	for _, key := range []string{"k1", "k2", "k3"} {
+		ops = append(ops, NewOp(Set, key, b("vv"), true))
+		for i := 0; i < 3; i++ {
+			ops = append(ops, NewOp(Get, key, &Record{b("vv"), true}))
+		}
+	}
+	ExecuteCacheOperations(t, c, ops)
+
+	clock.Advance(4 * time.Second)
+	ExecuteCacheOperations(t, c, []Operation{NewOp(Set, "k4", b("vv"), true)})
+
+	// k1, k2 and k3 have priority 4/16, but k4 only 1/12. LRU would evict k1.
+	clock.Advance(12 * time.Second)
+	ExecuteCacheOperations(t, c, []Operation{NewOp(Set, "k5", b("vv"), true)})
+	CheckResident(t, c, []string{"k1", "k2", "k3", "k5"}, []string{"k4"})
+}
+
+func TestHyperbolicDecay(t *testing.T) {
+	// desc := "Check that a binding's priority falls the longer it stays cached"
+	limit := 8 // room for 2 bindings
+	clock := NewFakeClock()
+	c := NewHyperbolic(limit, clock, hyperbolicAll, 316)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+	})
+	clock.Advance(time.Second)
+	ExecuteCacheOperations(t, c, []Operation{NewOp(Set, "k2", b("v2"), true)})
+
+	// k1 has priority 4/2 and k2 1/1
+	clock.Advance(time.Second)
+	ExecuteCacheOperations(t, c, []Operation{NewOp(Set, "k3", b("v3"), true)})
+	CheckResident(t, c, []string{"k1", "k3"}, []string{"k2"})
+
+	// k1 has priority 4/12 and k3 1/10
+	clock.Advance(10 * time.Second)
+	ExecuteCacheOperations(t, c, []Operation{NewOp(Set, "k4", b("v4"), true)})
+	CheckResident(t, c, []string{"k1", "k4"}, []string{"k3"})
+
+	// k1 has priority 4/13 and k4 1/1, so k1's uses no longer save it
+	clock.Advance(time.Second)
+	ExecuteCacheOperations(t, c, []Operation{NewOp(Set, "k5", b("v5"), true)})
+	CheckResident(t, c, []string{"k4", "k5"}, []string{"k1"})
+}
+
+func TestHyperbolicTies(t *testing.T) {
+	// desc := "Check that ties, including bindings cached for no time at all, evict the oldest"
+	limit := 12 // room for 3 bindings
+	clock := NewFakeClock()
+	c := NewHyperbolic(limit, clock, hyperbolicAll, 316)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true), // evicts k1
+	})
+	CheckResident(t, c, []string{"k2", "k3", "k4"}, []string{"k1"})
+
+	clock.Advance(time.Second)
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Get, "k4", &Record{b("v4"), true}),
+		NewOp(Set, "k5", b("v5"), true), // evicts k2, which ties with k3
+	})
+	CheckResident(t, c, []string{"k3", "k4", "k5"}, []string{"k2"})
+}
+
+func TestHyperbolicSampled(t *testing.T) {
+	// desc := "Check that sampled evictions are determined by the seed"
+	limit := 16 * 8 // room for 16 bindings
+	trace := ScanTrace(24, 2, 8, 10)
+	keys := ScanTrace(24, 1, 8*10, 1)
+
+	c1 := NewHyperbolic(limit, NewFakeClock(), 2, 316)
+	c2 := NewHyperbolic(limit, NewFakeClock(), 2, 316)
+	h1, h2 := ReplayHits(c1, trace), ReplayHits(c2, trace)
+	r1, r2 := residents(c1, keys), residents(c2, keys)
+
+	if h1 != h2 || fmt.Sprint(r1) != fmt.Sprint(r2) {
+		t.Errorf("Two hyperbolic caches seeded with 316 disagreed: one hit %d times "+
+			"and held %v, the other hit %d times and held %v", h1, r1, h2, r2)
+	}
+}
@@ -0,0 +1,9 @@
+package lru
+
+func (lru *LRU) SetJSON(key string, v any) error {
+	return nil
+}
+
+func (lru *LRU) GetJSON(key string, out any) error {
+	return nil
+}
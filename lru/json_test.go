@@ -0,0 +1,138 @@
+package lru
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+/******************************************************************************
+ *                             JSON value tests
+ ******************************************************************************/
+
+type jsonPoint struct {
+	X, Y int
+	Tag  string `json:",omitempty"`
+}
+
+// CheckSetJSON fails the test unless lru.SetJSON(key, v) returns expected
+func CheckSetJSON(t *testing.T, lru *LRU, key string, v any, expected error) {
+	if got := lru.SetJSON(key, v); !errors.Is(got, expected) {
+		t.Errorf(operationFailMessage, "SetJSON", fmt.Sprintf("%q, %#v", key, v),
+			Expected{expected}, Expected{got})
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	// desc := "Check that values come back from GetJSON as they went into SetJSON"
+	lru := NewLru(1024)
+	CheckSetJSON(t, lru, "point", jsonPoint{1, 2, "a"}, nil)
+	CheckSetJSON(t, lru, "list", []string{"x", "y"}, nil)
+
+	var p jsonPoint
+	if err := lru.GetJSON("point", &p); err != nil || p != (jsonPoint{1, 2, "a"}) {
+		t.Errorf(operationFailMessage, "GetJSON", `"point"`,
+			Expected{jsonPoint{1, 2, "a"}}, Expected{fmt.Sprintf("%v, %v", p, err)})
+	}
+	var list []string
+	if err := lru.GetJSON("list", &list); err != nil || !reflect.DeepEqual(list, []string{"x", "y"}) {
+		t.Errorf(operationFailMessage, "GetJSON", `"list"`,
+			Expected{[]string{"x", "y"}}, Expected{fmt.Sprintf("%v, %v", list, err)})
+	}
+
+	// The values are stored as plain JSON, for Get and the rest
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Get, "point", &Record{b(`{"X":1,"Y":2,"Tag":"a"}`), true}),
+		NewOp(Set, "list", b(`["z"]`), true),
+	})
+	if err := lru.GetJSON("list", &list); err != nil || !reflect.DeepEqual(list, []string{"z"}) {
+		t.Errorf(operationFailMessage, "GetJSON", `"list"`,
+			Expected{[]string{"z"}}, Expected{fmt.Sprintf("%v, %v", list, err)})
+	}
+}
+
+func TestJSONSize(t *testing.T) {
+	// desc := "Check that JSON bindings are charged for their encoded size"
+	limit := 40
+	lru := NewLru(limit)
+	CheckSetJSON(t, lru, "p1", jsonPoint{X: 10, Y: 20}, nil) // 2 + 15 bytes
+	CheckSetJSON(t, lru, "p2", jsonPoint{X: 30, Y: 40}, nil) // 2 + 15 bytes
+	ExecuteOperations(t, lru, []Operation{NewOp(Remaining, limit-34)})
+
+	// The third evicts the first
+	CheckSetJSON(t, lru, "p3", jsonPoint{X: 50, Y: 60}, nil)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Peek, "p1", &Record{nil, false}),
+		NewOp(Remaining, limit-34),
+	})
+
+	// One too large to fit is rejected, leaving everything as it was
+	CheckSetJSON(t, lru, "p2", jsonPoint{X: 70, Y: 80, Tag: "too long to fit"}, ErrTooLarge)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Peek, "p2", &Record{b(`{"X":30,"Y":40}`), true}),
+		NewOp(Peek, "p3", &Record{b(`{"X":50,"Y":60}`), true}),
+		NewOp(Remaining, limit-34),
+	})
+}
+
+func TestJSONMarshalError(t *testing.T) {
+	// desc := "Check that a value JSON cannot encode leaves the LRU unchanged"
+	lru := NewLru(1024)
+	CheckSetJSON(t, lru, "key", "value", nil)
+
+	var unsupported *json.UnsupportedTypeError
+	if err := lru.SetJSON("key", make(chan int)); !errors.As(err, &unsupported) {
+		t.Errorf(operationFailMessage, "SetJSON", `"key", make(chan int)`,
+			Expected{"a *json.UnsupportedTypeError"}, Expected{err})
+	}
+	if err := lru.SetJSON("other", map[string]any{"f": func() {}}); !errors.As(err, &unsupported) {
+		t.Errorf(operationFailMessage, "SetJSON", `"other", map[string]any{"f": func() {}}`,
+			Expected{"a *json.UnsupportedTypeError"}, Expected{err})
+	}
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Get, "key", &Record{b(`"value"`), true}),
+		NewOp(Get, "other", &Record{nil, false}),
+		NewOp(Len, 1),
+	})
+}
+
+func TestJSONGetErrors(t *testing.T) {
+	// desc := "Check that GetJSON reports misses and values that do not decode"
+	lru := NewLru(1024)
+	var p jsonPoint
+	if err := lru.GetJSON("missing", &p); err != ErrNotFound {
+		t.Errorf(operationFailMessage, "GetJSON", `"missing"`, Expected{ErrNotFound}, Expected{err})
+	}
+
+	lru.Set("bad", b("not json"))
+	var syntax *json.SyntaxError
+	if err := lru.GetJSON("bad", &p); !errors.As(err, &syntax) {
+		t.Errorf(operationFailMessage, "GetJSON", `"bad"`,
+			Expected{"a *json.SyntaxError"}, Expected{err})
+	}
+	CheckSetJSON(t, lru, "list", []int{1, 2}, nil)
+	var typeErr *json.UnmarshalTypeError
+	if err := lru.GetJSON("list", &p); !errors.As(err, &typeErr) {
+		t.Errorf(operationFailMessage, "GetJSON", `"list"`,
+			Expected{"a *json.UnmarshalTypeError"}, Expected{err})
+	}
+
+	// The bindings are still there
+	ExecuteOperations(t, lru, []Operation{NewOp(Len, 2)})
+}
+
+func TestJSONLoads(t *testing.T) {
+	// desc := "Check that GetJSON loads a missing value like Fetch"
+	lru := NewLru(1024)
+	loader := NewFakeLoader(map[string][]byte{"point": b(`{"X":3,"Y":4}`)})
+	lru.SetLoader(loader)
+
+	var p jsonPoint
+	if err := lru.GetJSON("point", &p); err != nil || p != (jsonPoint{X: 3, Y: 4}) {
+		t.Errorf(operationFailMessage, "GetJSON", `"point"`,
+			Expected{jsonPoint{X: 3, Y: 4}}, Expected{fmt.Sprintf("%v, %v", p, err)})
+	}
+	ExecuteOperations(t, lru, []Operation{NewOp(Peek, "point", &Record{b(`{"X":3,"Y":4}`), true})})
+}
@@ -0,0 +1,32 @@
+package lru
+
+// LfuPolicy evicts the least frequently used binding, breaking ties by
+// evicting the least recently used of them
+type LfuPolicy struct {
+	// whatever fields you want here
+}
+
+func NewLfuPolicy() *LfuPolicy {
+	return new(LfuPolicy)
+}
+
+// NewLfu returns a new LFU cache with capacity to store limit bytes
+func NewLfu(limit int) *PolicyCache {
+	return NewPolicyCache(limit, NewLfuPolicy())
+}
+
+func (p *LfuPolicy) Add(key string, size int) {
+}
+
+func (p *LfuPolicy) Update(key string, size int) {
+}
+
+func (p *LfuPolicy) Access(key string) {
+}
+
+func (p *LfuPolicy) Remove(key string) {
+}
+
+func (p *LfuPolicy) Evict() string {
+	return ""
+}
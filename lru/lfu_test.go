@@ -0,0 +1,144 @@
+package lru
+
+import "testing"
+
+/******************************************************************************
+ *                             LFU tests
+ ******************************************************************************/
+
+// PolicyTrace is a trace of operations, and the keys that must be resident
+// and absent after it has run on a cache with each of several policies
+type PolicyTrace struct {
+	limit   int
+	ops     []Operation
+	present map[string][]string // by policy name
+	absent  map[string][]string // by policy name
+}
+
+// RunPolicyTrace runs trace on a new cache for each named policy, in a
+// subtest per policy
+func RunPolicyTrace(t *testing.T, trace PolicyTrace, policies map[string]func() Policy) {
+	for name, policy := range policies {
+		t.Run(name, func(t *testing.T) {
+			c := NewPolicyCache(trace.limit, policy())
+			ExecuteCacheOperations(t, c, trace.ops)
+			CheckResident(t, c, trace.present[name], trace.absent[name])
+		})
+	}
+}
+
+// lfuAndLru are the policies compared by traces distinguishing LFU from LRU
+var lfuAndLru = map[string]func() Policy{
+	"LFU": func() Policy { return NewLfuPolicy() },
+	"LRU": func() Policy { return NewLruPolicy() },
+}
+
+func TestLfuFrequencyOrder(t *testing.T) {
+	// desc := "Check that LFU evicts the least frequently used binding, where LRU would not"
+	RunPolicyTrace(t, PolicyTrace{
+		limit: 12, // room for 3 bindings
+		ops: []Operation{
+			NewOp(Set, "k1", b("v1"), true),
+			NewOp(Set, "k2", b("v2"), true),
+			NewOp(Set, "k3", b("v3"), true),
+			NewOp(Get, "k1", &Record{b("v1"), true}),
+			NewOp(Get, "k1", &Record{b("v1"), true}),
+			NewOp(Get, "k2", &Record{b("v2"), true}),
+			NewOp(Get, "k3", &Record{b("v3"), true}),
+			NewOp(Set, "k4", b("v4"), true), // LFU evicts k2, LRU evicts k1
+		},
+		present: map[string][]string{
+			"LFU": {"k1", "k3", "k4"},
+			"LRU": {"k2", "k3", "k4"},
+		},
+		absent: map[string][]string{
+			"LFU": {"k2"},
+			"LRU": {"k1"},
+		},
+	}, lfuAndLru)
+}
+
+func TestLfuTieBreak(t *testing.T) {
+	// desc := "Check that LFU breaks ties by evicting the least recently used binding"
+	RunPolicyTrace(t, PolicyTrace{
+		limit: 12, // room for 3 bindings
+		ops: []Operation{
+			NewOp(Set, "k1", b("v1"), true),
+			NewOp(Set, "k2", b("v2"), true),
+			NewOp(Set, "k3", b("v3"), true),
+			NewOp(Get, "k2", &Record{b("v2"), true}),
+			NewOp(Get, "k1", &Record{b("v1"), true}),
+			NewOp(Get, "k3", &Record{b("v3"), true}),
+			NewOp(Set, "k4", b("v4"), true), // all used twice, so both evict k2
+		},
+		present: map[string][]string{
+			"LFU": {"k1", "k3", "k4"},
+			"LRU": {"k1", "k3", "k4"},
+		},
+		absent: map[string][]string{
+			"LFU": {"k2"},
+			"LRU": {"k2"},
+		},
+	}, lfuAndLru)
+}
+
+func TestLfuNewcomerEvicted(t *testing.T) {
+	// desc := "Check that LFU evicts a newly added binding before frequently used ones"
+	RunPolicyTrace(t, PolicyTrace{
+		limit: 12, // room for 3 bindings
+		ops: []Operation{
+			NewOp(Set, "k1", b("v1"), true),
+			NewOp(Set, "k2", b("v2"), true),
+			NewOp(Get, "k1", &Record{b("v1"), true}),
+			NewOp(Get, "k2", &Record{b("v2"), true}),
+			NewOp(Set, "k3", b("v3"), true),
+			NewOp(Set, "k4", b("v4"), true), // LFU evicts k3, LRU evicts k1
+			NewOp(Set, "k5", b("v5"), true), // LFU evicts k4, LRU evicts k2
+		},
+		present: map[string][]string{
+			"LFU": {"k1", "k2", "k5"},
+			"LRU": {"k3", "k4", "k5"},
+		},
+		absent: map[string][]string{
+			"LFU": {"k3", "k4"},
+			"LRU": {"k1", "k2"},
+		},
+	}, lfuAndLru)
+}
+
+func TestLfuUpdateCounts(t *testing.T) {
+	// desc := "Check that overwriting a binding counts as using it"
+	limit := 12 // room for 3 bindings
+	c := NewLfu(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k1", b("11"), true),
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Get, "k2", &Record{b("v2"), true}),
+		NewOp(Set, "k4", b("v4"), true), // evicts k3, the only binding used once
+	})
+	CheckResident(t, c, []string{"k1", "k2", "k4"}, []string{"k3"})
+}
+
+func TestLfuRemoveForgets(t *testing.T) {
+	// desc := "Check that a removed binding loses its frequency"
+	limit := 12 // room for 3 bindings
+	c := NewLfu(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Remove, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Get, "k2", &Record{b("v2"), true}),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Get, "k3", &Record{b("v3"), true}),
+		NewOp(Set, "k4", b("v4"), true), // evicts k1
+	})
+	CheckResident(t, c, []string{"k2", "k3", "k4"}, []string{"k1"})
+}
@@ -0,0 +1,36 @@
+package lru
+
+// LirsPolicy is the LIRS (low inter-reference recency set) policy of Jiang
+// and Zhang. It keeps bindings that were reused quickly in an LIR set that is
+// protected from eviction, and evicts from a small queue of the other, HIR,
+// bindings; a stack of recently used keys decides which set each binding is in
+type LirsPolicy struct {
+	// whatever fields you want here
+}
+
+// NewLirsPolicy returns a LIRS policy for a cache with capacity to store limit
+// bytes
+func NewLirsPolicy(limit int) *LirsPolicy {
+	return new(LirsPolicy)
+}
+
+// NewLirs returns a new LIRS cache with capacity to store limit bytes
+func NewLirs(limit int) *PolicyCache {
+	return NewPolicyCache(limit, NewLirsPolicy(limit))
+}
+
+func (p *LirsPolicy) Add(key string, size int) {
+}
+
+func (p *LirsPolicy) Update(key string, size int) {
+}
+
+func (p *LirsPolicy) Access(key string) {
+}
+
+func (p *LirsPolicy) Remove(key string) {
+}
+
+func (p *LirsPolicy) Evict() string {
+	return ""
+}
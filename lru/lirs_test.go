@@ -0,0 +1,113 @@
+package lru
+
+import "testing"
+
+/******************************************************************************
+ *                             LIRS tests
+ ******************************************************************************/
+
+func TestLirsScanResistance(t *testing.T) {
+	// desc := "Check that new bindings are resident HIR, and are evicted before LIR bindings"
+	ops := []Operation{
+		NewOp(Set, "k1", b("v1"), true), // LIR
+		NewOp(Set, "k2", b("v2"), true), // LIR
+		NewOp(Set, "k3", b("v3"), true), // LIR
+		NewOp(Set, "k4", b("v4"), true), // resident HIR
+		NewOp(Set, "k5", b("v5"), true), // LIRS evicts k4, LRU evicts k1
+		NewOp(Set, "k6", b("v6"), true), // LIRS evicts k5, LRU evicts k2
+	}
+	RunPolicyTrace(t, PolicyTrace{
+		limit: 16, // room for 4 bindings, 3 of them LIR
+		ops:   ops,
+		present: map[string][]string{
+			"LIRS": {"k1", "k2", "k3", "k6"},
+			"LRU":  {"k3", "k4", "k5", "k6"},
+		},
+		absent: map[string][]string{
+			"LIRS": {"k4", "k5"},
+			"LRU":  {"k1", "k2"},
+		},
+	}, map[string]func() Policy{
+		"LIRS": func() Policy { return NewLirsPolicy(16) },
+		"LRU":  func() Policy { return NewLruPolicy() },
+	})
+}
+
+func TestLirsGhostPromotion(t *testing.T) {
+	// desc := "Check that a key evicted while on the stack returns as LIR, demoting the bottom LIR"
+	limit := 16 // room for 4 bindings, 3 of them LIR
+	c := NewLirs(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Set, "k5", b("v5"), true), // evicts k4, which stays on the stack
+		NewOp(Set, "k6", b("v6"), true), // evicts k5
+
+		// k4 becomes LIR and k1 is demoted behind k6, which is evicted
+		NewOp(Set, "k4", b("44"), true),
+	})
+	CheckResident(t, c, []string{"k1", "k2", "k3", "k4"}, []string{"k5", "k6"})
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k7", b("v7"), true), // evicts k1
+	})
+	CheckResident(t, c, []string{"k2", "k3", "k4", "k7"}, []string{"k1"})
+}
+
+func TestLirsStackPruning(t *testing.T) {
+	// desc := "Check that HIR bindings pruned from the bottom of the stack are not promoted"
+	limit := 16 // room for 4 bindings, 3 of them LIR
+	c := NewLirs(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k2", &Record{b("v2"), true}),
+
+		// k4 reaches the bottom of the stack, and is pruned from it
+		NewOp(Get, "k3", &Record{b("v3"), true}),
+
+		// So this Get leaves k4 HIR, rather than promoting it
+		NewOp(Get, "k4", &Record{b("v4"), true}),
+		NewOp(Set, "k5", b("v5"), true), // evicts k4
+	})
+	CheckResident(t, c, []string{"k1", "k2", "k3", "k5"}, []string{"k4"})
+}
+
+func TestLirsHirPromotion(t *testing.T) {
+	// desc := "Check that a resident HIR binding used again while on the stack becomes LIR"
+	limit := 16 // room for 4 bindings, 3 of them LIR
+	c := NewLirs(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+
+		// k4 is above k1 on the stack, so it becomes LIR and demotes k1
+		NewOp(Get, "k4", &Record{b("v4"), true}),
+		NewOp(Set, "k5", b("v5"), true), // evicts k1
+		NewOp(Set, "k6", b("v6"), true), // evicts k5
+	})
+	CheckResident(t, c, []string{"k2", "k3", "k4", "k6"}, []string{"k1", "k5"})
+}
+
+func TestLirsHitRatio(t *testing.T) {
+	// desc := "Check that LIRS keeps part of a loop too large for the cache, unlike LRU"
+	limit := 64 * 8 // room for 64 bindings
+	trace := ScanTrace(48, 1, 40, 50)
+
+	lirs := ReplayHits(NewLirs(limit), trace)
+	lru := ReplayHits(NewPolicyCache(limit, NewLruPolicy()), trace)
+	if 2*lirs < len(trace) || lirs <= lru {
+		t.Errorf("LIRS hit %d and LRU hit %d of %d Gets; expected LIRS to hit more "+
+			"often than LRU, and at least half of the time", lirs, lru, len(trace))
+	}
+}
@@ -0,0 +1,83 @@
+package lru
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Loader fetches the values an LRU is missing from a backing store
+type Loader interface {
+	// Load returns the value bound to key in the backing store, or an error
+	// wrapping ErrNotFound if there is none
+	Load(key string) ([]byte, error)
+}
+
+// LoaderFunc lets an ordinary function be used as a Loader
+type LoaderFunc func(key string) ([]byte, error)
+
+func (f LoaderFunc) Load(key string) ([]byte, error) {
+	return f(key)
+}
+
+// FileLoader loads the value bound to each key from the file of that name in
+// Dir. Keys that are not plain file names are never found.
+type FileLoader struct {
+	Dir string
+}
+
+func (l FileLoader) Load(key string) ([]byte, error) {
+	if key == "" || key == "." || key == ".." || filepath.Base(key) != key {
+		return nil, fmt.Errorf("%q is not a file name: %w", key, ErrNotFound)
+	}
+	val, err := os.ReadFile(filepath.Join(l.Dir, key))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	return val, err
+}
+
+// HTTPLoader loads the value bound to each key with a GET of BaseURL followed
+// by the escaped key, using Client, or http.DefaultClient if Client is nil
+type HTTPLoader struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+func (l HTTPLoader) Load(key string) ([]byte, error) {
+	client := l.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Get(l.BaseURL + url.PathEscape(key))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("GET %s: %w", key, ErrNotFound)
+	case resp.StatusCode/100 != 2:
+		return nil, fmt.Errorf("GET %s: %s", key, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (lru *LRU) SetLoader(loader Loader) {
+}
+
+func (lru *LRU) Fetch(key string) ([]byte, error) {
+	return nil, nil
+}
+
+func (lru *LRU) SetStaleWhileRevalidate(window time.Duration) {
+}
+
+func (lru *LRU) SetRefreshAhead(window time.Duration) {
+}
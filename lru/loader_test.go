@@ -0,0 +1,402 @@
+package lru
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+/******************************************************************************
+ *                             Loader tests
+ ******************************************************************************/
+
+var errBackend = errors.New("backend unavailable")
+
+// FakeLoader is a Loader backed by a map, which counts the loads of each key
+// and fails loads of the keys in fail
+type FakeLoader struct {
+	mu    sync.Mutex
+	store map[string][]byte
+	fail  map[string]bool
+	loads map[string]int
+}
+
+func NewFakeLoader(store map[string][]byte) *FakeLoader {
+	return &FakeLoader{store: store, fail: make(map[string]bool), loads: make(map[string]int)}
+}
+
+func (l *FakeLoader) Load(key string) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.loads[key]++
+	if l.fail[key] {
+		return nil, errBackend
+	}
+	val, ok := l.store[key]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	return val, nil
+}
+
+// CheckLoads asserts that key has been loaded exp times
+func (l *FakeLoader) CheckLoads(t *testing.T, key string, exp int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if got := l.loads[key]; got != exp {
+		t.Errorf("%q was loaded %d times, expected %d", key, got, exp)
+	}
+}
+
+// CheckFetch asserts that lru.Fetch(key) returns exp and an error matching
+// expErr, which may be nil
+func CheckFetch(t *testing.T, lru *LRU, key string, exp []byte, expErr error) {
+	val, err := lru.Fetch(key)
+	if string(val) != string(exp) || (val == nil) != (exp == nil) || !errors.Is(err, expErr) ||
+		(err == nil) != (expErr == nil) {
+		t.Errorf(operationFailMessage, "Fetch", fmt.Sprintf("\"%s\"", key),
+			fmt.Sprintf("%q, %v", exp, expErr), fmt.Sprintf("%q, %v", val, err))
+	}
+}
+
+func TestLoaderFetch(t *testing.T) {
+	// desc := "Check that Fetch loads a missing binding once, and then finds it"
+	limit := 1024
+	lru := NewLru(limit)
+	loader := NewFakeLoader(map[string][]byte{"k1": b("v1"), "k2": b("v2")})
+	lru.SetLoader(loader)
+
+	CheckFetch(t, lru, "k1", b("v1"), nil)
+	CheckFetch(t, lru, "k1", b("v1"), nil)
+	loader.CheckLoads(t, "k1", 1)
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Peek, "k1", &Record{b("v1"), true}),
+		NewOp(Len, 1),
+		NewOp(Remaining, limit-len("k1v1")),
+	})
+	loader.CheckLoads(t, "k2", 0)
+}
+
+func TestLoaderGet(t *testing.T) {
+	// desc := "Check that Get transparently loads bindings it misses"
+	limit := 1024
+	lru := NewLru(limit)
+	loader := NewFakeLoader(map[string][]byte{"k1": b("v1")})
+	lru.SetLoader(loader)
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k2", &Record{nil, false}),
+		NewOp(Len, 1),
+	})
+	loader.CheckLoads(t, "k1", 1)
+	loader.CheckLoads(t, "k2", 1)
+
+	lru.SetLoader(nil)
+	ExecuteOperations(t, lru, []Operation{NewOp(Get, "k2", &Record{nil, false})})
+	CheckFetch(t, lru, "k2", nil, ErrNotFound)
+	CheckFetch(t, lru, "k1", b("v1"), nil)
+	loader.CheckLoads(t, "k2", 1)
+}
+
+func TestLoaderErrors(t *testing.T) {
+	// desc := "Check that load errors are returned, and nothing is cached for them"
+	lru := NewLru(1024)
+	loader := NewFakeLoader(map[string][]byte{"k1": b("v1")})
+	loader.fail["k1"] = true
+	lru.SetLoader(loader)
+
+	CheckFetch(t, lru, "k1", nil, errBackend)
+	CheckFetch(t, lru, "k2", nil, ErrNotFound)
+	ExecuteOperations(t, lru, []Operation{NewOp(Len, 0)})
+
+	// Failures are not remembered, so a later Fetch tries again
+	loader.fail["k1"] = false
+	CheckFetch(t, lru, "k1", b("v1"), nil)
+	loader.CheckLoads(t, "k1", 2)
+}
+
+func TestLoaderAccounting(t *testing.T) {
+	// desc := "Check that loaded bindings are charged and evicted like any other"
+	limit := 8
+	lru := NewLru(limit)
+	loader := NewFakeLoader(map[string][]byte{
+		"k1": b("v1"), "k2": b("v2"), "k3": b("v3"), "big": b("toolarge"),
+	})
+	lru.SetLoader(loader)
+
+	CheckFetch(t, lru, "k1", b("v1"), nil)
+	CheckFetch(t, lru, "k2", b("v2"), nil)
+	ExecuteOperations(t, lru, []Operation{NewOp(Remaining, 0)})
+	CheckFetch(t, lru, "k3", b("v3"), nil) // evicts k1
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Peek, "k1", &Record{nil, false}),
+		NewOp(Peek, "k2", &Record{b("v2"), true}),
+	})
+
+	// A value too large to cache is still returned, but not added
+	CheckFetch(t, lru, "big", b("toolarge"), nil)
+	CheckFetch(t, lru, "big", b("toolarge"), nil)
+	loader.CheckLoads(t, "big", 2)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Peek, "k2", &Record{b("v2"), true}),
+		NewOp(Len, 2),
+		NewOp(Remaining, 0),
+	})
+}
+
+func TestLoaderSingleFlight(t *testing.T) {
+	// desc := "Check that concurrent Fetches of one key share one load"
+	lru := NewLru(1024)
+	release := make(chan struct{})
+	loader := NewFakeLoader(map[string][]byte{"k1": b("v1")})
+	lru.SetLoader(LoaderFunc(func(key string) ([]byte, error) {
+		<-release
+		return loader.Load(key)
+	}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			CheckFetch(t, lru, "k1", b("v1"), nil)
+		}()
+	}
+	close(release)
+	wg.Wait()
+	loader.CheckLoads(t, "k1", 1)
+}
+
+func TestFileLoader(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "k1"), b("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lru := NewLru(1024)
+	lru.SetLoader(FileLoader{dir})
+
+	CheckFetch(t, lru, "k1", b("v1"), nil)
+	CheckFetch(t, lru, "k2", nil, ErrNotFound)
+	CheckFetch(t, lru, "../k1", nil, ErrNotFound)
+	CheckFetch(t, lru, "..", nil, ErrNotFound)
+}
+
+func TestHTTPLoader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/values/k1":
+			w.Write(b("v1"))
+		case "/values/a b":
+			w.Write(b("spaced"))
+		case "/values/broken":
+			http.Error(w, "oops", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	lru := NewLru(1024)
+	lru.SetLoader(HTTPLoader{BaseURL: server.URL + "/values/"})
+
+	CheckFetch(t, lru, "k1", b("v1"), nil)
+	CheckFetch(t, lru, "a b", b("spaced"), nil)
+	CheckFetch(t, lru, "k2", nil, ErrNotFound)
+	if _, err := lru.Fetch("broken"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected an error other than ErrNotFound for a 500, found %v", err)
+	}
+}
+
+/******************************************************************************
+ *                        Stale-while-revalidate tests
+ ******************************************************************************/
+
+// gatedLoader returns a Loader that waits on release before each load from
+// loader
+func gatedLoader(loader *FakeLoader, release chan struct{}) Loader {
+	return LoaderFunc(func(key string) ([]byte, error) {
+		<-release
+		return loader.Load(key)
+	})
+}
+
+func TestStaleServedOnce(t *testing.T) {
+	// desc := "Check that an expired binding is served once while it is refreshed"
+	lru, clock := NewLruWithClock(1024)
+	loader := NewFakeLoader(map[string][]byte{"k1": b("new")})
+	release := make(chan struct{})
+	lru.SetLoader(gatedLoader(loader, release))
+	lru.SetStaleWhileRevalidate(time.Minute)
+
+	CheckSetWithTTL(t, lru, "k1", b("old"), 10*time.Second, true)
+	clock.Advance(30 * time.Second)
+
+	// The load is held until release, so the stale binding is gone and the
+	// refresh has not landed
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Get, "k1", &Record{b("old"), true}),
+		NewOp(Peek, "k1", &Record{nil, false}),
+		NewOp(Len, 0),
+	})
+
+	// A Get during the refresh waits for it, rather than loading again
+	close(release)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Get, "k1", &Record{b("new"), true}),
+		NewOp(Len, 1),
+	})
+	loader.CheckLoads(t, "k1", 1)
+
+	// The refreshed binding has the stale binding's ttl
+	clock.Advance(9 * time.Second)
+	ExecuteOperations(t, lru, []Operation{NewOp(Peek, "k1", &Record{b("new"), true})})
+	clock.Advance(time.Second)
+	ExecuteOperations(t, lru, []Operation{NewOp(Peek, "k1", &Record{nil, false})})
+}
+
+func TestStaleWindow(t *testing.T) {
+	// desc := "Check that bindings expired for longer than the window load as misses"
+	lru, clock := NewLruWithClock(1024)
+	loader := NewFakeLoader(map[string][]byte{"k1": b("new"), "k2": b("new")})
+	lru.SetLoader(loader)
+	lru.SetStaleWhileRevalidate(time.Minute)
+
+	CheckSetWithTTL(t, lru, "k1", b("old"), 10*time.Second, true)
+	lru.Set("k2", b("old")) // never expires
+	clock.Advance(10*time.Second + time.Minute)
+
+	CheckFetch(t, lru, "k1", b("new"), nil)
+	CheckFetch(t, lru, "k2", b("old"), nil)
+	loader.CheckLoads(t, "k1", 1)
+	loader.CheckLoads(t, "k2", 0)
+
+	// Without the mode, or without a loader, expired bindings are just gone
+	CheckSetWithTTL(t, lru, "k3", b("old"), time.Second, true)
+	CheckSetWithTTL(t, lru, "k4", b("old"), time.Second, true)
+	clock.Advance(2 * time.Second)
+	lru.SetStaleWhileRevalidate(0)
+	ExecuteOperations(t, lru, []Operation{NewOp(Get, "k3", &Record{nil, false})})
+	lru.SetStaleWhileRevalidate(time.Minute)
+	lru.SetLoader(nil)
+	ExecuteOperations(t, lru, []Operation{NewOp(Get, "k4", &Record{nil, false})})
+}
+
+func TestStaleRefreshFails(t *testing.T) {
+	// desc := "Check that a failed refresh binds nothing, and is not remembered"
+	lru, clock := NewLruWithClock(1024)
+	loader := NewFakeLoader(map[string][]byte{"k1": b("new")})
+	loader.fail["k1"] = true
+	release := make(chan struct{})
+	lru.SetLoader(gatedLoader(loader, release))
+	lru.SetStaleWhileRevalidate(time.Minute)
+
+	CheckSetWithTTL(t, lru, "k1", b("old"), 10*time.Second, true)
+	clock.Advance(10 * time.Second)
+	ExecuteOperations(t, lru, []Operation{NewOp(Get, "k1", &Record{b("old"), true})})
+
+	// Whether it waits for the refresh or loads again, the Get fails
+	close(release)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Get, "k1", &Record{nil, false}),
+		NewOp(Len, 0),
+	})
+
+	loader.mu.Lock()
+	loader.fail["k1"] = false
+	loader.mu.Unlock()
+	CheckFetch(t, lru, "k1", b("new"), nil)
+	ExecuteOperations(t, lru, []Operation{NewOp(Len, 1)})
+}
+
+/******************************************************************************
+ *                            Refresh-ahead tests
+ ******************************************************************************/
+
+// waitUntil fails the test unless cond becomes true within computeTimeout,
+// as a background refresh lands
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(computeTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting until %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRefreshAhead(t *testing.T) {
+	// desc := "Check that a binding used shortly before it expires is refreshed in the background"
+	lru, clock := NewLruWithClock(1024)
+	loader := NewFakeLoader(map[string][]byte{"k1": b("new")})
+	release := make(chan struct{})
+	lru.SetLoader(gatedLoader(loader, release))
+	lru.SetRefreshAhead(3 * time.Second)
+
+	CheckSetWithTTL(t, lru, "k1", b("old"), 10*time.Second, true)
+	clock.Advance(6 * time.Second)
+	ExecuteOperations(t, lru, []Operation{NewOp(Get, "k1", &Record{b("old"), true})})
+	loader.CheckLoads(t, "k1", 0)
+
+	// Within the window, Gets still find the old value while the refresh runs
+	clock.Advance(2 * time.Second)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Get, "k1", &Record{b("old"), true}),
+		NewOp(Get, "k1", &Record{b("old"), true}),
+	})
+	close(release)
+	waitUntil(t, "k1 is refreshed", func() bool {
+		val, _ := lru.Peek("k1")
+		return string(val) == "new"
+	})
+	loader.CheckLoads(t, "k1", 1)
+
+	// The refreshed binding has a new ttl of 10 seconds
+	clock.Advance(9 * time.Second)
+	ExecuteOperations(t, lru, []Operation{NewOp(Peek, "k1", &Record{b("new"), true})})
+	clock.Advance(time.Second)
+	ExecuteOperations(t, lru, []Operation{NewOp(Peek, "k1", &Record{nil, false})})
+}
+
+func TestRefreshAheadOncePerWindow(t *testing.T) {
+	// desc := "Check that a failed refresh is not retried before the binding expires"
+	lru, clock := NewLruWithClock(1024)
+	loader := NewFakeLoader(map[string][]byte{})
+	loader.fail["k1"] = true
+	lru.SetLoader(loader)
+	lru.SetRefreshAhead(5 * time.Second)
+
+	CheckSetWithTTL(t, lru, "k1", b("old"), 10*time.Second, true)
+	lru.Set("k2", b("v2")) // never expires, so never refreshed
+	clock.Advance(6 * time.Second)
+	ExecuteOperations(t, lru, []Operation{NewOp(Get, "k1", &Record{b("old"), true})})
+	waitUntil(t, "k1 is loaded", func() bool {
+		loader.mu.Lock()
+		defer loader.mu.Unlock()
+		return loader.loads["k1"] == 1
+	})
+
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Second)
+		ExecuteOperations(t, lru, []Operation{
+			NewOp(Get, "k1", &Record{b("old"), true}),
+			NewOp(Get, "k2", &Record{b("v2"), true}),
+		})
+	}
+	loader.CheckLoads(t, "k1", 1)
+	loader.CheckLoads(t, "k2", 0)
+
+	// Once expired, the binding loads as a miss
+	clock.Advance(time.Second)
+	ExecuteOperations(t, lru, []Operation{NewOp(Get, "k1", &Record{nil, false})})
+	loader.CheckLoads(t, "k1", 2)
+}
@@ -0,0 +1,46 @@
+package lru
+
+import "sync"
+
+// Logger receives structured entries describing what an LRU did and why
+type Logger interface {
+	Log(entry LogEntry)
+}
+
+// LogEntry describes one eviction, rejected binding or failure
+type LogEntry struct {
+	Msg       string // "evicted", "rejected" or "failed"
+	Key       string // the binding's key
+	Size      int    // storage the binding was, or would have been, charged
+	Remaining int    // RemainingStorage once the entry was logged
+	Err       error  // why the binding was rejected or what failed, if it was
+}
+
+// LogFunc lets an ordinary function be used as a Logger
+type LogFunc func(entry LogEntry)
+
+func (f LogFunc) Log(entry LogEntry) {
+	f(entry)
+}
+
+// MemoryLogger keeps every entry logged to it, for tests and debugging
+type MemoryLogger struct {
+	mu      sync.Mutex
+	entries []LogEntry
+}
+
+func (l *MemoryLogger) Log(entry LogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+// Entries returns the entries logged so far, oldest first
+func (l *MemoryLogger) Entries() []LogEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]LogEntry(nil), l.entries...)
+}
+
+func (lru *LRU) SetLogger(logger Logger) {
+}
@@ -0,0 +1,142 @@
+package lru
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+/******************************************************************************
+ *                              Logging tests
+ ******************************************************************************/
+
+// refuseAdmitter turns away every candidate that would displace a binding
+type refuseAdmitter struct{}
+
+func (refuseAdmitter) Record(key string)                   {}
+func (refuseAdmitter) Admit(candidate, victim string) bool { return false }
+
+// CheckLog asserts that logger has been given exactly expected since it was
+// last checked. Errors are compared with errors.Is.
+func CheckLog(t *testing.T, logger *MemoryLogger, expected []LogEntry) {
+	t.Helper()
+	got := logger.Entries()
+	logger.entries = nil
+
+	same := len(got) == len(expected)
+	for i := 0; same && i < len(got); i++ {
+		g, e := got[i], expected[i]
+		same = g.Msg == e.Msg && g.Key == e.Key && g.Size == e.Size &&
+			g.Remaining == e.Remaining && errors.Is(g.Err, e.Err) && (g.Err == nil) == (e.Err == nil)
+	}
+	if !same {
+		t.Errorf(operationFailMessage, "Log", &Args{}, Expected{fmt.Sprintf("%+v", expected)},
+			Expected{fmt.Sprintf("%+v", got)})
+	}
+}
+
+func TestLogEvictions(t *testing.T) {
+	// desc := "Check that every binding passed to the eviction callback is logged"
+	lru := NewLru(8)
+	logger := new(MemoryLogger)
+	lru.SetLogger(logger)
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true), // evicts k1
+		NewOp(Remove, "k2", &Record{b("v2"), true}),
+	})
+	CheckLog(t, logger, []LogEntry{{"evicted", "k1", 4, 0, nil}})
+
+	lru.Set("k4", b("v4"))
+	lru.Purge()
+	CheckLog(t, logger, []LogEntry{
+		{"evicted", "k3", 4, 4, nil},
+		{"evicted", "k4", 4, 8, nil},
+	})
+}
+
+func TestLogRejections(t *testing.T) {
+	// desc := "Check that every binding Set refuses is logged with the reason"
+	lru := NewLruWithOptions(16, Options{MaxKeySize: 4, MaxValueSize: 8})
+	logger := new(MemoryLogger)
+	lru.SetLogger(logger)
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "toolong", b("v"), false),
+		NewOp(Set, "k2", b("123456789"), false),
+	})
+	lru.Append("k1", b("123456789")) // too large once appended
+	CheckLog(t, logger, []LogEntry{
+		{"rejected", "toolong", 8, 12, ErrKeyTooLong},
+		{"rejected", "k2", 11, 12, ErrTooLarge},
+		{"rejected", "k1", 13, 12, ErrTooLarge},
+	})
+
+	lru.SetAdmitter(refuseAdmitter{})
+	lru.Set("k2", b("v2"))
+	lru.Set("k3", b("v3"))
+	lru.Set("k4", b("v4"))
+	lru.Set("k5", b("v5")) // would displace k1
+	CheckLog(t, logger, []LogEntry{{"rejected", "k5", 4, 0, ErrRejected}})
+
+	lru.Close()
+	lru.Set("k6", b("v6"))
+	CheckLog(t, logger, []LogEntry{{"rejected", "k6", 4, 0, ErrClosed}})
+}
+
+func TestLogFailures(t *testing.T) {
+	// desc := "Check that bindings the store or the log cannot take are logged with the error"
+	lru := NewLru(8)
+	logger := new(MemoryLogger)
+	lru.SetLogger(logger)
+	store := NewFakeStore()
+	store.fail["k1"] = true
+	lru.SetStore(store, WriteThrough)
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(SetE, "k1", b("v1"), errBackend),
+		NewOp(Set, "k2", b("v2"), true),
+	})
+	CheckLog(t, logger, []LogEntry{{"failed", "k1", 4, 8, errBackend}})
+
+	// Write-back failures are logged when the binding leaves
+	lru.SetStore(store, WriteBack)
+	lru.Set("k1", b("v1"))
+	lru.Remove("k1")
+	CheckLog(t, logger, []LogEntry{{"failed", "k1", 4, 4, errBackend}})
+
+	errDisk := errors.New("disk on fire")
+	lru.SetLog(failingWriter{errDisk})
+	lru.Set("k3", b("v3"))
+	lru.Remove("k2")
+	CheckLog(t, logger, []LogEntry{
+		{"failed", "k3", 4, 4, errDisk},
+		{"failed", "k2", 4, 4, errDisk},
+	})
+}
+
+func TestLogStops(t *testing.T) {
+	// desc := "Check that SetLogger(nil) stops logging, and that a LogFunc can be used"
+	lru := NewLru(4)
+	var buf bytes.Buffer
+	lru.SetLogger(LogFunc(func(entry LogEntry) {
+		fmt.Fprintf(&buf, "%s %s %d %d %v\n", entry.Msg, entry.Key, entry.Size, entry.Remaining, entry.Err)
+	}))
+	lru.Set("k1", b("v1"))
+	lru.Set("k2", b("v2"))
+	lru.Set("toolarge", b("v"))
+
+	lru.SetLogger(nil)
+	lru.Set("k3", b("v3"))
+	lru.Set("toolarge", b("v"))
+
+	expected := "evicted k1 4 0 <nil>\n" +
+		"rejected toolarge 9 0 " + ErrTooLarge.Error() + "\n"
+	if buf.String() != expected {
+		t.Errorf("Expected the log\n%s\nfound\n%s", expected, buf.String())
+	}
+}
@@ -1,5 +1,70 @@
 package lru
 
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrClosed is returned by Close when the LRU was already closed, and by
+	// methods that report errors, such as SetE and Incr, once it is closed
+	ErrClosed = errors.New("lru: use of closed LRU")
+
+	// ErrNotFound is returned by Incr and Fetch when no binding exists for
+	// the key, and Fetch has no loader to load one
+	ErrNotFound = errors.New("lru: key not found")
+
+	// ErrNotNumber is returned by Incr when a value is not a decimal integer
+	ErrNotNumber = errors.New("lru: value is not a decimal integer")
+
+	// ErrOverflow is returned by Incr when the result does not fit in an int64
+	ErrOverflow = errors.New("lru: integer overflow")
+
+	// ErrTooLarge is returned when a binding cannot fit in the LRU, even by
+	// evicting every unpinned binding, or its value is longer than
+	// Options.MaxValueSize
+	ErrTooLarge = errors.New("lru: binding larger than LRU capacity")
+
+	// ErrKeyTooLong is returned when a key is longer than Options.MaxKeySize
+	ErrKeyTooLong = errors.New("lru: key too long")
+
+	// ErrRejected is returned when an LRU's Admitter turns a binding away
+	ErrRejected = errors.New("lru: binding rejected by admitter")
+
+	// ErrCorrupt is returned by Load and Recover when their input is damaged
+	ErrCorrupt = errors.New("lru: corrupt snapshot or log")
+
+	// ErrRegistered is returned by Register when the name is already taken
+	ErrRegistered = errors.New("lru: cache name already registered")
+
+	// ErrNotRegistered is returned by Unregister when no cache has the name
+	ErrNotRegistered = errors.New("lru: no cache registered under name")
+)
+
+// Options limits the bindings an LRU accepts, and how it stores them. Zero
+// values mean no limit, no compression and no batch eviction.
+type Options struct {
+	MaxKeySize    int     // in bytes
+	MaxValueSize  int     // in bytes, before compression
+	CompressAbove int     // compress values longer than this many bytes
+	HighWatermark float64 // evict once more than this fraction is in use...
+	LowWatermark  float64 // ...until no more than this fraction is
+	IndexKeys     bool    // keep keys in order, for ScanPrefix
+	CopyOnWrite   bool    // keep copies of the values callers store
+	CopyOnRead    bool    // hand callers copies of the values they read
+	Arena         bool    // keep small values in reusable chunks
+	OffHeap       bool    // experimental: keep values in one big buffer
+}
+
+// CostFunc returns the storage charged for binding key to val
+type CostFunc func(key string, val []byte) int
+
+// KeyValue is a single binding passed to SetMulti
+type KeyValue struct {
+	Key   string
+	Value []byte
+}
+
 type LRU struct {
 	// whatever fields you want here
 }
@@ -8,6 +73,10 @@ func NewLru(limit int) *LRU {
 	return new(LRU)
 }
 
+func NewLruWithOptions(limit int, opts Options) *LRU {
+	return new(LRU)
+}
+
 func (lru *LRU) MaxStorage() int {
 	return 0
 }
@@ -20,10 +89,30 @@ func (lru *LRU) Get(key string) (value []byte, ok bool) {
 	return nil, false
 }
 
+// EntryInfo describes the life of a binding so far
+type EntryInfo struct {
+	Inserted   time.Time // when the binding was stored
+	LastAccess time.Time // when it was last accessed, or Inserted
+	Accesses   int       // times it has been accessed since it was stored
+	Size       int       // storage charged for it
+}
+
+func (lru *LRU) GetWithInfo(key string) (value []byte, info EntryInfo, ok bool) {
+	return nil, info, false
+}
+
+func (lru *LRU) GetVersioned(key string) (value []byte, version uint64, ok bool) {
+	return nil, 0, false
+}
+
 func (lru *LRU) Remove(key string) (value []byte, ok bool) {
 	return nil, false
 }
 
+func (lru *LRU) RemovePrefix(prefix string) int {
+	return 0
+}
+
 func (lru *LRU) Set(key string, value []byte) bool {
 	return false
 }
@@ -31,3 +120,110 @@ func (lru *LRU) Set(key string, value []byte) bool {
 func (lru *LRU) Len() int {
 	return 0
 }
+
+func (lru *LRU) SetEvictedCallback(callback func(key string, val []byte)) {
+}
+
+func (lru *LRU) Peek(key string) (value []byte, ok bool) {
+	return nil, false
+}
+
+func (lru *LRU) Contains(key string) bool {
+	return false
+}
+
+func (lru *LRU) Keys() []string {
+	return nil
+}
+
+func (lru *LRU) ScanPrefix(prefix string) []string {
+	return nil
+}
+
+func (lru *LRU) Match(pattern string) []string {
+	return nil
+}
+
+func (lru *LRU) Range(f func(key string, val []byte) bool) {
+}
+
+func (lru *LRU) Touch(key string) bool {
+	return false
+}
+
+func (lru *LRU) Oldest() (key string, ok bool) {
+	return "", false
+}
+
+func (lru *LRU) Newest() (key string, ok bool) {
+	return "", false
+}
+
+func (lru *LRU) Purge() {
+}
+
+func (lru *LRU) Resize(newMax int) int {
+	return 0
+}
+
+func (lru *LRU) EvictOldest(n int) int {
+	return 0
+}
+
+func (lru *LRU) FreeBytes(target int) int {
+	return 0
+}
+
+func (lru *LRU) Close() error {
+	return nil
+}
+
+func (lru *LRU) GetOrCompute(key string, compute func() ([]byte, error)) ([]byte, error) {
+	return nil, nil
+}
+
+func (lru *LRU) Add(key string, value []byte) bool {
+	return false
+}
+
+func (lru *LRU) CAS(key string, old, new []byte) bool {
+	return false
+}
+
+func (lru *LRU) Append(key string, suffix []byte) bool {
+	return false
+}
+
+func (lru *LRU) Prepend(key string, prefix []byte) bool {
+	return false
+}
+
+func (lru *LRU) Incr(key string, delta int64) (int64, error) {
+	return 0, nil
+}
+
+func (lru *LRU) GetMulti(keys []string) (values [][]byte, ok []bool) {
+	return nil, nil
+}
+
+func (lru *LRU) SetMulti(bindings []KeyValue, allOrNothing bool) []bool {
+	return nil
+}
+
+func (lru *LRU) Pin(key string) bool {
+	return false
+}
+
+func (lru *LRU) Unpin(key string) bool {
+	return false
+}
+
+func (lru *LRU) SetCostFunc(cost CostFunc) {
+}
+
+func (lru *LRU) SetEntryOverhead(overhead int) {
+}
+
+func (lru *LRU) SetE(key string, value []byte) error {
+	return nil
+}
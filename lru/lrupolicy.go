@@ -0,0 +1,27 @@
+package lru
+
+// LruPolicy evicts the least recently used binding, where adding, updating
+// and accessing a binding all count as using it
+type LruPolicy struct {
+	// whatever fields you want here
+}
+
+func NewLruPolicy() *LruPolicy {
+	return new(LruPolicy)
+}
+
+func (p *LruPolicy) Add(key string, size int) {
+}
+
+func (p *LruPolicy) Update(key string, size int) {
+}
+
+func (p *LruPolicy) Access(key string) {
+}
+
+func (p *LruPolicy) Remove(key string) {
+}
+
+func (p *LruPolicy) Evict() string {
+	return ""
+}
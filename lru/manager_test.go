@@ -0,0 +1,211 @@
+package lru
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+/******************************************************************************
+ *                              Namespace tests
+ ******************************************************************************/
+
+func TestManagerNamespaces(t *testing.T) {
+	// desc := "Check that namespaces bind keys independently within one budget"
+	limit := 32
+	m := NewManager(limit)
+	a, b2 := m.Namespace("a"), m.Namespace("b")
+	if m.Namespace("a") != a || a.Name() != "a" {
+		t.Errorf("Expected Namespace to return the existing namespace named \"a\"")
+	}
+
+	ExecuteCacheOperations(t, a, []Operation{
+		NewOp(Max, limit),
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+	})
+	ExecuteCacheOperations(t, b2, []Operation{
+		NewOp(Get, "k1", &Record{nil, false}),
+		NewOp(Set, "k1", b("w1"), true),
+		NewOp(Remaining, limit-4),
+		NewOp(Len, 1),
+	})
+	ExecuteCacheOperations(t, a, []Operation{
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Remove, "k1", &Record{b("v1"), true}),
+		NewOp(Remaining, limit-4),
+	})
+	ExecuteCacheOperations(t, b2, []Operation{NewOp(Get, "k1", &Record{b("w1"), true})})
+
+	if m.MaxStorage() != limit || m.RemainingStorage() != limit-8 || m.Len() != 2 {
+		t.Errorf("Expected the Manager to hold 2 bindings in 8 of %d bytes, found %d in %d of %d",
+			limit, m.Len(), m.MaxStorage()-m.RemainingStorage(), m.MaxStorage())
+	}
+	if names := m.Names(); fmt.Sprint(names) != "[a b]" {
+		t.Errorf(operationFailMessage, "Names", &Args{}, Expected{[]string{"a", "b"}}, Expected{names})
+	}
+}
+
+func TestManagerQuota(t *testing.T) {
+	// desc := "Check that a namespace evicts its own bindings to stay within its quota"
+	m := NewManager(64)
+	a, other := m.Namespace("a"), m.Namespace("other")
+	other.SetQuota(16)
+	other.Set("o1", b("v1"))
+	a.SetQuota(12)
+
+	ExecuteCacheOperations(t, a, []Operation{
+		NewOp(Max, 12),
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "k4", b("v4"), true), // evicts k2
+		NewOp(Get, "k2", &Record{nil, false}),
+		NewOp(Set, "toolarge", b("12345"), false),
+		NewOp(Remaining, 0),
+	})
+	if evicted := a.SetQuota(4); evicted != 2 {
+		t.Errorf(operationFailMessage, "SetQuota", "4", Expected{2}, Expected{evicted})
+	}
+	ExecuteCacheOperations(t, a, []Operation{
+		NewOp(Get, "k3", &Record{nil, false}),
+		NewOp(Get, "k1", &Record{nil, false}),
+		NewOp(Get, "k4", &Record{b("v4"), true}),
+		NewOp(Len, 1),
+	})
+	ExecuteCacheOperations(t, other, []Operation{NewOp(Get, "o1", &Record{b("v1"), true})})
+}
+
+func TestManagerShares(t *testing.T) {
+	// desc := "Check that shares are quotas, scaled down when the quotas overcommit the limit"
+	m := NewManager(30)
+	a, b2 := m.Namespace("a"), m.Namespace("b")
+	a.SetQuota(10)
+	b2.SetQuota(20)
+	if a.Share() != 10 || b2.Share() != 20 {
+		t.Errorf("Expected shares of 10 and 20, found %d and %d", a.Share(), b2.Share())
+	}
+
+	c := m.Namespace("c") // quota 30
+	if a.Share() != 5 || b2.Share() != 10 || c.Share() != 15 {
+		t.Errorf("Expected shares of 5, 10 and 15, found %d, %d and %d", a.Share(), b2.Share(), c.Share())
+	}
+	ExecuteCacheOperations(t, a, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v222"), false), // larger than a's share
+		NewOp(Max, 10),
+	})
+}
+
+func TestManagerIsolation(t *testing.T) {
+	// desc := "Check that one namespace's churn never evicts another within its share"
+	m := NewManager(64)
+	quiet, noisy := m.Namespace("quiet"), m.Namespace("noisy")
+	quiet.SetQuota(32)
+	noisy.SetQuota(32)
+
+	for i := 0; i < 8; i++ {
+		quiet.Set(fmt.Sprintf("q%d", i), b("v"))
+	}
+	for i := 0; i < 1000; i++ {
+		if !noisy.Set(fmt.Sprintf("n%03d", i), b("v")) {
+			t.Fatalf("Expected noisy to stay able to Set")
+		}
+	}
+	for i := 0; i < 8; i++ {
+		ExecuteCacheOperations(t, quiet, []Operation{NewOp(Get, fmt.Sprintf("q%d", i), &Record{b("v"), true})})
+	}
+	ExecuteCacheOperations(t, noisy, []Operation{NewOp(Len, 6), NewOp(Remaining, 2)})
+}
+
+func TestManagerOvercommitted(t *testing.T) {
+	// desc := "Check that once the quotas overcommit the limit, a Set only evicts from namespaces over their share"
+	m := NewManager(32)
+	a, b2 := m.Namespace("a"), m.Namespace("b") // shares of 16 each
+
+	// a fills the Manager while b is empty
+	for i := 1; i <= 8; i++ {
+		ExecuteCacheOperations(t, a, []Operation{NewOp(Set, fmt.Sprintf("a%d", i), b("v1"), true)})
+	}
+	ExecuteCacheOperations(t, a, []Operation{NewOp(Get, "a1", &Record{b("v1"), true})})
+
+	// b's Sets take a's least recently used bindings until a is down to its share
+	for i := 1; i <= 4; i++ {
+		ExecuteCacheOperations(t, b2, []Operation{NewOp(Set, fmt.Sprintf("b%d", i), b("v1"), true)})
+	}
+	ExecuteCacheOperations(t, a, []Operation{
+		NewOp(Get, "a2", &Record{nil, false}),
+		NewOp(Get, "a5", &Record{nil, false}),
+		NewOp(Get, "a1", &Record{b("v1"), true}),
+		NewOp(Len, 4),
+	})
+
+	// Then b, at its share, evicts its own
+	ExecuteCacheOperations(t, b2, []Operation{
+		NewOp(Set, "b5", b("v1"), true),
+		NewOp(Get, "b1", &Record{nil, false}),
+		NewOp(Len, 4),
+	})
+	ExecuteCacheOperations(t, a, []Operation{NewOp(Len, 4)})
+	if m.RemainingStorage() != 0 {
+		t.Errorf(operationFailMessage, "RemainingStorage", &Args{}, Expected{0}, Expected{m.RemainingStorage()})
+	}
+}
+
+func TestManagerDrop(t *testing.T) {
+	// desc := "Check that dropping a namespace frees its storage and leaves its handle empty"
+	m := NewManager(16)
+	a := m.Namespace("a")
+	a.Set("k1", b("v1"))
+	m.Namespace("b").Set("k1", b("v1"))
+
+	if !m.Drop("a") || m.Drop("a") {
+		t.Errorf("Expected Drop to succeed once")
+	}
+	ExecuteCacheOperations(t, a, []Operation{
+		NewOp(Get, "k1", &Record{nil, false}),
+		NewOp(Set, "k2", b("v2"), false),
+		NewOp(Len, 0),
+	})
+	if m.RemainingStorage() != 12 || m.Len() != 1 || fmt.Sprint(m.Names()) != "[b]" {
+		t.Errorf("Expected only b's binding to be left, found %d bindings in namespaces %v",
+			m.Len(), m.Names())
+	}
+	if fresh := m.Namespace("a"); fresh == a || fresh.Len() != 0 {
+		t.Errorf("Expected Namespace to create a new, empty namespace after Drop")
+	}
+}
+
+func TestManagerConcurrent(t *testing.T) {
+	// desc := "Check that namespaces of one Manager survive concurrent use"
+	m := NewManager(capacityOr(64 * 8))
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		ns := m.Namespace(fmt.Sprintf("ns%d", g))
+		ns.SetQuota(16 * 8)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < opsOr(2000); i++ {
+				key := fmt.Sprintf("k%03d", i%100)
+				ns.Set(key, b("1234"))
+				ns.Get(key)
+				if i%7 == 0 {
+					ns.Remove(key)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	used := 0
+	for _, name := range m.Names() {
+		ns := m.Namespace(name)
+		used += ns.MaxStorage() - ns.RemainingStorage()
+	}
+	if used != m.MaxStorage()-m.RemainingStorage() || used != 8*m.Len() || used > m.MaxStorage() {
+		t.Errorf("After concurrent use, the namespaces use %d bytes, but the Manager uses %d for %d bindings",
+			used, m.MaxStorage()-m.RemainingStorage(), m.Len())
+	}
+}
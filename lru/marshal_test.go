@@ -0,0 +1,196 @@
+package lru
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+/******************************************************************************
+ *                             Marshaling tests
+ ******************************************************************************/
+// The golden files in testdata lock down the wire format. If you change the
+// format on purpose, regenerate them with:
+//
+//	go test -run MarshalGolden -lru.update-golden
+
+var updateGolden = flag.Bool("lru.update-golden", false,
+	"rewrite the golden files in testdata instead of checking against them")
+
+var (
+	_ encoding.BinaryMarshaler   = (*LRU)(nil)
+	_ encoding.BinaryUnmarshaler = (*LRU)(nil)
+)
+
+// goldenCaches returns the caches whose encodings are kept in testdata, by
+// file name. They use clock for bindings that expire.
+func goldenCaches(clock Clock) map[string]*LRU {
+	empty := NewLru(64)
+
+	bindings := NewLru(1024)
+	bindings.SetClock(clock)
+	bindings.Set("k1", b("v1"))
+	bindings.SetWithTTL("k2", b("v2"), time.Hour)
+	bindings.Set("k3", b(""))
+	bindings.Get("k1")
+
+	return map[string]*LRU{"empty.golden": empty, "bindings.golden": bindings}
+}
+
+// hexMismatch reports that the bytes called name differ from what was expected
+func hexMismatch(name string, expected, found []byte) string {
+	return fmt.Sprintf("%s: expected\n%x\nfound\n%x", name, expected, found)
+}
+
+// CheckBytes fails the test, showing both in hex, unless the bytes called name
+// are exactly those expected
+func CheckBytes(t testing.TB, name string, expected, found []byte) {
+	if !bytes.Equal(found, expected) {
+		t.Error(hexMismatch(name, expected, found))
+	}
+}
+
+func TestMarshalGolden(t *testing.T) {
+	// desc := "Check that MarshalBinary writes exactly what the golden files hold"
+	for name, lru := range goldenCaches(NewFakeClock()) {
+		data, err := lru.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: MarshalBinary: %v", name, err)
+		}
+
+		path := filepath.Join("testdata", name)
+		if *updateGolden {
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		golden, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		CheckBytes(t, name, golden, data)
+	}
+}
+
+func TestUnmarshalGolden(t *testing.T) {
+	// desc := "Check that UnmarshalBinary restores capacity, bindings and recency"
+	clock := NewFakeClock()
+	for name, original := range goldenCaches(clock) {
+		golden, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var lru LRU
+		lru.SetClock(clock)
+		if err := lru.UnmarshalBinary(golden); err != nil {
+			t.Fatalf("%s: UnmarshalBinary: %v", name, err)
+		}
+		ExecuteOperations(t, &lru, []Operation{
+			NewOp(Max, original.MaxStorage()),
+			NewOp(Remaining, original.RemainingStorage()),
+		})
+		CheckKeys(t, &lru, original.Keys())
+		for _, key := range original.Keys() {
+			val, _ := original.Peek(key)
+			ExecuteOperations(t, &lru, []Operation{NewOp(Peek, key, &Record{val, true})})
+		}
+	}
+}
+
+func TestUnmarshalReplaces(t *testing.T) {
+	// desc := "Check that UnmarshalBinary replaces an LRU's capacity and bindings"
+	original := NewLru(8)
+	original.Set("k1", b("v1"))
+	data, err := original.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lru := NewLru(1024)
+	lru.Set("k2", b("v2"))
+	if err := lru.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	CheckKeys(t, lru, []string{"k1"})
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Max, 8),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true), // evicts k1
+		NewOp(Peek, "k1", &Record{nil, false}),
+	})
+}
+
+func TestUnmarshalCorrupt(t *testing.T) {
+	// desc := "Check that UnmarshalBinary rejects damaged data, changing nothing"
+	original := NewLru(1024)
+	original.Set("k1", b("v1"))
+	data, err := original.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lru := NewLru(16)
+	lru.Set("k0", b("v0"))
+	check := func(input []byte) {
+		if err := lru.UnmarshalBinary(input); !errors.Is(err, ErrCorrupt) {
+			t.Errorf(operationFailMessage, "UnmarshalBinary", fmt.Sprintf("%x", input),
+				Expected{ErrCorrupt}, Expected{err})
+		}
+		CheckKeys(t, lru, []string{"k0"})
+		ExecuteOperations(t, lru, []Operation{NewOp(Max, 16)})
+	}
+
+	for n := 0; n < len(data); n++ {
+		check(data[:n])
+	}
+	check(append(append([]byte(nil), data...), 0))
+	for i := range data {
+		damaged := append([]byte(nil), data...)
+		damaged[i] ^= 0x10
+		check(damaged)
+	}
+}
+
+// Session is an example of a structure with an LRU inside it
+type Session struct {
+	User  string
+	Cache *LRU
+}
+
+func TestMarshalGob(t *testing.T) {
+	// desc := "Check that an LRU survives being gob-encoded inside another structure"
+	session := Session{"alice", NewLru(12)}
+	session.Cache.Set("k1", b("v1"))
+	session.Cache.Set("k2", b("v2"))
+	session.Cache.Get("k1")
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(session); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Session
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.User != "alice" || decoded.Cache == nil {
+		t.Fatalf("Expected alice's session, found %+v", decoded)
+	}
+	CheckKeys(t, decoded.Cache, []string{"k2", "k1"})
+	ExecuteOperations(t, decoded.Cache, []Operation{
+		NewOp(Max, 12),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true), // evicts k2
+		NewOp(Peek, "k2", &Record{nil, false}),
+		NewOp(Peek, "k1", &Record{b("v1"), true}),
+	})
+}
@@ -0,0 +1,358 @@
+package lru
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Failure message tests
+ ******************************************************************************/
+// The autograder's log parsers extract scores from the exact text of the
+// harness's failure messages, so testdata/messages.golden locks down how each
+// kind of failure is rendered. If you change a message on purpose, update the
+// parsers, then regenerate the golden file, with a working LRU, by running:
+//
+//	go test -run FailureMessagesGolden -lru.update-golden
+
+// recordingTB is a testing.TB that records the failures and logs reported to
+// it, as go test would print them, instead of failing the test
+type recordingTB struct {
+	testing.TB
+	failed bool
+	out    strings.Builder
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Failed() bool {
+	return r.failed
+}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.failed = true
+	r.Logf(format, args...)
+}
+
+func (r *recordingTB) Error(args ...interface{}) {
+	r.failed = true
+	r.Logf("%s", fmt.Sprintln(args...))
+}
+
+func (r *recordingTB) Logf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+	r.out.WriteString(msg)
+}
+
+// failureCases cause one failure of every kind the harness reports, each by
+// running the harness code that reports it. Those that run operations expect
+// the wrong results from a working LRU.
+var failureCases = []struct {
+	name string
+	fail func(t testing.TB)
+}{
+	{"get", func(t testing.TB) {
+		lru := NewLru(8)
+		lru.Set("k1", b("v1"))
+		ExecuteOperation(t, lru, NewOp(Get, "k1", &Record{b("v2"), true}))
+	}},
+	{"get-miss", func(t testing.TB) {
+		ExecuteOperation(t, NewLru(8), NewOp(Get, "", &Record{b(""), true}))
+	}},
+	{"set-binary", func(t testing.TB) {
+		ExecuteOperation(t, NewLru(8), NewOp(Set, "k\x00", b("\x00\xffv"), false))
+	}},
+	{"set-error", func(t testing.TB) {
+		ExecuteOperation(t, NewLru(4), NewOp(SetE, "k1", b("v1long"), nil))
+	}},
+	{"len", func(t testing.TB) {
+		lru := NewLru(8)
+		lru.Set("k1", b("v1"))
+		ExecuteOperation(t, lru, NewOp(Len, 2))
+	}},
+	{"resize", func(t testing.TB) {
+		lru := NewLru(8)
+		lru.Set("k1", b("v1"))
+		lru.Set("k2", b("v2"))
+		ExecuteOperation(t, lru, NewOp(Resize, 4, 0))
+	}},
+	{"stats", func(t testing.TB) {
+		ExecuteOperation(t, NewLru(8), NewOp(Stat, Stats{Hits: 1, Misses: 2, Evictions: 3, BytesEvicted: 4, Utilization: 0.5}))
+	}},
+	{"section", func(t testing.TB) {
+		ExecuteOperation(t, NewLru(8), NewOp(Len, 1).In("fill phase"))
+	}},
+	{"eviction", func(t testing.TB) {
+		CheckEvicted(t, map[string][]byte{"k1": b("v1")}, "k1", b("v2"))
+	}},
+	{"evictions", func(t testing.TB) {
+		CheckEvictions(t, []Binding{{"k2", b("v2")}}, []Binding{{"k1", b("v1")}, {"k2", b("v2")}})
+	}},
+	{"eviction-order", func(t testing.TB) {
+		defer func(strict, stats bool) {
+			*strictEvictions, *checkStats = strict, stats
+		}(*strictEvictions, *checkStats)
+		*strictEvictions, *checkStats = true, false
+
+		// The model has room for three bindings, but the LRU only for two
+		ExecuteEvictionTraceNoSubtests(t, NewLru(8), 12, []Operation{
+			NewOp(Set, "k1", b("v1"), true),
+			NewOp(Set, "k2", b("v2"), true),
+			NewOp(Set, "k3", b("v3"), true),
+		})
+	}},
+	{"panic-error", func(t testing.TB) {
+		lru := NewLru(4)
+		lru.Set("k1", b("v1"))
+		lru.SetEvictedCallback(func(string, []byte) {
+			panic(errors.New("runtime error: index out of range"))
+		})
+		ExecuteOperation(t, lru, NewOp(Set, "k2", b("v2"), true))
+	}},
+	{"panic-value", func(t testing.TB) {
+		lru := NewLru(4)
+		lru.Set("k1", b("v1"))
+		lru.SetEvictedCallback(func(string, []byte) { panic("not implemented") })
+		ExecuteOperation(t, lru, NewOp(Set, "k2", b("v2"), true))
+	}},
+	{"hex", func(t testing.TB) {
+		CheckBytes(t, "bindings.golden", []byte{0x01, 0x02, 0xff}, []byte{0x01, 0x03})
+	}},
+}
+
+// stackTrace matches the stack trace of a panic, which varies from build to
+// build
+var stackTrace = regexp.MustCompile(`(?s)(Stacktrace:\n)goroutine .*?\n\n`)
+
+// renderFailureMessages runs every failure case, and writes what each reports
+// under a header naming it. Stack traces are elided, and bytes that are not
+// printable ASCII are written as \xNN, so that the golden file stays text.
+func renderFailureMessages(t *testing.T) []byte {
+	var buf bytes.Buffer
+	for _, c := range failureCases {
+		rec := &recordingTB{TB: t}
+		c.fail(rec)
+		if !rec.failed {
+			t.Errorf("Expected the %s case to fail", c.name)
+		}
+
+		text := stackTrace.ReplaceAllString(rec.out.String(), "$1<stack trace>\n\n")
+		fmt.Fprintf(&buf, "-- %s --\n", c.name)
+		for _, c := range []byte(text) {
+			if c != '\n' && (c < ' ' || c > '~') {
+				fmt.Fprintf(&buf, "\\x%02x", c)
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func TestFailureMessagesGolden(t *testing.T) {
+	// desc := "Check that failure messages render exactly as the golden file holds"
+	// The cases expect the wrong results from a working LRU, so what they
+	// report depends on the LRU working
+	rec := &recordingTB{TB: t}
+	lru := NewLru(8)
+	evicted := RecordEvictions(lru)
+	ExecuteOperationsNoSubtests(rec, lru, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(SetE, "k3", b("v3toolong"), ErrTooLarge),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Get, "k2", &Record{b("v2"), true}),
+		NewOp(Resize, 4, 1),
+		NewOp(Stat, Stats{Hits: 1, Evictions: 2, BytesEvicted: 8, Utilization: 1}),
+	})
+	CheckEvictions(rec, *evicted, []Binding{{"k1", b("v1")}, {"k3", b("v3")}})
+	if rec.failed {
+		t.Skip("Skipping failure message tests until the LRU passes the basic tests")
+	}
+
+	data := renderFailureMessages(t)
+	path := filepath.Join("testdata", "messages.golden")
+	if *updateGolden {
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(data, golden) {
+		return
+	}
+
+	// Point at the first line that differs, since the messages are long
+	expected := strings.Split(string(golden), "\n")
+	found := strings.Split(string(data), "\n")
+	for i := 0; i < len(expected) || i < len(found); i++ {
+		var exp, got string
+		if i < len(expected) {
+			exp = expected[i]
+		}
+		if i < len(found) {
+			got = found[i]
+		}
+		if exp != got {
+			t.Fatalf("%s, line %d: expected\n%q\nfound\n%q", path, i+1, exp, got)
+		}
+	}
+}
+
+func TestPanicReportValues(t *testing.T) {
+	// desc := "Check that panics with values other than errors are reported, not re-raised"
+	for _, e := range []interface{}{errors.New("boom"), "boom", 316} {
+		report := panicReport(e, nil)
+		if !strings.Contains(report, fmt.Sprintf("Error: %v\n", e)) {
+			t.Errorf("Expected the report of panic(%#v) to include it, found %q", e, report)
+		}
+	}
+}
+
+/******************************************************************************
+ *                             Operation subtest tests
+ ******************************************************************************/
+
+func TestRunOperationNames(t *testing.T) {
+	// desc := "Check that repeated operations get unique, index-prefixed subtest names"
+	ops := []Operation{NewOp(Len, 0), NewOp(Get, "k1", &Record{nil, false}), NewOp(Len, 0)}
+
+	var names []string
+	record := func(t *testing.T) { names = append(names, t.Name()) }
+	for _, op := range ops {
+		RunOperation(t, op, record)
+	}
+	// Numbering continues across calls, as when a test runs several traces
+	RunOperation(t, ops[0], record)
+
+	expected := []string{"000_Len()&0", "001_Get(\"k1\")&cache_miss", "002_Len()&0", "003_Len()&0"}
+	for i := range expected {
+		expected[i] = t.Name() + "/" + expected[i]
+	}
+	if strings.Join(names, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected subtests\n%s\nfound\n%s", strings.Join(expected, "\n"), strings.Join(names, "\n"))
+	}
+}
+
+func TestOperationReport(t *testing.T) {
+	// desc := "Check that -lru.op-report maps each subtest name to its operation"
+	path := filepath.Join(t.TempDir(), "ops.jsonl")
+	defer func(saved string) { *opReport = saved }(*opReport)
+	*opReport = path
+
+	t.Run("trace", func(t *testing.T) {
+		ExecuteOperations(t, NewLru(8), []Operation{
+			NewOp(Set, "k1", b("v1"), true),
+			NewOp(Get, "k1", &Record{b("v1"), true}),
+		})
+	})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	expected := []OperationReport{
+		{t.Name() + "/trace/000_Set(\"k1\",'v1')&true", 0, Set, "\"k1\",'v1'", "true", "", false},
+		{t.Name() + "/trace/001_Get(\"k1\")&cache_hit:<'v1'>", 1, Get, "\"k1\"", "cache hit:<'v1'>", "", false},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d reports, found %q", len(expected), lines)
+	}
+	for i, line := range lines {
+		var got OperationReport
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("Report %d: %v", i, err)
+		}
+		if got != expected[i] {
+			t.Errorf("Report %d: expected %+v, found %+v", i, expected[i], got)
+		}
+	}
+}
+
+func TestOperationReportTruncated(t *testing.T) {
+	// desc := "Check that -lru.op-report drops whatever an earlier run left in it"
+	path := filepath.Join(t.TempDir(), "ops.jsonl")
+	if err := os.WriteFile(path, []byte("{\"test\":\"stale\"}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func(saved string) { *opReport = saved }(*opReport)
+	*opReport = path
+
+	// Both traces are part of this run, so the second must not truncate
+	// the first's reports
+	for _, name := range []string{"first", "second"} {
+		t.Run(name, func(t *testing.T) {
+			ExecuteOperations(t, NewLru(8), []Operation{NewOp(Len, 0)})
+		})
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || strings.Contains(string(data), "stale") {
+		t.Errorf("Expected the reports of this run's two operations alone, found %q", lines)
+	}
+}
+
+func TestOperationReportUnwritable(t *testing.T) {
+	// desc := "Check that a report that cannot be written neither fails operations nor stops the run"
+	defer func(saved string) { *opReport = saved }(*opReport)
+	*opReport = t.TempDir() // a directory cannot be opened for writing
+	defer func() { opSubtests.reportErr = nil }()
+
+	ok := t.Run("trace", func(t *testing.T) {
+		ExecuteOperations(t, NewLru(8), []Operation{NewOp(Len, 0), NewOp(Len, 0)})
+	})
+	if !ok {
+		t.Errorf("Expected the operations to pass though their reports could not be written")
+	}
+	if opSubtests.reportErr == nil {
+		t.Errorf("Expected the failure to write the report to turn reporting off")
+	}
+}
+
+func TestRunOperationSections(t *testing.T) {
+	// desc := "Check that an operation's section appears in its subtest name and report"
+	path := filepath.Join(t.TempDir(), "ops.jsonl")
+	defer func(saved string) { *opReport = saved }(*opReport)
+	*opReport = path
+
+	var names []string
+	ops := append(Section("fill phase", NewOp(Len, 0)), NewOp(Len, 0))
+	for _, op := range ops {
+		RunOperation(t, op, func(t *testing.T) { names = append(names, t.Name()) })
+	}
+
+	expected := []string{t.Name() + "/000_[fill_phase]_Len()&0", t.Name() + "/001_Len()&0"}
+	if strings.Join(names, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected subtests\n%s\nfound\n%s", strings.Join(expected, "\n"), strings.Join(names, "\n"))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"section":"fill phase"`) ||
+		strings.Contains(lines[1], `"section"`) {
+		t.Errorf("Expected only the first report to name its section, found %q", lines)
+	}
+}
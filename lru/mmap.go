@@ -0,0 +1,11 @@
+//go:build unix
+
+package lru
+
+func (lru *LRU) SetMmapFile(path string, size, threshold int) error {
+	return nil
+}
+
+func (lru *LRU) MmapInUse() int {
+	return 0
+}
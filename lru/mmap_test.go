@@ -0,0 +1,158 @@
+//go:build unix
+
+package lru
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Memory-mapped value tests
+ ******************************************************************************/
+
+// NewLruWithMmap returns a new LRU keeping values longer than threshold in a
+// mapped file of size bytes, which is removed when the test ends, and the
+// path of the file
+func NewLruWithMmap(t *testing.T, limit, size, threshold int) (*LRU, string) {
+	lru := NewLru(limit)
+	path := filepath.Join(t.TempDir(), "values")
+	if err := lru.SetMmapFile(path, size, threshold); err != nil {
+		t.Fatalf(operationFailMessage, "SetMmapFile", &Args{[]interface{}{path, size, threshold}},
+			Expected{nil}, Expected{err})
+	}
+	t.Cleanup(func() { lru.Close() })
+	return lru, path
+}
+
+// CheckMmapInUse fails the test unless lru.MmapInUse() returns expected
+func CheckMmapInUse(t *testing.T, lru *LRU, expected int) {
+	t.Helper()
+	if got := lru.MmapInUse(); got != expected {
+		t.Errorf(operationFailMessage, "MmapInUse", &Args{}, Expected{expected}, Expected{got})
+	}
+}
+
+func TestMmapThreshold(t *testing.T) {
+	// desc := "Check that only values over the threshold are mapped, and are charged as usual"
+	limit := 4096
+	lru, _ := NewLruWithMmap(t, limit, 4096, 100)
+	CheckMmapInUse(t, lru, 0)
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "small", arenaValue(1, 100), true),
+		NewOp(Set, "large", arenaValue(2, 101), true),
+		NewOp(Remaining, limit-5-100-5-101),
+	})
+	CheckMmapInUse(t, lru, 101)
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Get, "small", &Record{arenaValue(1, 100), true}),
+		NewOp(Get, "large", &Record{arenaValue(2, 101), true}),
+		NewOp(Set, "large", arenaValue(3, 1000), true),
+		NewOp(Get, "large", &Record{arenaValue(3, 1000), true}),
+		NewOp(Set, "small", arenaValue(4, 10), true),
+		NewOp(Remaining, limit-5-1000-5-10),
+	})
+	CheckMmapInUse(t, lru, 1000)
+}
+
+func TestMmapCopies(t *testing.T) {
+	// desc := "Check that mapped values are copied in and out"
+	lru, _ := NewLruWithMmap(t, 64, 64, 0)
+	CheckAliases(t, lru, false, false)
+	CheckMmapInUse(t, lru, 5)
+}
+
+func TestMmapFull(t *testing.T) {
+	// desc := "Check that values that do not fit in the file are kept in memory"
+	lru, _ := NewLruWithMmap(t, 4096, 1000, 0)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", arenaValue(1, 400), true),
+		NewOp(Set, "k2", arenaValue(2, 400), true),
+		NewOp(Set, "k3", arenaValue(3, 400), true), // only 200 bytes left
+		NewOp(Set, "k4", arenaValue(4, 200), true),
+	})
+	CheckMmapInUse(t, lru, 1000)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Get, "k1", &Record{arenaValue(1, 400), true}),
+		NewOp(Get, "k2", &Record{arenaValue(2, 400), true}),
+		NewOp(Get, "k3", &Record{arenaValue(3, 400), true}),
+		NewOp(Get, "k4", &Record{arenaValue(4, 200), true}),
+	})
+}
+
+func TestMmapEvictionReclaims(t *testing.T) {
+	// desc := "Check that evicting, removing and replacing mapped values frees their bytes"
+	lru, _ := NewLruWithMmap(t, 3*402, 1000, 0) // room for 3 bindings
+	var evicted []Binding
+	lru.SetEvictedCallback(func(key string, val []byte) {
+		evicted = append(evicted, Binding{key, val})
+	})
+
+	lru.Set("k1", arenaValue(1, 400))
+	lru.Set("k2", arenaValue(2, 400))
+	lru.Set("k3", arenaValue(3, 400)) // kept in memory
+	CheckMmapInUse(t, lru, 800)
+
+	// k4 is stored, in memory, before it evicts k1, freeing k1's bytes...
+	lru.Set("k4", arenaValue(4, 400))
+	CheckMmapInUse(t, lru, 400)
+
+	// ...which k5 reuses, before it evicts k2
+	lru.Set("k5", arenaValue(5, 400))
+	CheckMmapInUse(t, lru, 400)
+
+	// Once k5 is removed, all the free bytes merge, to fit the largest value
+	lru.Remove("k5")
+	CheckMmapInUse(t, lru, 0)
+	lru.Set("k6", arenaValue(6, 1000)) // evicts k3 and k4
+	CheckMmapInUse(t, lru, 1000)
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Get, "k6", &Record{arenaValue(6, 1000), true}),
+		NewOp(Len, 1),
+	})
+	lru.Purge()
+	CheckMmapInUse(t, lru, 0)
+	CheckRange(t, evicted, []Binding{
+		{"k1", arenaValue(1, 400)}, {"k2", arenaValue(2, 400)}, {"k3", arenaValue(3, 400)},
+		{"k4", arenaValue(4, 400)}, {"k6", arenaValue(6, 1000)},
+	})
+}
+
+func TestMmapRange(t *testing.T) {
+	// desc := "Check that Range sees mapped values as they were when it began"
+	lru, _ := NewLruWithMmap(t, 4096, 1000, 0)
+	lru.Set("k1", arenaValue(1, 400))
+	lru.Set("k2", arenaValue(2, 400))
+
+	var walked []Binding
+	lru.Range(func(key string, val []byte) bool {
+		walked = append(walked, Binding{key, val})
+		if key == "k1" {
+			lru.Purge()
+			lru.Set("n1", bytes.Repeat(b("x"), 1000)) // overwrites both
+		}
+		return true
+	})
+	CheckRange(t, walked, []Binding{{"k1", arenaValue(1, 400)}, {"k2", arenaValue(2, 400)}})
+}
+
+func TestMmapClose(t *testing.T) {
+	// desc := "Check that Close removes the mapped file"
+	lru, path := NewLruWithMmap(t, 4096, 1000, 0)
+	lru.Set("k1", arenaValue(1, 400))
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Mapped file %s missing before Close: %v", path, err)
+	}
+	if err := lru.Close(); err != nil {
+		t.Errorf(operationFailMessage, "Close", &Args{}, Expected{nil}, Expected{err})
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected mapped file %s removed by Close, found error %v", path, err)
+	}
+}
@@ -0,0 +1,205 @@
+package lru
+
+/******************************************************************************
+ *                             Reference model
+ ******************************************************************************/
+// Model is a deliberately simple LRU that the harness uses to derive expected
+// results for arbitrary traces, e.g. the exact keys each operation evicts.
+// It favours obviously-correct code over speed, so only use it on traces of
+// modest size.
+type Model struct {
+	limit int
+	used  int
+	order []string // least recently used first
+	vals  map[string][]byte
+	stats Stats // counted as an LRU counts them
+}
+
+// NewModel returns an empty model with capacity to store limit bytes
+func NewModel(limit int) *Model {
+	return &Model{limit: limit, vals: make(map[string][]byte)}
+}
+
+func (m *Model) MaxStorage() int {
+	return m.limit
+}
+
+func (m *Model) RemainingStorage() int {
+	return m.limit - m.used
+}
+
+func (m *Model) Len() int {
+	return len(m.order)
+}
+
+// Keys returns the keys in the model, least recently used first
+func (m *Model) Keys() []string {
+	return append([]string(nil), m.order...)
+}
+
+func (m *Model) Get(key string) ([]byte, bool) {
+	val, ok := m.vals[key]
+	if ok {
+		m.stats.Hits++
+		m.touch(key)
+	} else {
+		m.stats.Misses++
+	}
+	return val, ok
+}
+
+// Peek returns the value bound to key without updating its recency
+func (m *Model) Peek(key string) ([]byte, bool) {
+	val, ok := m.vals[key]
+	return val, ok
+}
+
+func (m *Model) Contains(key string) bool {
+	_, ok := m.vals[key]
+	return ok
+}
+
+// Touch marks key as most recently used, returning whether it was present
+func (m *Model) Touch(key string) bool {
+	_, ok := m.vals[key]
+	if ok {
+		m.touch(key)
+	}
+	return ok
+}
+
+func (m *Model) Remove(key string) ([]byte, bool) {
+	val, ok := m.vals[key]
+	if ok {
+		m.unlink(key)
+		delete(m.vals, key)
+		m.used -= len(key) + len(val)
+	}
+	return val, ok
+}
+
+// Set adds or replaces a binding, returning whether it fit along with the keys
+// evicted to make room for it, least recently used first
+func (m *Model) Set(key string, val []byte) (bool, []string) {
+	if len(key)+len(val) > m.limit {
+		return false, nil
+	}
+
+	if old, ok := m.vals[key]; ok {
+		m.used -= len(old)
+		m.touch(key)
+	} else {
+		m.used += len(key)
+		m.order = append(m.order, key)
+	}
+	m.used += len(val)
+	m.vals[key] = val
+
+	return true, m.evict()
+}
+
+// Add sets a binding only if key is not already bound
+func (m *Model) Add(key string, val []byte) (bool, []string) {
+	if _, ok := m.vals[key]; ok {
+		return false, nil
+	}
+	return m.Set(key, val)
+}
+
+// Extend appends suffix to, or prepends prefix to, the value bound to key,
+// if there is one and the result fits
+func (m *Model) Extend(key string, prefix, suffix []byte) (bool, []string) {
+	old, ok := m.vals[key]
+	if !ok || len(key)+len(prefix)+len(old)+len(suffix) > m.limit {
+		return false, nil
+	}
+	val := append(append(append([]byte{}, prefix...), old...), suffix...)
+	return m.Set(key, val)
+}
+
+// Resize changes the model's capacity, returning the keys evicted to fit it
+func (m *Model) Resize(limit int) []string {
+	m.limit = limit
+	return m.evict()
+}
+
+// Purge removes every binding, returning their keys, least recently used first
+func (m *Model) Purge() []string {
+	evicted := m.Keys()
+	for _, key := range evicted {
+		m.evictKey(key)
+	}
+	return evicted
+}
+
+// Stats returns the hits, misses and evictions of the operations the model
+// has seen, which an LRU's Stats must match after the same operations
+func (m *Model) Stats() Stats {
+	stats := m.stats
+	if m.limit > 0 {
+		stats.Utilization = float64(m.used) / float64(m.limit)
+	}
+	return stats
+}
+
+// evict removes least recently used bindings until the rest fit the limit
+func (m *Model) evict() []string {
+	var evicted []string
+	for m.used > m.limit {
+		victim := m.order[0]
+		evicted = append(evicted, victim)
+		m.evictKey(victim)
+	}
+	return evicted
+}
+
+// evictKey removes the binding of key, counting it as an eviction
+func (m *Model) evictKey(key string) {
+	if val, ok := m.Remove(key); ok {
+		m.stats.Evictions++
+		m.stats.BytesEvicted += len(key) + len(val)
+	}
+}
+
+// Apply performs op on the model, returning the keys it evicted
+func (m *Model) Apply(op Operation) []string {
+	switch op.method {
+	case Get:
+		m.Get(op.args.Key())
+	case Set, SetE:
+		_, evicted := m.Set(op.args.Key(), op.args.Val())
+		return evicted
+	case Add:
+		_, evicted := m.Add(op.args.Key(), op.args.Val())
+		return evicted
+	case Append:
+		_, evicted := m.Extend(op.args.Key(), nil, op.args.Val())
+		return evicted
+	case Prepend:
+		_, evicted := m.Extend(op.args.Key(), op.args.Val(), nil)
+		return evicted
+	case Remove:
+		m.Remove(op.args.Key())
+	case Touch:
+		m.Touch(op.args.Key())
+	case Resize:
+		return m.Resize(op.args.Int())
+	case Purge:
+		return m.Purge()
+	}
+	return nil
+}
+
+func (m *Model) touch(key string) {
+	m.unlink(key)
+	m.order = append(m.order, key)
+}
+
+func (m *Model) unlink(key string) {
+	for i, k := range m.order {
+		if k == key {
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package lru
+
+// MruPolicy evicts the most recently used binding, which suits workloads that
+// loop over more bindings than fit, where LRU would evict every binding just
+// before it is needed again
+type MruPolicy struct {
+	// whatever fields you want here
+}
+
+func NewMruPolicy() *MruPolicy {
+	return new(MruPolicy)
+}
+
+// NewMru returns a new MRU cache with capacity to store limit bytes
+func NewMru(limit int) *PolicyCache {
+	return NewPolicyCache(limit, NewMruPolicy())
+}
+
+func (p *MruPolicy) Add(key string, size int) {
+}
+
+func (p *MruPolicy) Update(key string, size int) {
+}
+
+func (p *MruPolicy) Access(key string) {
+}
+
+func (p *MruPolicy) Remove(key string) {
+}
+
+func (p *MruPolicy) Evict() string {
+	return ""
+}
@@ -0,0 +1,52 @@
+package lru
+
+import "testing"
+
+/******************************************************************************
+ *                             MRU tests
+ ******************************************************************************/
+
+func TestMruEvictionOrder(t *testing.T) {
+	// desc := "Check that MRU evicts the most recently used binding other than the new one"
+	limit := 12 // room for 3 bindings
+	c := NewMru(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true), // evicts k3
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "k5", b("v5"), true), // evicts k1
+		NewOp(Set, "k6", b("v6"), true), // evicts k5
+	})
+	CheckResident(t, c, []string{"k2", "k4", "k6"}, []string{"k1", "k3", "k5"})
+}
+
+func TestMruUpdateCounts(t *testing.T) {
+	// desc := "Check that overwriting a binding makes it the most recently used"
+	limit := 12 // room for 3 bindings
+	c := NewMru(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k1", b("11"), true),
+		NewOp(Set, "k4", b("v4"), true), // evicts k1
+	})
+	CheckResident(t, c, []string{"k2", "k3", "k4"}, []string{"k1"})
+}
+
+func TestMruLoopHitRatio(t *testing.T) {
+	// desc := "Check that MRU beats LRU on a loop over more keys than fit"
+	limit := 64 * 8 // room for 64 bindings
+	trace := ScanTrace(80, 1, 0, 20)
+
+	mru := ReplayHits(NewMru(limit), trace)
+	lru := ReplayHits(NewPolicyCache(limit, NewLruPolicy()), trace)
+	if lru != 0 || 2*mru < len(trace) {
+		t.Errorf("MRU hit %d and LRU hit %d of %d Gets; expected LRU never to hit, "+
+			"and MRU to hit at least half of the time", mru, lru, len(trace))
+	}
+}
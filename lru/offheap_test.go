@@ -0,0 +1,147 @@
+package lru
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Off-heap tests
+ ******************************************************************************/
+
+func TestOffHeapModel(t *testing.T) {
+	// desc := "Check that an off-heap LRU agrees with the model under churn"
+	CheckModelChurn(t, Options{OffHeap: true})
+	CheckModelChurn(t, Options{OffHeap: true, Arena: true})
+}
+
+func TestOffHeapCopies(t *testing.T) {
+	// desc := "Check that an off-heap LRU copies values in and out"
+	CheckAliases(t, NewLruWithOptions(64, Options{OffHeap: true}), false, false)
+}
+
+func TestOffHeapCompaction(t *testing.T) {
+	// desc := "Check that values survive being moved by compaction, in any mode"
+	for _, opts := range []Options{{OffHeap: true}, {OffHeap: true, CompressAbove: 64}} {
+		t.Run(fmt.Sprintf("%+v", opts), func(t *testing.T) {
+			lru := NewLruWithOptions(8<<10, opts)
+			lru.Set("pinned", bytes.Repeat(b("p"), 1000))
+			lru.Pin("pinned")
+
+			// Overwriting the same few keys fills buffer after buffer with
+			// dead values, forcing compaction over and over
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("k%d", i%5)
+				if !lru.Set(key, arenaValue(i, 100+i%900)) {
+					t.Fatalf(operationFailMessage, "Set", fmt.Sprintf("%q, %d bytes", key, 100+i%900),
+						Expected{true}, Expected{false})
+				}
+			}
+			for i := 995; i < 1000; i++ {
+				key := fmt.Sprintf("k%d", i%5)
+				ExecuteOperations(t, lru, []Operation{NewOp(Peek, key, &Record{arenaValue(i, 100+i%900), true})})
+			}
+			ExecuteOperations(t, lru, []Operation{NewOp(Peek, "pinned", &Record{bytes.Repeat(b("p"), 1000), true})})
+		})
+	}
+}
+
+func TestOffHeapHandedOut(t *testing.T) {
+	// desc := "Check that values handed out stay intact through compaction"
+	lru := NewLruWithOptions(1024, Options{OffHeap: true})
+	var evicted []Binding
+	lru.SetEvictedCallback(func(key string, val []byte) {
+		evicted = append(evicted, Binding{key, val})
+	})
+	lru.Set("k1", arenaValue(1, 300))
+	lru.Set("k2", arenaValue(2, 300))
+	lru.Set("k3", arenaValue(3, 300))
+
+	var walked []Binding
+	lru.Range(func(key string, val []byte) bool {
+		walked = append(walked, Binding{key, val})
+		if key == "k1" {
+			// Evicts k2 and k3, and compacts at least once
+			for i := 0; i < 20; i++ {
+				lru.Set("k1", arenaValue(10+i, 800))
+			}
+		}
+		return true
+	})
+	CheckRange(t, walked, []Binding{
+		{"k1", arenaValue(1, 300)}, {"k2", arenaValue(2, 300)}, {"k3", arenaValue(3, 300)},
+	})
+	CheckRange(t, evicted, []Binding{{"k2", arenaValue(2, 300)}, {"k3", arenaValue(3, 300)}})
+}
+
+func TestOffHeapStorage(t *testing.T) {
+	// desc := "Check that an off-heap LRU charges for storage as the standard mode does"
+	limit := 1024
+	lru := NewLruWithOptions(limit, Options{OffHeap: true})
+	lru.SetEntryOverhead(8)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", arenaValue(1, 500), true),
+		NewOp(Set, "k2", arenaValue(2, 400), true),
+		NewOp(Remaining, limit-920),
+		NewOp(Set, "k1", arenaValue(3, 100), true),
+		NewOp(Remaining, limit-520),
+		NewOp(Set, "k3", arenaValue(4, 600), true), // evicts k2
+		NewOp(Peek, "k2", &Record{nil, false}),
+		NewOp(Remaining, limit-720),
+		NewOp(Get, "k1", &Record{arenaValue(3, 100), true}),
+	})
+}
+
+// offHeapBindings and offHeapValue size the LRUs in the off-heap benchmarks
+const (
+	offHeapBindings = 1 << 17
+	offHeapValue    = 64
+)
+
+// fillForBenchmark returns an LRU made with opts holding offHeapBindings
+// bindings, and their keys
+func fillForBenchmark(opts Options) (*LRU, []string) {
+	keys := make([]string, offHeapBindings)
+	lru := NewLruWithOptions(offHeapBindings*(12+offHeapValue), opts)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%08d", i)
+		lru.Set(keys[i], arenaValue(i, offHeapValue))
+	}
+	return lru, keys
+}
+
+// benchmarkOffHeapGet times Gets on a full LRU made with opts, reporting how
+// many heap objects it holds and how long a full garbage collection takes
+func benchmarkOffHeapGet(b *testing.B, opts Options) {
+	lru, keys := fillForBenchmark(opts)
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	pause := stats.PauseTotalNs
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	pause = stats.PauseTotalNs - pause
+
+	rng := rand.New(rand.NewSource(316))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := lru.Get(keys[rng.Intn(len(keys))]); !ok {
+			b.FailNow()
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(stats.HeapObjects), "heap-objects")
+	b.ReportMetric(float64(pause), "gc-pause-ns")
+}
+
+func BenchmarkOffHeapGetStandard(b *testing.B) {
+	benchmarkOffHeapGet(b, Options{CopyOnWrite: true, CopyOnRead: true})
+}
+
+func BenchmarkOffHeapGetOffHeap(b *testing.B) {
+	benchmarkOffHeapGet(b, Options{OffHeap: true})
+}
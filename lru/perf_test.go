@@ -0,0 +1,498 @@
+package lru
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Performance tests
+ ******************************************************************************/
+// The performance rubric grades the medians that these tests log, rather than
+// the single runs of go test -bench, which vary too much from run to run. Each
+// benchmark is run with GOMAXPROCS pinned, first for warmup runs that are
+// thrown away, then for the runs that are measured. Each run lasts as long
+// as -test.benchtime says:
+//
+//	go test -run Performance -lru.perf -lru.perf-runs 9
+
+var perf = flag.Bool("lru.perf", false,
+	"run the performance tests, which take a while")
+
+var perfProcs = flag.Int("lru.perf-procs", 1,
+	"GOMAXPROCS for the performance tests")
+
+var perfWarmup = flag.Int("lru.perf-warmup", 2,
+	"runs of each benchmark to discard before measuring")
+
+var perfRuns = flag.Int("lru.perf-runs", 5,
+	"measured runs of each benchmark, of which the median is graded")
+
+// Measurement summarises the measured runs of a benchmark
+type Measurement struct {
+	NsPerOp  []float64 // of each run, in the order they ran
+	Median   float64
+	Variance float64
+}
+
+// RelStdDev returns the standard deviation of the runs relative to their
+// median, as a fraction
+func (m Measurement) RelStdDev() float64 {
+	if m.Median == 0 {
+		return 0
+	}
+	return math.Sqrt(m.Variance) / m.Median
+}
+
+// summarize computes the median and the (population) variance of nsPerOp
+func summarize(nsPerOp []float64) Measurement {
+	m := Measurement{NsPerOp: nsPerOp}
+	if len(nsPerOp) == 0 {
+		return m
+	}
+
+	sorted := append([]float64(nil), nsPerOp...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	m.Median = sorted[mid]
+	if len(sorted)%2 == 0 {
+		m.Median = (sorted[mid-1] + sorted[mid]) / 2
+	}
+
+	mean := 0.0
+	for _, ns := range sorted {
+		mean += ns
+	}
+	mean /= float64(len(sorted))
+	for _, ns := range sorted {
+		m.Variance += (ns - mean) * (ns - mean)
+	}
+	m.Variance /= float64(len(sorted))
+	return m
+}
+
+// MeasureBenchmark runs bench with GOMAXPROCS set to procs, warmup times to
+// be discarded and then runs times to be measured, and summarises the ns/op
+// of the measured runs. GOMAXPROCS is restored afterwards.
+func MeasureBenchmark(procs, warmup, runs int, bench func(b *testing.B)) Measurement {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+
+	for i := 0; i < warmup; i++ {
+		testing.Benchmark(bench)
+	}
+	nsPerOp := make([]float64, runs)
+	for i := range nsPerOp {
+		res := testing.Benchmark(bench)
+		if res.N > 0 {
+			nsPerOp[i] = float64(res.T.Nanoseconds()) / float64(res.N)
+		}
+	}
+	return summarize(nsPerOp)
+}
+
+// CheckPerformance measures bench with the -lru.perf flags, and logs its
+// median in the form the performance rubric reads
+func CheckPerformance(t *testing.T, name string, bench func(b *testing.B)) Measurement {
+	if !*perf {
+		t.Skip("no -lru.perf given")
+	}
+	m := MeasureBenchmark(*perfProcs, *perfWarmup, *perfRuns, bench)
+	t.Logf("%s: median %.1f ns/op over %d runs (variance %.1f, stddev %.1f%%)",
+		name, m.Median, len(m.NsPerOp), m.Variance, 100*m.RelStdDev())
+	return m
+}
+
+func TestPerformanceZipf(t *testing.T) {
+	// desc := "Measure the leaderboard workload, median of several runs"
+	CheckPerformance(t, "Zipf", BenchmarkZipf)
+}
+
+func TestPerformanceChurnGC(t *testing.T) {
+	// desc := "Measure the eviction-heavy workload, median of several runs"
+	CheckPerformance(t, "ChurnGC", BenchmarkChurnGC)
+}
+
+func TestSummarize(t *testing.T) {
+	// desc := "Check that repeated runs are summarised by median and variance"
+	cases := []struct {
+		nsPerOp          []float64
+		median, variance float64
+	}{
+		{nil, 0, 0},
+		{[]float64{7}, 7, 0},
+		{[]float64{30, 10, 20}, 20, 200.0 / 3},
+		{[]float64{10, 40, 20, 1000}, 30, 178968.75},
+	}
+	for _, c := range cases {
+		m := summarize(c.nsPerOp)
+		if m.Median != c.median || math.Abs(m.Variance-c.variance) > 1e-9 {
+			t.Errorf("summarize(%v): expected median %v and variance %v, found %v and %v",
+				c.nsPerOp, c.median, c.variance, m.Median, m.Variance)
+		}
+	}
+}
+
+func TestMeasureBenchmark(t *testing.T) {
+	// desc := "Check that measuring pins GOMAXPROCS, discards warmups and restores GOMAXPROCS"
+	// One iteration per run is enough to check the runs themselves
+	benchtime := flag.Lookup("test.benchtime").Value.String()
+	flag.Set("test.benchtime", "1x")
+	defer flag.Set("test.benchtime", benchtime)
+
+	before := runtime.GOMAXPROCS(0)
+	procs := make(map[int]bool)
+	calls := 0
+	m := MeasureBenchmark(1, 2, 3, func(b *testing.B) {
+		calls++
+		procs[runtime.GOMAXPROCS(0)] = true
+	})
+
+	if len(m.NsPerOp) != 3 {
+		t.Errorf("Expected 3 measured runs, found %d", len(m.NsPerOp))
+	}
+	// testing.Benchmark calls the function at least once for every run
+	if calls < 5 {
+		t.Errorf("Expected at least 5 runs with warmup, found %d calls", calls)
+	}
+	if len(procs) != 1 || !procs[1] {
+		t.Errorf("Expected every run with GOMAXPROCS 1, found %v", procs)
+	}
+	if after := runtime.GOMAXPROCS(0); after != before {
+		t.Errorf("Expected GOMAXPROCS to be restored to %d, found %d", before, after)
+	}
+}
+
+/******************************************************************************
+ *                             Scaling tests
+ ******************************************************************************/
+// TestPerformanceScaling measures each operation on full LRUs of several sizes,
+// and reports how its time grows with the number of bindings, as the class of
+// the best-fitting model: "Get appears to be O(n)" is a sure sign of a linear
+// search. It runs with the other performance tests:
+//
+//	go test -run PerformanceScaling -lru.perf
+
+// scalingSizes are the numbers of bindings the scaling test measures at. They
+// stay small enough that an O(1) LRU fits in cache at every size, which would
+// otherwise slow its largest sizes down enough to look like growth.
+var scalingSizes = []int{1 << 8, 1 << 10, 1 << 12, 1 << 14}
+
+// growthModels are the classes an operation can be fit to, beyond O(1), by the
+// function of n that each grows with
+var growthModels = []struct {
+	class string
+	f     func(n float64) float64
+}{
+	{"O(log n)", math.Log2},
+	{"O(n)", func(n float64) float64 { return n }},
+}
+
+// fitLinear fits y = a + slope*x by least squares, and returns the slope and
+// the fraction of y's variance that the fit explains (R²)
+func fitLinear(x, y []float64) (slope, r2 float64) {
+	var mx, my float64
+	for i := range x {
+		mx += x[i]
+		my += y[i]
+	}
+	mx /= float64(len(x))
+	my /= float64(len(y))
+
+	var sxx, sxy, syy float64
+	for i := range x {
+		sxx += (x[i] - mx) * (x[i] - mx)
+		sxy += (x[i] - mx) * (y[i] - my)
+		syy += (y[i] - my) * (y[i] - my)
+	}
+	if sxx == 0 || syy == 0 {
+		return 0, 0
+	}
+	return sxy / sxx, sxy * sxy / (sxx * syy)
+}
+
+// classifyGrowth returns the class of the model that best fits nsPerOp, the
+// time per operation measured at each of sizes. Times that grow by less than
+// half across the sizes are O(1), whatever their shape, since noise and caches
+// can account for that much; otherwise the growth model that explains the most
+// of their variance wins, as long as it explains at least 80%.
+func classifyGrowth(sizes []int, nsPerOp []float64) string {
+	lo, hi := math.Inf(1), 0.0
+	for _, ns := range nsPerOp {
+		lo, hi = math.Min(lo, ns), math.Max(hi, ns)
+	}
+	if hi < 1.5*lo {
+		return "O(1)"
+	}
+
+	best, bestR2 := "O(1)", 0.8
+	for _, model := range growthModels {
+		x := make([]float64, len(sizes))
+		for i, n := range sizes {
+			x[i] = model.f(float64(n))
+		}
+		if slope, r2 := fitLinear(x, nsPerOp); slope > 0 && r2 > bestR2 {
+			best, bestR2 = model.class, r2
+		}
+	}
+	return best
+}
+
+// scalingBenchmarks return, for an LRU filled with n bindings, a benchmark of
+// one operation, by name
+var scalingBenchmarks = []struct {
+	op    string
+	bench func(n int) func(b *testing.B)
+}{
+	{"Get", func(n int) func(b *testing.B) {
+		return func(b *testing.B) {
+			lru, keys := scalingLru(n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				lru.Get(keys[i%n])
+			}
+		}
+	}},
+	// Every Set binds a key that is not bound, and so evicts another: cycling
+	// through all 2n keys from the n unbound ones, each key is n Sets from
+	// having been bound, by which time it has been evicted
+	{"Set", func(n int) func(b *testing.B) {
+		return func(b *testing.B) {
+			lru, keys := scalingLru(n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := keys[(n+i)%len(keys)]
+				lru.Set(key, []byte(key))
+			}
+		}
+	}},
+	// Removes are paired with Sets, to keep the LRU full
+	{"Remove+Set", func(n int) func(b *testing.B) {
+		return func(b *testing.B) {
+			lru, keys := scalingLru(n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := keys[(i*7919)%n]
+				lru.Remove(key)
+				lru.Set(key, []byte(key))
+			}
+		}
+	}},
+}
+
+// scalingLru returns an LRU filled with n bindings of 8-byte keys to
+// themselves, and 2n keys: the n bound, in order of recency, then n more
+func scalingLru(n int) (*LRU, []string) {
+	lru := NewLru(n * 16)
+	keys := make([]string, 2*n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%08x", i)
+	}
+	for _, key := range keys[:n] {
+		lru.Set(key, []byte(key))
+	}
+	return lru, keys
+}
+
+func TestPerformanceScaling(t *testing.T) {
+	// desc := "Report how the time of each operation grows with the LRU's size"
+	if !*perf {
+		t.Skip("no -lru.perf given")
+	}
+	for _, s := range scalingBenchmarks {
+		nsPerOp := make([]float64, len(scalingSizes))
+		for i, n := range scalingSizes {
+			nsPerOp[i] = MeasureBenchmark(*perfProcs, *perfWarmup, *perfRuns, s.bench(n)).Median
+		}
+		t.Logf("%s appears to be %s (ns/op %s at sizes %v)", s.op,
+			classifyGrowth(scalingSizes, nsPerOp), formatNs(nsPerOp), scalingSizes)
+	}
+}
+
+// formatNs formats times to one decimal place, as a list
+func formatNs(nsPerOp []float64) string {
+	formatted := make([]string, len(nsPerOp))
+	for i, ns := range nsPerOp {
+		formatted[i] = fmt.Sprintf("%.1f", ns)
+	}
+	return "[" + strings.Join(formatted, " ") + "]"
+}
+
+func TestClassifyGrowth(t *testing.T) {
+	// desc := "Check that measurements are fit to the right complexity class"
+	cases := []struct {
+		name  string
+		f     func(n float64) float64
+		class string
+	}{
+		{"flat", func(float64) float64 { return 50 }, "O(1)"},
+		{"noisy flat", func(n float64) float64 { return 50 + 10*math.Mod(n, 3) }, "O(1)"},
+		{"cache effects", func(n float64) float64 { return 40 + 5*math.Log2(n) }, "O(1)"},
+		{"logarithmic", func(n float64) float64 { return 10 + 20*math.Log2(n) }, "O(log n)"},
+		{"linear", func(n float64) float64 { return 30 + n/4 }, "O(n)"},
+		{"shrinking", func(n float64) float64 { return 1e5 / n }, "O(1)"},
+	}
+	for _, c := range cases {
+		nsPerOp := make([]float64, len(scalingSizes))
+		for i, n := range scalingSizes {
+			nsPerOp[i] = c.f(float64(n))
+		}
+		if got := classifyGrowth(scalingSizes, nsPerOp); got != c.class {
+			t.Errorf("%s %v: expected %s, found %s", c.name, nsPerOp, c.class, got)
+		}
+	}
+}
+
+/******************************************************************************
+ *                             Throughput scaling tests
+ ******************************************************************************/
+// The concurrency extra credit is graded on how the throughput of a cache
+// that is safe for concurrent use grows as goroutines are added. Each
+// workload mixes Gets and Sets from every goroutine at once, at several
+// levels of contention: the fraction of operations that go to a few hot keys,
+// which every goroutine fights over, rather than to keys spread across the
+// cache. TestPerformanceParallel reports the scaling curve of each workload:
+//
+//	go test -run PerformanceParallel -lru.perf
+//
+// The same workloads can be run as ordinary benchmarks:
+//
+//	go test -run XXX -bench ParallelGetSet
+
+// parallelConstructors are the registered constructors whose caches are safe
+// for concurrent use, and so can be measured in parallel
+var parallelConstructors = []string{"NewSyncLru", "NewStripedLru"}
+
+// parallelProcs are the GOMAXPROCS settings the scaling curve is measured at
+var parallelProcs = []int{1, 2, 4, 8}
+
+// contentionRatios are the fractions of operations on the hot keys
+var contentionRatios = []float64{0, 0.5, 0.95}
+
+const (
+	parallelKeys    = 1 << 12 // half of which fit in the cache
+	parallelHotKeys = 16
+	parallelSetOdds = 4 // one operation in parallelSetOdds is a Set
+)
+
+// parallelGetSet returns a benchmark in which every goroutine of RunParallel
+// Gets and Sets keys on one cache made by factory, choosing one of the hot
+// keys for a fraction contention of the operations
+func parallelGetSet(factory CacheFactory, contention float64) func(b *testing.B) {
+	keys := make([]string, parallelKeys)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%08x", i)
+	}
+
+	return func(b *testing.B) {
+		cache := factory(parallelKeys / 2 * 16)
+		for _, key := range keys[:parallelKeys/2] {
+			cache.Set(key, []byte(key))
+		}
+
+		var seed int64
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			rng := rand.New(rand.NewSource(atomic.AddInt64(&seed, 1)))
+			for pb.Next() {
+				key := keys[parallelHotKeys+rng.Intn(parallelKeys-parallelHotKeys)]
+				if rng.Float64() < contention {
+					key = keys[rng.Intn(parallelHotKeys)]
+				}
+				if rng.Intn(parallelSetOdds) == 0 {
+					cache.Set(key, []byte(key))
+				} else {
+					cache.Get(key)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParallelGetSet(b *testing.B) {
+	for _, name := range parallelConstructors {
+		factory, err := LookupConstructor(name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, contention := range contentionRatios {
+			bench := parallelGetSet(factory, contention)
+			for _, procs := range parallelProcs {
+				label := fmt.Sprintf("%s/contention=%.2f/procs=%d", name, contention, procs)
+				b.Run(label, func(b *testing.B) {
+					defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+					bench(b)
+				})
+			}
+		}
+	}
+}
+
+func TestPerformanceParallel(t *testing.T) {
+	// desc := "Report how each concurrent cache's throughput scales with GOMAXPROCS"
+	if !*perf {
+		t.Skip("no -lru.perf given")
+	}
+	for _, name := range parallelConstructors {
+		factory, err := LookupConstructor(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, contention := range contentionRatios {
+			bench := parallelGetSet(factory, contention)
+			nsPerOp := make([]float64, len(parallelProcs))
+			for i, procs := range parallelProcs {
+				nsPerOp[i] = MeasureBenchmark(procs, *perfWarmup, *perfRuns, bench).Median
+			}
+			t.Logf("%s, contention %.2f: %s", name, contention, scalingCurve(parallelProcs, nsPerOp))
+		}
+	}
+}
+
+// scalingCurve formats the throughput measured at each GOMAXPROCS setting in
+// procs, in millions of operations per second, with its speedup over the
+// first setting
+func scalingCurve(procs []int, nsPerOp []float64) string {
+	points := make([]string, len(procs))
+	for i, p := range procs {
+		mops, speedup := 0.0, 0.0
+		if nsPerOp[i] > 0 {
+			mops = 1e3 / nsPerOp[i]
+			speedup = nsPerOp[0] / nsPerOp[i]
+		}
+		points[i] = fmt.Sprintf("procs=%d %.2f Mops/s (%.2fx)", p, mops, speedup)
+	}
+	return strings.Join(points, ", ")
+}
+
+func TestScalingCurve(t *testing.T) {
+	// desc := "Check that the scaling curve reports throughput and speedup"
+	got := scalingCurve([]int{1, 2, 4}, []float64{100, 50, 0})
+	expected := "procs=1 10.00 Mops/s (1.00x), procs=2 20.00 Mops/s (2.00x), procs=4 0.00 Mops/s (0.00x)"
+	if got != expected {
+		t.Errorf("Expected the curve %q, found %q", expected, got)
+	}
+}
+
+func TestParallelGetSet(t *testing.T) {
+	// desc := "Check that the parallel workload runs on every concurrent cache"
+	benchtime := flag.Lookup("test.benchtime").Value.String()
+	flag.Set("test.benchtime", "100x")
+	defer flag.Set("test.benchtime", benchtime)
+
+	for _, name := range parallelConstructors {
+		factory, err := LookupConstructor(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := testing.Benchmark(parallelGetSet(factory, 0.5)); res.N == 0 {
+			t.Errorf("Expected the parallel workload to run on %s", name)
+		}
+	}
+}
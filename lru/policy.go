@@ -0,0 +1,62 @@
+package lru
+
+// Policy decides which binding a PolicyCache evicts when it runs out of
+// storage. The cache keeps the bindings and accounts for their storage; the
+// policy only sees keys, sizes and the order in which they are used.
+type Policy interface {
+	// Add records that key was bound, using size bytes of storage
+	Add(key string, size int)
+
+	// Update records that key, already bound, was bound again to a value that
+	// now uses size bytes of storage
+	Update(key string, size int)
+
+	// Access records a Get that found key
+	Access(key string)
+
+	// Remove forgets key, which was removed other than by eviction
+	Remove(key string)
+
+	// Evict chooses the next key to evict and forgets it. It is only called
+	// while at least two keys are bound, and must never choose the key most
+	// recently passed to Add or Update.
+	Evict() string
+}
+
+// PolicyCache stores bindings within a fixed amount of storage, evicting the
+// bindings its Policy chooses when it runs out
+type PolicyCache struct {
+	// whatever fields you want here
+}
+
+func NewPolicyCache(limit int, policy Policy) *PolicyCache {
+	return new(PolicyCache)
+}
+
+func (c *PolicyCache) MaxStorage() int {
+	return 0
+}
+
+func (c *PolicyCache) RemainingStorage() int {
+	return 0
+}
+
+func (c *PolicyCache) Get(key string) (value []byte, ok bool) {
+	return nil, false
+}
+
+func (c *PolicyCache) Contains(key string) bool {
+	return false
+}
+
+func (c *PolicyCache) Remove(key string) (value []byte, ok bool) {
+	return nil, false
+}
+
+func (c *PolicyCache) Set(key string, value []byte) bool {
+	return false
+}
+
+func (c *PolicyCache) Len() int {
+	return 0
+}
@@ -0,0 +1,242 @@
+package lru
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Policy harness
+ ******************************************************************************/
+// Every PolicyCache, whatever its policy, is a ByteCache, so policies are
+// graded with the same operations as the LRU. Checking which keys are
+// resident uses Contains, which, unlike Get, is not an access that the
+// policy could observe.
+
+var _ ByteCache = (*PolicyCache)(nil)
+
+// CheckResident fails the test unless every key in present is bound in c,
+// and no key in absent is
+func CheckResident(t *testing.T, c *PolicyCache, present []string, absent []string) {
+	for _, key := range present {
+		if !c.Contains(key) {
+			t.Errorf(operationFailMessage, "Contains", fmt.Sprintf("\"%s\"", key),
+				Expected{true}, Expected{false})
+		}
+	}
+	for _, key := range absent {
+		if c.Contains(key) {
+			t.Errorf(operationFailMessage, "Contains", fmt.Sprintf("\"%s\"", key),
+				Expected{false}, Expected{true})
+		}
+	}
+}
+
+// SpyPolicy wraps a Policy, recording every call the cache makes to it
+type SpyPolicy struct {
+	Policy
+	calls []string
+}
+
+func (p *SpyPolicy) Add(key string, size int) {
+	p.calls = append(p.calls, fmt.Sprintf("Add(%s,%d)", key, size))
+	p.Policy.Add(key, size)
+}
+
+func (p *SpyPolicy) Update(key string, size int) {
+	p.calls = append(p.calls, fmt.Sprintf("Update(%s,%d)", key, size))
+	p.Policy.Update(key, size)
+}
+
+func (p *SpyPolicy) Access(key string) {
+	p.calls = append(p.calls, fmt.Sprintf("Access(%s)", key))
+	p.Policy.Access(key)
+}
+
+func (p *SpyPolicy) Remove(key string) {
+	p.calls = append(p.calls, fmt.Sprintf("Remove(%s)", key))
+	p.Policy.Remove(key)
+}
+
+func (p *SpyPolicy) Evict() string {
+	key := p.Policy.Evict()
+	p.calls = append(p.calls, fmt.Sprintf("Evict()=%s", key))
+	return key
+}
+
+// CheckCalls fails the test unless the spy recorded exactly the expected calls
+// since it was last checked
+func (p *SpyPolicy) CheckCalls(t *testing.T, expected ...string) {
+	if fmt.Sprint(p.calls) != fmt.Sprint(expected) {
+		t.Errorf("\n***** Policy calls incorrect! *****\nExpected calls: %v\nReceived calls: %v\n",
+			expected, p.calls)
+	}
+	p.calls = nil
+}
+
+/******************************************************************************
+ *                             Policy cache tests
+ ******************************************************************************/
+
+func TestPolicyCacheBasic(t *testing.T) {
+	// desc := "Check that a policy cache accounts for storage like an LRU"
+	limit := 1024
+	c := NewFifo(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Max, limit),
+		NewOp(Remaining, limit),
+		NewOp(Len, 0),
+		NewOp(Get, "k1", &Record{nil, false}),
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "", b(""), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "", &Record{b(""), true}),
+		NewOp(Remaining, limit-4),
+		NewOp(Set, "k1", b("v11"), true),
+		NewOp(Remaining, limit-5),
+		NewOp(Remove, "k1", &Record{b("v11"), true}),
+		NewOp(Remove, "k1", &Record{nil, false}),
+		NewOp(Len, 1),
+		NewOp(Set, "toolarge", make([]byte, limit), false),
+		NewOp(Remaining, limit),
+	})
+}
+
+func TestPolicyCacheCalls(t *testing.T) {
+	// desc := "Check that a policy cache tells its policy about every use of a binding"
+	limit := 8 // room for 2 bindings
+	spy := &SpyPolicy{Policy: NewFifoPolicy()}
+	c := NewPolicyCache(limit, spy)
+
+	c.Set("k1", b("v1"))
+	c.Set("k2", b("v2"))
+	spy.CheckCalls(t, "Add(k1,4)", "Add(k2,4)")
+
+	c.Get("k1")
+	c.Get("missing")
+	c.Contains("k2")
+	spy.CheckCalls(t, "Access(k1)")
+
+	c.Set("k1", b("1"))
+	c.Set("k3", b("v3"))
+	spy.CheckCalls(t, "Update(k1,3)", "Add(k3,4)", "Evict()=k1")
+
+	c.Remove("k2")
+	c.Remove("k2")
+	c.Set("toolarge", b("value"))
+	spy.CheckCalls(t, "Remove(k2)")
+	CheckResident(t, c, []string{"k3"}, []string{"k1", "k2", "toolarge"})
+}
+
+/******************************************************************************
+ *                             Policy benchmarks
+ ******************************************************************************/
+
+// benchmarkPolicyZipf replays the same skewed workload as BenchmarkZipf on a
+// cache evicting by policy, so that policies' per-operation costs and hit
+// ratios can be compared with each other and with the LRU
+func benchmarkPolicyZipf(b *testing.B, policy Policy) {
+	N := 1 << 16
+	c := NewPolicyCache(N*8, policy) // room for roughly 1/2 of the keys
+
+	keys := make([]string, N)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%08x", i)
+	}
+
+	rng := rand.New(rand.NewSource(316))
+	zipf := rand.NewZipf(rng, 1.1, 1, uint64(N-1))
+	trace := make([]string, 1<<20)
+	for i := range trace {
+		trace[i] = keys[zipf.Uint64()]
+	}
+
+	hits := 0
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		key := trace[i%len(trace)]
+		if _, ok := c.Get(key); ok {
+			hits++
+		} else if !c.Set(key, []byte(key)) {
+			b.FailNow()
+		}
+	}
+
+	b.ReportMetric(100*float64(hits)/float64(b.N), "hit%")
+}
+
+func BenchmarkPolicyZipfLru(b *testing.B) {
+	benchmarkPolicyZipf(b, NewLruPolicy())
+}
+
+/******************************************************************************
+ *                             Hit ratio helpers
+ ******************************************************************************/
+
+// ReplayHits replays trace on c, Getting each key and Setting it only when the
+// Get misses, and returns the number of hits
+func ReplayHits(c ByteCache, trace []string) int {
+	hits := 0
+	for _, key := range trace {
+		if _, ok := c.Get(key); ok {
+			hits++
+		} else {
+			c.Set(key, []byte(key))
+		}
+	}
+	return hits
+}
+
+// ScanTrace returns a trace of rounds rounds, each of which makes passes
+// passes over hot keys, using each in turn, and then scans through scan keys
+// that are never used again. Keys are all 4 bytes long, so each binding of a
+// key to itself uses 8.
+func ScanTrace(hot, passes, scan, rounds int) []string {
+	trace := []string{}
+	cold := 0
+	for r := 0; r < rounds; r++ {
+		for p := 0; p < passes; p++ {
+			for i := 0; i < hot; i++ {
+				trace = append(trace, fmt.Sprintf("h%03d", i))
+			}
+		}
+		for i := 0; i < scan; i++ {
+			trace = append(trace, fmt.Sprintf("%04x", cold))
+			cold++
+		}
+	}
+	return trace
+}
+
+// benchmarkPolicyHits fills a cache evicting by policy, and then only Gets
+// bindings that are bound, measuring the cost of a hit on its own
+func benchmarkPolicyHits(b *testing.B, policy Policy) {
+	N := 1 << 12
+	c := NewPolicyCache(N*8, policy)
+
+	keys := make([]string, N)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%08x", i)[4:]
+		c.Set(keys[i], []byte(keys[i]))
+	}
+
+	rng := rand.New(rand.NewSource(316))
+	trace := make([]string, 1<<16)
+	for i := range trace {
+		trace[i] = keys[rng.Intn(N)]
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, ok := c.Get(trace[i%len(trace)]); !ok {
+			b.FailNow()
+		}
+	}
+}
+
+func BenchmarkPolicyHitsLru(b *testing.B) {
+	benchmarkPolicyHits(b, NewLruPolicy())
+}
@@ -0,0 +1,13 @@
+//go:build protobuf
+
+package lru
+
+import "google.golang.org/protobuf/proto"
+
+func (lru *LRU) SetProto(key string, m proto.Message) error {
+	return nil
+}
+
+func (lru *LRU) GetProto(key string, m proto.Message) error {
+	return nil
+}
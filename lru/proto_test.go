@@ -0,0 +1,118 @@
+//go:build protobuf
+
+package lru
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+/******************************************************************************
+ *                             Protocol buffer value tests
+ ******************************************************************************/
+// These tests only build with the "protobuf" tag, since they need
+// google.golang.org/protobuf. They use the generated well-known wrapper
+// messages, so that no protoc step is needed:
+//
+//	go test -tags protobuf -run Proto
+
+// CheckSetProto fails the test unless lru.SetProto(key, m) returns expected
+func CheckSetProto(t *testing.T, lru *LRU, key string, m proto.Message, expected error) {
+	if got := lru.SetProto(key, m); !errors.Is(got, expected) {
+		t.Errorf(operationFailMessage, "SetProto", fmt.Sprintf("%q, %v", key, m),
+			Expected{expected}, Expected{got})
+	}
+}
+
+// CheckGetProto fails the test unless lru.GetProto(key, m) decodes expected
+// into m, a message of the same type
+func CheckGetProto(t *testing.T, lru *LRU, key string, m, expected proto.Message) {
+	if err := lru.GetProto(key, m); err != nil || !proto.Equal(m, expected) {
+		t.Errorf(operationFailMessage, "GetProto", fmt.Sprintf("%q", key),
+			Expected{expected}, Expected{fmt.Sprintf("%v, %v", m, err)})
+	}
+}
+
+func TestProtoRoundTrip(t *testing.T) {
+	// desc := "Check that messages come back from GetProto as they went into SetProto"
+	lru := NewLru(1024)
+	CheckSetProto(t, lru, "name", wrapperspb.String("hello"), nil)
+	CheckSetProto(t, lru, "count", wrapperspb.Int64(300), nil)
+	CheckGetProto(t, lru, "name", new(wrapperspb.StringValue), wrapperspb.String("hello"))
+	CheckGetProto(t, lru, "count", new(wrapperspb.Int64Value), wrapperspb.Int64(300))
+
+	// The values are stored in the wire format, for Get and the rest
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Get, "name", &Record{b("\x0a\x05hello"), true}),
+		NewOp(Set, "name", b("\x0a\x03bye"), true),
+	})
+	CheckGetProto(t, lru, "name", wrapperspb.String("stale"), wrapperspb.String("bye"))
+}
+
+func TestProtoSize(t *testing.T) {
+	// desc := "Check that protobuf bindings are charged for their wire size"
+	limit := 20
+	lru := NewLru(limit)
+	CheckSetProto(t, lru, "n1", wrapperspb.String("hello"), nil) // 2 + 7 bytes
+	CheckSetProto(t, lru, "n2", wrapperspb.Int64(300), nil)      // 2 + 3 bytes
+	CheckSetProto(t, lru, "n3", wrapperspb.Int64(1), nil)        // 2 + 2 bytes
+	ExecuteOperations(t, lru, []Operation{NewOp(Remaining, limit-18)})
+
+	// The next evicts the first
+	CheckSetProto(t, lru, "n4", wrapperspb.Int64(1000), nil) // 2 + 3 bytes
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Peek, "n1", &Record{nil, false}),
+		NewOp(Remaining, limit-14),
+	})
+
+	// One too large to fit is rejected, leaving everything as it was
+	CheckSetProto(t, lru, "n2", wrapperspb.String("much too long to fit"), ErrTooLarge)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Peek, "n2", &Record{b("\x08\xac\x02"), true}),
+		NewOp(Remaining, limit-14),
+	})
+}
+
+func TestProtoMarshalError(t *testing.T) {
+	// desc := "Check that a message that cannot be encoded leaves the LRU unchanged"
+	lru := NewLru(1024)
+	CheckSetProto(t, lru, "name", wrapperspb.String("hello"), nil)
+
+	// proto3 strings must be valid UTF-8
+	if err := lru.SetProto("name", wrapperspb.String("\xff")); err == nil {
+		t.Errorf(operationFailMessage, "SetProto", `"name", "\xff"`,
+			Expected{"an error"}, Expected{err})
+	}
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Get, "name", &Record{b("\x0a\x05hello"), true}),
+		NewOp(Len, 1),
+	})
+}
+
+func TestProtoGetErrors(t *testing.T) {
+	// desc := "Check that GetProto reports misses and values that do not decode"
+	lru := NewLru(1024)
+	if err := lru.GetProto("missing", new(wrapperspb.StringValue)); err != ErrNotFound {
+		t.Errorf(operationFailMessage, "GetProto", `"missing"`, Expected{ErrNotFound}, Expected{err})
+	}
+
+	lru.Set("bad", b("not proto"))
+	if err := lru.GetProto("bad", new(wrapperspb.StringValue)); err == nil {
+		t.Errorf(operationFailMessage, "GetProto", `"bad"`, Expected{"an error"}, Expected{err})
+	}
+
+	// The binding is still there
+	ExecuteOperations(t, lru, []Operation{NewOp(Peek, "bad", &Record{b("not proto"), true})})
+}
+
+func TestProtoLoads(t *testing.T) {
+	// desc := "Check that GetProto loads a missing value like Fetch"
+	lru := NewLru(1024)
+	lru.SetLoader(NewFakeLoader(map[string][]byte{"name": b("\x0a\x05hello")}))
+	CheckGetProto(t, lru, "name", new(wrapperspb.StringValue), wrapperspb.String("hello"))
+	ExecuteOperations(t, lru, []Operation{NewOp(Peek, "name", &Record{b("\x0a\x05hello"), true})})
+}
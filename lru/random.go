@@ -0,0 +1,34 @@
+package lru
+
+// RandomPolicy evicts a binding chosen uniformly at random, using a
+// pseudo-random generator seeded when the policy is created, so that the
+// same seed always makes the same choices
+type RandomPolicy struct {
+	// whatever fields you want here
+}
+
+func NewRandomPolicy(seed int64) *RandomPolicy {
+	return new(RandomPolicy)
+}
+
+// NewRandom returns a new random-eviction cache with capacity to store limit
+// bytes, whose choices are determined by seed
+func NewRandom(limit int, seed int64) *PolicyCache {
+	return NewPolicyCache(limit, NewRandomPolicy(seed))
+}
+
+func (p *RandomPolicy) Add(key string, size int) {
+}
+
+func (p *RandomPolicy) Update(key string, size int) {
+}
+
+func (p *RandomPolicy) Access(key string) {
+}
+
+func (p *RandomPolicy) Remove(key string) {
+}
+
+func (p *RandomPolicy) Evict() string {
+	return ""
+}
@@ -0,0 +1,70 @@
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Random eviction tests
+ ******************************************************************************/
+
+// residents returns the keys in keys that are bound in c
+func residents(c *PolicyCache, keys []string) []string {
+	present := []string{}
+	for _, key := range keys {
+		if c.Contains(key) {
+			present = append(present, key)
+		}
+	}
+	return present
+}
+
+func TestRandomDeterministic(t *testing.T) {
+	// desc := "Check that random caches with the same seed make the same choices"
+	limit := 16 * 8 // room for 16 bindings
+	trace := ScanTrace(24, 2, 8, 10)
+	keys := ScanTrace(24, 1, 8*10, 1)
+
+	c1, c2 := NewRandom(limit, 316), NewRandom(limit, 316)
+	h1, h2 := ReplayHits(c1, trace), ReplayHits(c2, trace)
+	r1, r2 := residents(c1, keys), residents(c2, keys)
+
+	if h1 != h2 || fmt.Sprint(r1) != fmt.Sprint(r2) {
+		t.Errorf("Two random caches seeded with 316 disagreed: one hit %d times "+
+			"and held %v, the other hit %d times and held %v", h1, r1, h2, r2)
+	}
+}
+
+func TestRandomUniform(t *testing.T) {
+	// desc := "Check that every binding is about equally likely to be evicted"
+	limit := 16 // room for 4 bindings
+	c := NewRandom(limit, 316)
+	keys := []string{"k0", "k1", "k2", "k3"}
+
+	trials := 4000
+	evicted := map[string]int{}
+	for i := 0; i < trials; i++ {
+		for _, key := range keys {
+			c.Set(key, b("vv"))
+		}
+		c.Set("k4", b("vv")) // evicts one of the others
+		if !c.Contains("k4") {
+			t.Fatalf("Set(\"k4\") evicted the binding it added")
+		}
+		for _, key := range keys {
+			if !c.Contains(key) {
+				evicted[key]++
+			}
+			c.Remove(key)
+		}
+		c.Remove("k4")
+	}
+
+	for _, key := range keys {
+		if n := evicted[key]; n < trials/4*8/10 || n > trials/4*12/10 {
+			t.Errorf("%s was evicted in %d of %d trials; expected about %d",
+				key, n, trials, trials/4)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package lru
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// The registry holds the caches of the whole process, by name
+var registry = struct {
+	sync.Mutex
+	caches map[string]ByteCache
+}{caches: map[string]ByteCache{}}
+
+// Register makes cache available to Lookup under name, or returns an error
+// wrapping ErrRegistered if another cache already has the name
+func Register(name string, cache ByteCache) error {
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.caches[name]; ok {
+		return fmt.Errorf("%q: %w", name, ErrRegistered)
+	}
+	registry.caches[name] = cache
+	return nil
+}
+
+// Lookup returns the cache registered under name
+func Lookup(name string) (ByteCache, bool) {
+	registry.Lock()
+	defer registry.Unlock()
+	cache, ok := registry.caches[name]
+	return cache, ok
+}
+
+// Registered returns the names of the registered caches, in increasing order
+func Registered() []string {
+	registry.Lock()
+	defer registry.Unlock()
+	names := make([]string, 0, len(registry.caches))
+	for name := range registry.caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Unregister removes the cache registered under name and, if it is an
+// io.Closer (as an LRU is), closes it, returning the error from Close. It
+// returns an error wrapping ErrNotRegistered if no cache has the name.
+func Unregister(name string) error {
+	registry.Lock()
+	cache, ok := registry.caches[name]
+	delete(registry.caches, name)
+	registry.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%q: %w", name, ErrNotRegistered)
+	}
+	if c, ok := cache.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// UnregisterAll unregisters every cache, as Unregister does, and returns the
+// errors from closing them joined together
+func UnregisterAll() error {
+	var errs []error
+	for _, name := range Registered() {
+		if err := Unregister(name); err != nil && !errors.Is(err, ErrNotRegistered) {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// CacheInfo describes a registered cache
+type CacheInfo struct {
+	Name             string `json:"name"`
+	Len              int    `json:"len"`
+	MaxStorage       int    `json:"max_storage"`
+	RemainingStorage int    `json:"remaining_storage"`
+
+	// Stats is set for caches with a Stats method, as an LRU has
+	Stats *Stats `json:"stats,omitempty"`
+}
+
+// Describe returns a CacheInfo for every registered cache, in increasing
+// order of name
+func Describe() []CacheInfo {
+	registry.Lock()
+	defer registry.Unlock()
+	infos := make([]CacheInfo, 0, len(registry.caches))
+	for name, cache := range registry.caches {
+		info := CacheInfo{
+			Name:             name,
+			Len:              cache.Len(),
+			MaxStorage:       cache.MaxStorage(),
+			RemainingStorage: cache.RemainingStorage(),
+		}
+		if c, ok := cache.(interface{ Stats() Stats }); ok {
+			stats := c.Stats()
+			info.Stats = &stats
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// RegistryHandler serves GET requests with the result of Describe, as a JSON
+// array. It calls the registered caches as it serves, so they must be safe
+// for concurrent use if it is.
+func RegistryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Describe())
+	})
+}
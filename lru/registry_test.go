@@ -0,0 +1,126 @@
+package lru
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+/******************************************************************************
+ *                              Registry tests
+ ******************************************************************************/
+
+// emptyRegistry makes sure the registry is empty for the test and after it
+func emptyRegistry(t *testing.T) {
+	t.Helper()
+	UnregisterAll()
+	t.Cleanup(func() { UnregisterAll() })
+}
+
+// closeCounter is a ByteCache that counts how often it is closed
+type closeCounter struct {
+	ByteCache
+	closes int
+	err    error
+}
+
+func (c *closeCounter) Close() error {
+	c.closes++
+	return c.err
+}
+
+func TestRegistryLookup(t *testing.T) {
+	// desc := "Check that registered caches are found by name, and names are unique"
+	emptyRegistry(t)
+	sessions, pages := NewLru(64), NewByteCache(64)
+
+	if err := Register("sessions", sessions); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := Register("pages", pages); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := Register("sessions", pages); !errors.Is(err, ErrRegistered) {
+		t.Errorf(operationFailMessage, "Register", "\"sessions\"", Expected{ErrRegistered}, Expected{err})
+	}
+
+	if c, ok := Lookup("sessions"); !ok || c != ByteCache(sessions) {
+		t.Errorf("Expected Lookup(\"sessions\") to find the LRU registered under that name")
+	}
+	if _, ok := Lookup("users"); ok {
+		t.Errorf("Expected Lookup(\"users\") to find nothing")
+	}
+	if names := Registered(); fmt.Sprint(names) != "[pages sessions]" {
+		t.Errorf(operationFailMessage, "Registered", &Args{}, Expected{[]string{"pages", "sessions"}}, Expected{names})
+	}
+}
+
+func TestRegistryLifecycle(t *testing.T) {
+	// desc := "Check that unregistering closes what can be closed, and frees the name"
+	emptyRegistry(t)
+	errClose := errors.New("close failed")
+	plain := &closeCounter{ByteCache: NewByteCache(64)}
+	failing := &closeCounter{ByteCache: NewByteCache(64), err: errClose}
+	Register("plain", plain)
+	Register("failing", failing)
+	Register("uncloseable", NewByteCache(64))
+
+	if err := Unregister("plain"); err != nil || plain.closes != 1 {
+		t.Errorf("Expected Unregister to close the cache once, found %d closes and error %v", plain.closes, err)
+	}
+	if err := Unregister("plain"); !errors.Is(err, ErrNotRegistered) {
+		t.Errorf(operationFailMessage, "Unregister", "\"plain\"", Expected{ErrNotRegistered}, Expected{err})
+	}
+	if err := Register("plain", plain); err != nil {
+		t.Errorf("Expected an unregistered name to be free, found %v", err)
+	}
+
+	if err := UnregisterAll(); !errors.Is(err, errClose) {
+		t.Errorf(operationFailMessage, "UnregisterAll", &Args{}, Expected{errClose}, Expected{err})
+	}
+	if len(Registered()) != 0 || plain.closes != 2 || failing.closes != 1 {
+		t.Errorf("Expected UnregisterAll to close and remove every cache, found %v left", Registered())
+	}
+}
+
+func TestRegistryHandler(t *testing.T) {
+	// desc := "Check that the stats endpoint describes every registered cache"
+	emptyRegistry(t)
+	sessions := NewLru(64)
+	sessions.Set("k1", b("v1"))
+	sessions.Get("k1")
+	Register("sessions", sessions)
+	Register("pages", NewSyncLru(NewByteCache(32)))
+
+	w := httptest.NewRecorder()
+	RegistryHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/caches", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("Expected a JSON response, found %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var infos []CacheInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &infos); err != nil {
+		t.Fatalf("Decoding the response: %v", err)
+	}
+
+	stats := sessions.Stats()
+	expected := []CacheInfo{
+		{"pages", 0, 32, 32, nil},
+		{"sessions", sessions.Len(), 64, sessions.RemainingStorage(), &stats},
+	}
+	if !reflect.DeepEqual(Describe(), expected) {
+		t.Errorf(operationFailMessage, "Describe", &Args{}, Expected{expected}, Expected{Describe()})
+	}
+	if !reflect.DeepEqual(infos, expected) {
+		t.Errorf("Expected the response to describe %+v, found %+v", expected, infos)
+	}
+
+	w = httptest.NewRecorder()
+	RegistryHandler().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/caches", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("Expected POST to be refused, found %d", w.Code)
+	}
+}
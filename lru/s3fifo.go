@@ -0,0 +1,37 @@
+package lru
+
+// S3FifoPolicy is the S3-FIFO policy, which admits new bindings to a small
+// FIFO queue so that bindings used only once are evicted quickly, moves
+// bindings used again to a main FIFO queue, and remembers recently evicted
+// keys in a ghost queue so that they are admitted straight to the main queue
+// if they come back
+type S3FifoPolicy struct {
+	// whatever fields you want here
+}
+
+// NewS3FifoPolicy returns an S3-FIFO policy for a cache with capacity to store
+// limit bytes
+func NewS3FifoPolicy(limit int) *S3FifoPolicy {
+	return new(S3FifoPolicy)
+}
+
+// NewS3Fifo returns a new S3-FIFO cache with capacity to store limit bytes
+func NewS3Fifo(limit int) *PolicyCache {
+	return NewPolicyCache(limit, NewS3FifoPolicy(limit))
+}
+
+func (p *S3FifoPolicy) Add(key string, size int) {
+}
+
+func (p *S3FifoPolicy) Update(key string, size int) {
+}
+
+func (p *S3FifoPolicy) Access(key string) {
+}
+
+func (p *S3FifoPolicy) Remove(key string) {
+}
+
+func (p *S3FifoPolicy) Evict() string {
+	return ""
+}
@@ -0,0 +1,95 @@
+package lru
+
+import "testing"
+
+/******************************************************************************
+ *                             S3-FIFO tests
+ ******************************************************************************/
+
+func TestS3FifoOneHitWonders(t *testing.T) {
+	// desc := "Check that bindings used only once are evicted before bindings used again"
+	ops := []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k2", &Record{b("v2"), true}),
+		NewOp(Set, "k5", b("v5"), true), // S3-FIFO promotes k1 and k2, evicts k3; LRU evicts k3
+		NewOp(Set, "k6", b("v6"), true), // both evict k4
+		NewOp(Set, "k7", b("v7"), true), // S3-FIFO evicts k5, LRU evicts k1
+	}
+	RunPolicyTrace(t, PolicyTrace{
+		limit: 16, // room for 4 bindings
+		ops:   ops,
+		present: map[string][]string{
+			"S3-FIFO": {"k1", "k2", "k6", "k7"},
+			"LRU":     {"k2", "k5", "k6", "k7"},
+		},
+		absent: map[string][]string{
+			"S3-FIFO": {"k3", "k4", "k5"},
+			"LRU":     {"k1", "k3", "k4"},
+		},
+	}, map[string]func() Policy{
+		"S3-FIFO": func() Policy { return NewS3FifoPolicy(16) },
+		"LRU":     func() Policy { return NewLruPolicy() },
+	})
+}
+
+func TestS3FifoGhostReadmission(t *testing.T) {
+	// desc := "Check that a recently evicted key is admitted straight to the main queue"
+	limit := 16 // room for 4 bindings
+	c := NewS3Fifo(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k2", &Record{b("v2"), true}),
+		NewOp(Set, "k5", b("v5"), true), // evicts k3 to the ghost queue
+		NewOp(Set, "k6", b("v6"), true), // evicts k4
+		NewOp(Set, "k7", b("v7"), true), // evicts k5
+		NewOp(Set, "k3", b("33"), true), // joins the main queue, evicts k6
+		NewOp(Set, "k8", b("v8"), true), // evicts k7 from the small queue
+	})
+	CheckResident(t, c, []string{"k1", "k2", "k3", "k8"}, []string{"k4", "k5", "k6", "k7"})
+}
+
+func TestS3FifoMainSecondChance(t *testing.T) {
+	// desc := "Check that the main queue reinserts bindings used since they last reached its tail"
+	limit := 16 // room for 4 bindings
+	c := NewS3Fifo(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k2", &Record{b("v2"), true}),
+		NewOp(Get, "k3", &Record{b("v3"), true}),
+		NewOp(Set, "k5", b("v5"), true), // promotes k1, k2 and k3, evicts k4
+		NewOp(Remove, "k5", &Record{b("v5"), true}),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+
+		// The small queue holds nothing else, so the main queue spares k1
+		NewOp(Set, "k6", b("v6v6v6"), true),
+	})
+	CheckResident(t, c, []string{"k1", "k3", "k6"}, []string{"k2", "k4", "k5"})
+}
+
+func TestS3FifoHitRatio(t *testing.T) {
+	// desc := "Check that S3-FIFO beats LRU on a workload mixing hot keys with scans"
+	// Hot keys are used twice per round, but a round uses more keys than fit
+	limit := 64 * 8 // room for 64 bindings
+	trace := ScanTrace(48, 2, 40, 50)
+
+	s3 := ReplayHits(NewS3Fifo(limit), trace)
+	lru := ReplayHits(NewPolicyCache(limit, NewLruPolicy()), trace)
+	if 2*s3 < 3*lru || 2*s3 < len(trace) {
+		t.Errorf("S3-FIFO hit %d and LRU hit %d of %d Gets; expected S3-FIFO to hit at "+
+			"least 1.5 times as often as LRU, and at least half of the time", s3, lru, len(trace))
+	}
+}
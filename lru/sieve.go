@@ -0,0 +1,34 @@
+package lru
+
+// SievePolicy is the SIEVE policy: a FIFO queue of bindings whose hand sweeps
+// from the oldest towards the newest, sparing bindings visited since it last
+// passed them. Unlike LRU, a hit only sets a visited bit, and never moves a
+// binding in the queue.
+type SievePolicy struct {
+	// whatever fields you want here
+}
+
+func NewSievePolicy() *SievePolicy {
+	return new(SievePolicy)
+}
+
+// NewSieve returns a new SIEVE cache with capacity to store limit bytes
+func NewSieve(limit int) *PolicyCache {
+	return NewPolicyCache(limit, NewSievePolicy())
+}
+
+func (p *SievePolicy) Add(key string, size int) {
+}
+
+func (p *SievePolicy) Update(key string, size int) {
+}
+
+func (p *SievePolicy) Access(key string) {
+}
+
+func (p *SievePolicy) Remove(key string) {
+}
+
+func (p *SievePolicy) Evict() string {
+	return ""
+}
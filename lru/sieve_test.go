@@ -0,0 +1,121 @@
+package lru
+
+import "testing"
+
+/******************************************************************************
+ *                             SIEVE tests
+ ******************************************************************************/
+
+func TestSieveVisitedSpared(t *testing.T) {
+	// desc := "Check that the hand spares visited bindings and evicts the first unvisited one"
+	limit := 16 // room for 4 bindings
+	c := NewSieve(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k3", &Record{b("v3"), true}),
+		NewOp(Set, "k5", b("v5"), true), // spares k1, evicts k2
+		NewOp(Set, "k6", b("v6"), true), // spares k3, evicts k4
+	})
+	CheckResident(t, c, []string{"k1", "k3", "k5", "k6"}, []string{"k2", "k4"})
+}
+
+func TestSieveHandPersists(t *testing.T) {
+	// desc := "Check that the hand resumes where it stopped, rather than at the oldest binding"
+	ops := []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "k5", b("v5"), true), // both evict k2, leaving the hand at k3
+		NewOp(Set, "k6", b("v6"), true), // both evict k3
+		NewOp(Set, "k7", b("v7"), true), // SIEVE evicts k4, LRU evicts k4
+		NewOp(Set, "k8", b("v8"), true), // SIEVE evicts k5, LRU evicts k1
+	}
+	RunPolicyTrace(t, PolicyTrace{
+		limit: 16, // room for 4 bindings
+		ops:   ops,
+		present: map[string][]string{
+			"SIEVE": {"k1", "k6", "k7", "k8"},
+			"LRU":   {"k5", "k6", "k7", "k8"},
+		},
+		absent: map[string][]string{
+			"SIEVE": {"k2", "k3", "k4", "k5"},
+			"LRU":   {"k1", "k2", "k3", "k4"},
+		},
+	}, map[string]func() Policy{
+		"SIEVE": func() Policy { return NewSievePolicy() },
+		"LRU":   func() Policy { return NewLruPolicy() },
+	})
+}
+
+func TestSieveWrap(t *testing.T) {
+	// desc := "Check that the hand wraps back to the oldest binding after the newest"
+	limit := 16 // room for 4 bindings
+	c := NewSieve(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Get, "k4", &Record{b("v4"), true}),
+		NewOp(Get, "k3", &Record{b("v3"), true}),
+		NewOp(Get, "k2", &Record{b("v2"), true}),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "k5", b("v5"), true), // clears every bit, wraps, evicts k1
+		NewOp(Set, "k6", b("v6"), true), // evicts k2
+	})
+	CheckResident(t, c, []string{"k3", "k4", "k5", "k6"}, []string{"k1", "k2"})
+}
+
+func TestSieveUpdateVisits(t *testing.T) {
+	// desc := "Check that overwriting a binding marks it visited without moving it"
+	limit := 12 // room for 3 bindings
+	c := NewSieve(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k1", b("11"), true),
+		NewOp(Set, "k4", b("v4"), true), // spares k1, evicts k2
+		NewOp(Set, "k5", b("v5"), true), // evicts k3
+		NewOp(Set, "k6", b("v6"), true), // evicts k4, as the hand has not wrapped
+	})
+
+	// k1 is still the oldest binding, so it outlives newer unvisited ones
+	CheckResident(t, c, []string{"k1", "k5", "k6"}, []string{"k2", "k3", "k4"})
+}
+
+func TestSieveRemoveAtHand(t *testing.T) {
+	// desc := "Check that removing the binding under the hand moves the hand towards the newest"
+	limit := 16 // room for 4 bindings
+	c := NewSieve(limit)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "k5", b("v5"), true), // evicts k2, leaving the hand at k3
+		NewOp(Remove, "k3", &Record{b("v3"), true}),
+		NewOp(Set, "k6", b("v6"), true),
+		NewOp(Set, "k7", b("v7"), true), // evicts k4
+	})
+	CheckResident(t, c, []string{"k1", "k5", "k6", "k7"}, []string{"k2", "k3", "k4"})
+}
+
+func BenchmarkPolicyHitsSieve(b *testing.B) {
+	benchmarkPolicyHits(b, NewSievePolicy())
+}
+
+func BenchmarkPolicyZipfSieve(b *testing.B) {
+	benchmarkPolicyZipf(b, NewSievePolicy())
+}
@@ -0,0 +1,36 @@
+package lru
+
+// SlruPolicy is a segmented LRU policy: bindings start on probation, are
+// promoted to a protected segment when used again, and are only evicted once
+// they have been demoted back to probation
+type SlruPolicy struct {
+	// whatever fields you want here
+}
+
+// NewSlruPolicy returns an SLRU policy for a cache with capacity to store
+// limit bytes, whose protected segment may hold up to protectedRatio of them
+func NewSlruPolicy(limit int, protectedRatio float64) *SlruPolicy {
+	return new(SlruPolicy)
+}
+
+// NewSlru returns a new SLRU cache with capacity to store limit bytes, up to
+// protectedRatio of which are protected
+func NewSlru(limit int, protectedRatio float64) *PolicyCache {
+	return NewPolicyCache(limit, NewSlruPolicy(limit, protectedRatio))
+}
+
+func (p *SlruPolicy) Add(key string, size int) {
+}
+
+func (p *SlruPolicy) Update(key string, size int) {
+}
+
+func (p *SlruPolicy) Access(key string) {
+}
+
+func (p *SlruPolicy) Remove(key string) {
+}
+
+func (p *SlruPolicy) Evict() string {
+	return ""
+}
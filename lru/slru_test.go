@@ -0,0 +1,132 @@
+package lru
+
+import "testing"
+
+/******************************************************************************
+ *                             SLRU tests
+ ******************************************************************************/
+
+func TestSlruPromotion(t *testing.T) {
+	// desc := "Check that a binding used a second time is protected from a stream of new ones"
+	ops := []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}), // promotes k1
+		NewOp(Set, "k5", b("v5"), true),          // both evict k2
+		NewOp(Set, "k6", b("v6"), true),          // both evict k3
+		NewOp(Set, "k7", b("v7"), true),          // both evict k4
+		NewOp(Set, "k8", b("v8"), true),          // SLRU evicts k5, LRU evicts k1
+	}
+	RunPolicyTrace(t, PolicyTrace{
+		limit: 16, // room for 4 bindings
+		ops:   ops,
+		present: map[string][]string{
+			"SLRU": {"k1", "k6", "k7", "k8"},
+			"LRU":  {"k5", "k6", "k7", "k8"},
+		},
+		absent: map[string][]string{
+			"SLRU": {"k2", "k3", "k4", "k5"},
+			"LRU":  {"k1", "k2", "k3", "k4"},
+		},
+	}, map[string]func() Policy{
+		"SLRU": func() Policy { return NewSlruPolicy(16, 0.5) },
+		"LRU":  func() Policy { return NewLruPolicy() },
+	})
+}
+
+func TestSlruDemotion(t *testing.T) {
+	// desc := "Check that protected overflow demotes the LRU protected binding to probation"
+	limit := 16 // room for 4 bindings, 2 of them protected
+	c := NewSlru(limit, 0.5)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k2", &Record{b("v2"), true}),
+		NewOp(Get, "k3", &Record{b("v3"), true}), // demotes k1 behind k4
+		NewOp(Set, "k5", b("v5"), true),          // evicts k4
+	})
+	CheckResident(t, c, []string{"k1", "k2", "k3", "k5"}, []string{"k4"})
+
+	// k1 is the most recently used binding on probation, so it goes second
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k6", b("v6"), true), // evicts k1
+		NewOp(Set, "k7", b("v7"), true), // evicts k5
+	})
+	CheckResident(t, c, []string{"k2", "k3", "k6", "k7"}, []string{"k1", "k5"})
+}
+
+func TestSlruUpdatePromotes(t *testing.T) {
+	// desc := "Check that overwriting a binding promotes it like a Get"
+	limit := 12 // room for 3 bindings
+	c := NewSlru(limit, 0.5)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k1", b("11"), true), // promotes k1
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true), // evicts k2
+		NewOp(Set, "k5", b("v5"), true), // evicts k3
+	})
+	CheckResident(t, c, []string{"k1", "k4", "k5"}, []string{"k2", "k3"})
+}
+
+func TestSlruRatios(t *testing.T) {
+	// desc := "Check that the protected ratio decides how many bindings are protected"
+	ops := []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k2", &Record{b("v2"), true}),
+		NewOp(Get, "k3", &Record{b("v3"), true}),
+		NewOp(Set, "k5", b("v5"), true),
+		NewOp(Set, "k6", b("v6"), true),
+		NewOp(Set, "k7", b("v7"), true),
+	}
+	RunPolicyTrace(t, PolicyTrace{
+		limit: 16, // room for 4 bindings
+		ops:   ops,
+		present: map[string][]string{
+			"none":  {"k3", "k5", "k6", "k7"}, // nothing is protected, as in LRU
+			"half":  {"k2", "k3", "k6", "k7"}, // k1 was demoted
+			"three": {"k1", "k2", "k3", "k7"},
+			"all":   {"k1", "k2", "k3", "k7"},
+		},
+		absent: map[string][]string{
+			"none":  {"k1", "k2", "k4"},
+			"half":  {"k1", "k4", "k5"},
+			"three": {"k4", "k5", "k6"},
+			"all":   {"k4", "k5", "k6"},
+		},
+	}, map[string]func() Policy{
+		"none":  func() Policy { return NewSlruPolicy(16, 0) },
+		"half":  func() Policy { return NewSlruPolicy(16, 0.5) },
+		"three": func() Policy { return NewSlruPolicy(16, 0.75) },
+		"all":   func() Policy { return NewSlruPolicy(16, 1) },
+	})
+}
+
+func TestSlruAllProtected(t *testing.T) {
+	// desc := "Check that SLRU evicts from the protected segment once probation is empty"
+	limit := 12 // room for 3 bindings, all of which may be protected
+	c := NewSlru(limit, 1)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Get, "k2", &Record{b("v2"), true}),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k3", &Record{b("v3"), true}),
+		NewOp(Set, "k4", b("v4"), true), // evicts k2 from the protected segment
+	})
+	CheckResident(t, c, []string{"k1", "k3", "k4"}, []string{"k2"})
+}
@@ -0,0 +1,19 @@
+package lru
+
+import "io"
+
+func (lru *LRU) Save(w io.Writer) error {
+	return nil
+}
+
+func (lru *LRU) Load(r io.Reader) error {
+	return nil
+}
+
+func (lru *LRU) MarshalBinary() ([]byte, error) {
+	return nil, nil
+}
+
+func (lru *LRU) UnmarshalBinary(data []byte) error {
+	return nil
+}
@@ -0,0 +1,189 @@
+package lru
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+/******************************************************************************
+ *                             Snapshot tests
+ ******************************************************************************/
+
+// snapshot returns what lru.Save writes
+func snapshot(t *testing.T, lru *LRU) []byte {
+	var buf bytes.Buffer
+	if err := lru.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// restore loads data into lru, failing the test on an error
+func restore(t *testing.T, lru *LRU, data []byte) {
+	if err := lru.Load(bytes.NewReader(data)); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	// desc := "Check that a restored LRU holds the same bindings, in the same order"
+	limit := 16 // room for 4 bindings
+	original := NewLru(limit)
+	ExecuteOperations(t, original, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k3", &Record{b("v3"), true}),
+	})
+
+	restored := NewLru(limit)
+	restore(t, restored, snapshot(t, original))
+	CheckKeys(t, restored, []string{"k2", "k4", "k1", "k3"})
+
+	// Both evict in the same order from here on
+	ops := []Operation{
+		NewOp(Set, "k5", b("v5"), true), // evicts k2
+		NewOp(Set, "k6", b("v6"), true), // evicts k4
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "k7", b("v7"), true), // evicts k3
+		NewOp(Remaining, 0),
+		NewOp(Len, 4),
+	}
+	ExecuteOperations(t, original, ops)
+	ExecuteOperations(t, restored, ops)
+	CheckKeys(t, restored, original.Keys())
+}
+
+func TestSnapshotEdgeCases(t *testing.T) {
+	// desc := "Check that empty values, binary keys and empty LRUs survive a round trip"
+	original := NewLru(1024)
+	restored := NewLru(1024)
+	restore(t, restored, snapshot(t, original))
+	CheckKeys(t, restored, []string{})
+
+	binary := string([]byte{0, 1, 0xff, '\n'})
+	ExecuteOperations(t, original, []Operation{
+		NewOp(Set, "", b("empty key"), true),
+		NewOp(Set, "k1", b(""), true),
+		NewOp(Set, binary, []byte{0, 0, 0x80}, true),
+	})
+	restore(t, restored, snapshot(t, original))
+	ExecuteOperations(t, restored, []Operation{
+		NewOp(Peek, "", &Record{b("empty key"), true}),
+		NewOp(Peek, "k1", &Record{b(""), true}),
+		NewOp(Peek, binary, &Record{[]byte{0, 0, 0x80}, true}),
+		NewOp(Remaining, original.RemainingStorage()),
+	})
+	CheckKeys(t, restored, original.Keys())
+}
+
+func TestSnapshotSmaller(t *testing.T) {
+	// desc := "Check that restoring into a smaller LRU keeps the most recently used bindings"
+	original := NewLru(16)
+	for _, key := range []string{"k1", "k2", "k3", "k4"} {
+		original.Set(key, b("v"+key[1:]))
+	}
+	original.Get("k2")
+
+	restored := NewLru(8)
+	restore(t, restored, snapshot(t, original))
+	CheckKeys(t, restored, []string{"k4", "k2"})
+}
+
+func TestSnapshotTTL(t *testing.T) {
+	// desc := "Check that expiry times survive a round trip, and expired bindings do not"
+	clock := NewFakeClock()
+	original := NewLru(1024)
+	original.SetClock(clock)
+	original.SetWithTTL("k1", b("v1"), time.Minute)
+	original.SetWithTTL("k2", b("v2"), time.Hour)
+	original.Set("k3", b("v3"))
+	data := snapshot(t, original)
+
+	restored := NewLru(1024)
+	restored.SetClock(clock)
+	clock.Advance(2 * time.Minute)
+	restore(t, restored, data)
+	CheckKeys(t, restored, []string{"k2", "k3"})
+
+	clock.Advance(time.Hour)
+	ExecuteOperations(t, restored, []Operation{
+		NewOp(Peek, "k2", &Record{nil, false}),
+		NewOp(Peek, "k3", &Record{b("v3"), true}),
+	})
+}
+
+func TestSnapshotFormat(t *testing.T) {
+	// desc := "Check that Save writes exactly the documented format"
+	lru := NewLru(1024)
+	lru.Set("k2", b(""))
+	lru.Set("k1", b("v1"))
+	lru.Get("k2")
+
+	expected := "4c52555301" + // magic "LRUS" and version 1
+		"02" + // 2 bindings, least recently used first
+		"026b31" + "027631" + "00" + // "k1" = "v1", no expiry
+		"026b32" + "00" + "00" + // "k2" = "", no expiry
+		"6cd90f63" // CRC-32 of everything above
+	if got := hex.EncodeToString(snapshot(t, lru)); got != expected {
+		t.Errorf("Expected the snapshot\n%s\nfound\n%s", expected, got)
+	}
+}
+
+func TestSnapshotCorrupt(t *testing.T) {
+	// desc := "Check that Load rejects truncated or damaged snapshots, changing nothing"
+	original := NewLru(1024)
+	original.Set("k1", b("v1"))
+	original.SetWithTTL("k2", b("v2"), time.Hour)
+	data := snapshot(t, original)
+
+	lru := NewLru(1024)
+	lru.Set("k0", b("v0"))
+	check := func(what string, input []byte) {
+		if err := lru.Load(bytes.NewReader(input)); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("Loading %s: expected %v, found %v", what, ErrCorrupt, err)
+		}
+		CheckKeys(t, lru, []string{"k0"})
+	}
+
+	for n := 0; n < len(data); n++ {
+		check(fmt.Sprintf("the first %d bytes", n), data[:n])
+	}
+	for i := range data {
+		damaged := append([]byte(nil), data...)
+		damaged[i] ^= 0x10
+		check(fmt.Sprintf("a snapshot with byte %d damaged", i), damaged)
+	}
+	check("garbage", []byte("not a snapshot at all"))
+}
+
+func TestSnapshotIOErrors(t *testing.T) {
+	// desc := "Check that errors reading or writing a snapshot are returned"
+	lru := NewLru(1024)
+	lru.Set("k1", b("v1"))
+
+	errDisk := errors.New("disk on fire")
+	if err := lru.Save(failingWriter{errDisk}); !errors.Is(err, errDisk) {
+		t.Errorf(operationFailMessage, "Save", &Args{}, Expected{errDisk}, Expected{err})
+	}
+	if err := lru.Load(iotest.ErrReader(errDisk)); !errors.Is(err, errDisk) {
+		t.Errorf(operationFailMessage, "Load", &Args{}, Expected{errDisk}, Expected{err})
+	}
+	CheckKeys(t, lru, []string{"k1"})
+}
+
+// failingWriter fails every Write with err
+type failingWriter struct {
+	err error
+}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
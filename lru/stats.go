@@ -0,0 +1,35 @@
+package lru
+
+import "time"
+
+// Stats summarises how an LRU has been used since it was created, or since
+// ResetStats was last called
+type Stats struct {
+	Hits         int     // Gets that found a binding
+	Misses       int     // Gets that did not
+	Evictions    int     // bindings reported to the eviction callback
+	BytesEvicted int     // storage released by those evictions
+	Utilization  float64 // fraction of MaxStorage currently in use
+}
+
+func (lru *LRU) Stats() Stats {
+	return Stats{}
+}
+
+func (lru *LRU) ResetStats() {
+}
+
+// WindowStats summarises only the most recent Gets made on an LRU, as chosen
+// by SetStatsWindow
+type WindowStats struct {
+	Hits     int
+	Misses   int
+	HitRatio float64 // Hits / (Hits + Misses), or 0 if there were no Gets
+}
+
+func (lru *LRU) SetStatsWindow(n int, period time.Duration) {
+}
+
+func (lru *LRU) WindowStats() WindowStats {
+	return WindowStats{}
+}
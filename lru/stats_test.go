@@ -0,0 +1,262 @@
+package lru
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// CheckStats fails the test unless lru.Stats() returns expected. Utilization
+// may be computed in different ways, so it only needs to be very close.
+func CheckStats(t *testing.T, lru *LRU, expected Stats) {
+	if got := lru.Stats(); !statsMatch(got, expected) {
+		t.Errorf(operationFailMessage, "Stats", &Args{},
+			fmt.Sprintf("%+v", expected), fmt.Sprintf("%+v", got))
+	}
+}
+
+// CheckModelStats fails the test unless lru.Stats() matches the counts of a
+// reference model that has seen the same n operations
+func CheckModelStats(t testing.TB, lru *LRU, model *Model, n int) {
+	expected := model.Stats()
+	if got := lru.Stats(); !statsMatch(got, expected) {
+		t.Errorf(operationFailMessage, "Stats", &Args{},
+			fmt.Sprintf("%+v", expected), fmt.Sprintf("%+v", got))
+		t.Logf("Stats disagree with the first %d operations of the trace", n)
+	}
+}
+
+func statsMatch(got, expected Stats) bool {
+	if math.Abs(got.Utilization-expected.Utilization) < 1e-9 {
+		got.Utilization = expected.Utilization
+	}
+	return got == expected
+}
+
+/******************************************************************************
+ *                             Stats tests
+ ******************************************************************************/
+
+func TestStatsNew(t *testing.T) {
+	// desc := "Check that a new LRU reports empty stats"
+	CheckStats(t, NewLru(1024), Stats{})
+	CheckStats(t, NewLru(0), Stats{})
+}
+
+func TestStatsTrace(t *testing.T) {
+	// desc := "Check stats counters across a known trace"
+	limit := 12 // room for 3 bindings
+	lru := NewLru(limit)
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k4", &Record{nil, false}),
+	})
+	CheckStats(t, lru, Stats{Hits: 1, Misses: 1, Utilization: 1})
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k4", b("v4"), true), // evicts k2
+		NewOp(Get, "k2", &Record{nil, false}),
+		NewOp(Peek, "k3", &Record{b("v3"), true}),
+		NewOp(Peek, "k2", &Record{nil, false}),
+		NewOp(Remove, "k4", &Record{b("v4"), true}),
+	})
+	CheckStats(t, lru, Stats{Hits: 1, Misses: 2, Evictions: 1, BytesEvicted: 4, Utilization: 8.0 / 12})
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "big", b("123456789"), true), // evicts k3 and k1
+		NewOp(Get, "big", &Record{b("123456789"), true}),
+		NewOp(Get, "big", &Record{b("123456789"), true}),
+	})
+	CheckStats(t, lru, Stats{Hits: 3, Misses: 2, Evictions: 3, BytesEvicted: 12, Utilization: 1})
+}
+
+func TestStatsOtherEvictions(t *testing.T) {
+	// desc := "Check that Resize and Purge evictions are counted"
+	limit := 20
+	lru := NewLru(limit)
+
+	ops := []Operation{}
+	for i := 0; i < 5; i++ {
+		ops = append(ops, NewOp(Set, fmt.Sprintf("k%d", i), b(fmt.Sprintf("v%d", i)), true))
+	}
+	ExecuteOperations(t, lru, ops)
+
+	lru.Resize(10)
+	CheckStats(t, lru, Stats{Evictions: 3, BytesEvicted: 12, Utilization: 0.8})
+
+	lru.Purge()
+	CheckStats(t, lru, Stats{Evictions: 5, BytesEvicted: 20})
+}
+
+func TestResetStats(t *testing.T) {
+	// desc := "Check that ResetStats zeroes counters but not utilization"
+	limit := 8
+	lru := NewLru(limit)
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true), // evicts k1
+		NewOp(Get, "k1", &Record{nil, false}),
+		NewOp(Get, "k2", &Record{b("v2"), true}),
+	})
+	CheckStats(t, lru, Stats{Hits: 1, Misses: 1, Evictions: 1, BytesEvicted: 4, Utilization: 1})
+
+	lru.ResetStats()
+	CheckStats(t, lru, Stats{Utilization: 1})
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Get, "k3", &Record{b("v3"), true}),
+		NewOp(Remove, "k2", &Record{b("v2"), true}),
+	})
+	CheckStats(t, lru, Stats{Hits: 1, Utilization: 0.5})
+}
+
+func TestModelStats(t *testing.T) {
+	// desc := "Check that the reference model counts stats as an LRU must"
+	model := NewModel(12) // room for 3 bindings
+	for _, op := range []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Get, "k4", &Record{nil, false}),
+		NewOp(Set, "k4", b("v4"), true), // evicts k2
+		NewOp(Touch, "k3", true),
+		NewOp(Remove, "k4", &Record{b("v4"), true}),
+		NewOp(Set, "big", b("123456789"), true), // evicts k1 and k3
+		NewOp(Get, "big", &Record{b("123456789"), true}),
+		NewOp(Resize, 4, 1),
+	} {
+		model.Apply(op)
+	}
+
+	expected := Stats{Hits: 2, Misses: 1, Evictions: 4, BytesEvicted: 24}
+	if got := model.Stats(); !statsMatch(got, expected) {
+		t.Errorf("Expected the model's stats to be %+v, found %+v", expected, got)
+	}
+}
+
+/******************************************************************************
+ *                             Window stats tests
+ ******************************************************************************/
+
+// CheckWindowStats fails the test unless lru.WindowStats() reports the
+// expected hits and misses, with a matching hit ratio
+func CheckWindowStats(t *testing.T, lru *LRU, hits, misses int) {
+	expected := WindowStats{Hits: hits, Misses: misses}
+	if hits+misses > 0 {
+		expected.HitRatio = float64(hits) / float64(hits+misses)
+	}
+
+	got := lru.WindowStats()
+	if got.Hits != expected.Hits || got.Misses != expected.Misses ||
+		math.Abs(got.HitRatio-expected.HitRatio) > 1e-9 {
+		t.Errorf(operationFailMessage, "WindowStats", &Args{},
+			fmt.Sprintf("%+v", expected), fmt.Sprintf("%+v", got))
+	}
+}
+
+// GetHitsAndMisses performs Gets on lru in the given order of hits ('h') and
+// misses ('m'), using a key that is bound for each hit and one that is not for
+// each miss
+func GetHitsAndMisses(t *testing.T, lru *LRU, pattern string) {
+	ops := make([]Operation, len(pattern))
+	for i, c := range pattern {
+		if c == 'h' {
+			ops[i] = NewOp(Get, "hit", &Record{b("value"), true})
+		} else {
+			ops[i] = NewOp(Get, "miss", &Record{nil, false})
+		}
+	}
+	ExecuteOperationsNoSubtests(t, lru, ops)
+}
+
+func TestWindowStatsUnbounded(t *testing.T) {
+	// desc := "Check that without a window every Get is counted"
+	lru := NewLru(1024)
+	CheckWindowStats(t, lru, 0, 0)
+
+	ExecuteOperations(t, lru, []Operation{NewOp(Set, "hit", b("value"), true)})
+	GetHitsAndMisses(t, lru, "hhmhmmhh")
+	CheckWindowStats(t, lru, 5, 3)
+
+	lru.ResetStats()
+	CheckWindowStats(t, lru, 0, 0)
+	GetHitsAndMisses(t, lru, "mh")
+	CheckWindowStats(t, lru, 1, 1)
+}
+
+func TestWindowStatsLastN(t *testing.T) {
+	// desc := "Check that a window of N only counts the last N Gets"
+	lru := NewLru(1024)
+	lru.SetStatsWindow(4, 0)
+	ExecuteOperations(t, lru, []Operation{NewOp(Set, "hit", b("value"), true)})
+
+	GetHitsAndMisses(t, lru, "mm")
+	CheckWindowStats(t, lru, 0, 2)
+	GetHitsAndMisses(t, lru, "hh")
+	CheckWindowStats(t, lru, 2, 2)
+	GetHitsAndMisses(t, lru, "h")
+	CheckWindowStats(t, lru, 3, 1)
+	GetHitsAndMisses(t, lru, "hhhh")
+	CheckWindowStats(t, lru, 4, 0)
+	GetHitsAndMisses(t, lru, "mmm")
+	CheckWindowStats(t, lru, 1, 3)
+
+	// Other operations do not move the window
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Peek, "hit", &Record{b("value"), true}),
+		NewOp(Set, "other", b("value"), true),
+		NewOp(Remove, "other", &Record{b("value"), true}),
+	})
+	CheckWindowStats(t, lru, 1, 3)
+
+	// The lifetime counters are unaffected by the window
+	CheckStats(t, lru, Stats{Hits: 7, Misses: 5, Utilization: 8.0 / 1024})
+}
+
+func TestWindowStatsPeriod(t *testing.T) {
+	// desc := "Check that a time window only counts recent Gets"
+	lru, clock := NewLruWithClock(1024)
+	lru.SetStatsWindow(0, 10*time.Second)
+	ExecuteOperations(t, lru, []Operation{NewOp(Set, "hit", b("value"), true)})
+
+	GetHitsAndMisses(t, lru, "mmm") // t=0s
+	clock.Advance(5 * time.Second)
+	GetHitsAndMisses(t, lru, "hh") // t=5s
+	CheckWindowStats(t, lru, 2, 3)
+
+	clock.Advance(5 * time.Second) // t=10s, the misses are now 10s old
+	CheckWindowStats(t, lru, 2, 0)
+
+	GetHitsAndMisses(t, lru, "m") // t=10s
+	clock.Advance(4 * time.Second)
+	CheckWindowStats(t, lru, 2, 1)
+
+	clock.Advance(time.Second) // t=15s
+	CheckWindowStats(t, lru, 0, 1)
+
+	clock.Advance(time.Hour)
+	CheckWindowStats(t, lru, 0, 0)
+}
+
+func TestWindowStatsBoth(t *testing.T) {
+	// desc := "Check that Gets must be both recent and among the last N"
+	lru, clock := NewLruWithClock(1024)
+	lru.SetStatsWindow(3, 10*time.Second)
+	ExecuteOperations(t, lru, []Operation{NewOp(Set, "hit", b("value"), true)})
+
+	GetHitsAndMisses(t, lru, "hhhh")
+	clock.Advance(8 * time.Second)
+	GetHitsAndMisses(t, lru, "m")
+	CheckWindowStats(t, lru, 2, 1)
+
+	clock.Advance(2 * time.Second)
+	CheckWindowStats(t, lru, 0, 1)
+}
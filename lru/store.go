@@ -0,0 +1,30 @@
+package lru
+
+// Store persists the bindings an LRU writes to a backing store
+type Store interface {
+	// Put binds key to value in the backing store
+	Put(key string, value []byte) error
+}
+
+// WriteMode says when an LRU writes its bindings to its Store
+type WriteMode int
+
+const (
+	// WriteThrough writes every binding to the Store as it is set
+	WriteThrough WriteMode = iota
+	// WriteBack writes bindings to the Store only when they leave the LRU or
+	// are flushed
+	WriteBack
+)
+
+func (lru *LRU) SetStore(store Store, mode WriteMode) error {
+	return nil
+}
+
+func (lru *LRU) Flush() error {
+	return nil
+}
+
+func (lru *LRU) Dirty() int {
+	return 0
+}
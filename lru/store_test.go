@@ -0,0 +1,289 @@
+package lru
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Store tests
+ ******************************************************************************/
+
+// FakeStore is a Store backed by a map, which records the keys it is asked to
+// Put, in order, and fails Puts of the keys in fail
+type FakeStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	fail   map[string]bool
+	puts   []string
+}
+
+func NewFakeStore() *FakeStore {
+	return &FakeStore{values: make(map[string][]byte), fail: make(map[string]bool)}
+}
+
+func (s *FakeStore) Put(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.puts = append(s.puts, key)
+	if s.fail[key] {
+		return errBackend
+	}
+	s.values[key] = value
+	return nil
+}
+
+// CheckPuts asserts that the store has been asked to Put exactly keys, in
+// order, since the last call to CheckPuts
+func (s *FakeStore) CheckPuts(t *testing.T, keys ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if strings.Join(s.puts, " ") != strings.Join(keys, " ") {
+		t.Errorf("Expected Puts of %v, found %v", keys, s.puts)
+	}
+	s.puts = nil
+}
+
+// CheckStored asserts that the store binds key to exp, or nothing if exp is nil
+func (s *FakeStore) CheckStored(t *testing.T, key string, exp []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	val, ok := s.values[key]
+	if string(val) != string(exp) || ok != (exp != nil) {
+		t.Errorf("Expected the store to bind %q to %q, found %q", key, exp, val)
+	}
+}
+
+// CheckDirty asserts that lru holds exp dirty bindings
+func CheckDirty(t *testing.T, lru *LRU, exp int) {
+	if dirty := lru.Dirty(); dirty != exp {
+		t.Errorf(operationFailMessage, "Dirty", &Args{}, Expected{exp}, Expected{dirty})
+	}
+}
+
+func TestStoreWriteThrough(t *testing.T) {
+	// desc := "Check that write-through writes every binding to the store as it is set"
+	limit := 8
+	lru := NewLru(limit)
+	store := NewFakeStore()
+	lru.SetStore(store, WriteThrough)
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k1", b("w1"), true),
+	})
+	store.CheckPuts(t, "k1", "k2", "k1")
+	store.CheckStored(t, "k1", b("w1"))
+	CheckDirty(t, lru, 0)
+
+	// Bindings are already written, so evicting, removing or flushing them
+	// writes nothing
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k3", b("v3"), true), // evicts k2
+		NewOp(Remove, "k1", &Record{b("w1"), true}),
+	})
+	if err := lru.Flush(); err != nil {
+		t.Errorf("Flush: %v", err)
+	}
+	store.CheckPuts(t, "k3")
+	store.CheckStored(t, "k2", b("v2"))
+}
+
+func TestStoreWriteThroughErrors(t *testing.T) {
+	// desc := "Check that a binding the store fails to write is not set"
+	lru := NewLru(1024)
+	store := NewFakeStore()
+	lru.SetStore(store, WriteThrough)
+	ExecuteOperations(t, lru, []Operation{NewOp(Set, "k1", b("v1"), true)})
+
+	store.fail["k1"] = true
+	store.fail["k2"] = true
+	if err := lru.SetE("k1", b("w1")); !errors.Is(err, errBackend) {
+		t.Errorf(operationFailMessage, "SetE", "\"k1\", \"w1\"", Expected{errBackend}, Expected{err})
+	}
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k2", b("v2"), false),
+		NewOp(Peek, "k1", &Record{b("v1"), true}),
+		NewOp(Peek, "k2", &Record{nil, false}),
+		NewOp(Len, 1),
+	})
+	store.CheckPuts(t, "k1", "k1", "k2")
+	store.CheckStored(t, "k1", b("v1"))
+}
+
+func TestStoreWriteBackDirty(t *testing.T) {
+	// desc := "Check that write-back only writes dirty bindings, when they leave the LRU"
+	limit := 12 // room for 3 bindings
+	lru := NewLru(limit)
+	store := NewFakeStore()
+	lru.SetStore(store, WriteBack)
+	lru.SetLoader(NewFakeLoader(map[string][]byte{"k9": b("v9")}))
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k1", b("w1"), true),
+	})
+	store.CheckPuts(t)
+	CheckDirty(t, lru, 2)
+
+	// A loaded binding is already in the store, so is not dirty
+	CheckFetch(t, lru, "k9", b("v9"), nil)
+	CheckDirty(t, lru, 2)
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k3", b("v3"), true), // evicts k2
+		NewOp(Remove, "k1", &Record{b("w1"), true}),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Set, "k5", b("v5"), true), // evicts k9, which is clean
+	})
+	store.CheckPuts(t, "k2", "k1")
+	store.CheckStored(t, "k1", b("w1"))
+	store.CheckStored(t, "k9", nil)
+	CheckDirty(t, lru, 3)
+}
+
+func TestStoreFlushOrder(t *testing.T) {
+	// desc := "Check that Flush writes dirty bindings from least to most recently used"
+	lru := NewLru(1024)
+	store := NewFakeStore()
+	lru.SetStore(store, WriteBack)
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+	})
+	if err := lru.Flush(); err != nil {
+		t.Errorf("Flush: %v", err)
+	}
+	store.CheckPuts(t, "k2", "k3", "k1")
+	CheckDirty(t, lru, 0)
+
+	// Flushing clean bindings writes nothing
+	if err := lru.Flush(); err != nil {
+		t.Errorf("Flush: %v", err)
+	}
+	store.CheckPuts(t)
+
+	// Flush stops at the first failure, leaving it and later bindings dirty
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k2", b("w2"), true),
+		NewOp(Set, "k3", b("w3"), true),
+		NewOp(Set, "k1", b("w1"), true),
+	})
+	store.fail["k3"] = true
+	if err := lru.Flush(); !errors.Is(err, errBackend) {
+		t.Errorf(operationFailMessage, "Flush", &Args{}, Expected{errBackend}, Expected{err})
+	}
+	store.CheckPuts(t, "k2", "k3")
+	CheckDirty(t, lru, 2)
+
+	store.fail["k3"] = false
+	if err := lru.Flush(); err != nil {
+		t.Errorf("Flush: %v", err)
+	}
+	store.CheckPuts(t, "k3", "k1")
+	store.CheckStored(t, "k3", b("w3"))
+}
+
+func TestStoreWriteBackErrors(t *testing.T) {
+	// desc := "Check that failures writing back evicted bindings are reported by Flush"
+	limit := 8
+	lru := NewLru(limit)
+	store := NewFakeStore()
+	lru.SetStore(store, WriteBack)
+	store.fail["k1"] = true
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true), // evicts k1, which fails
+	})
+	store.CheckPuts(t, "k1")
+	if err := lru.Flush(); !errors.Is(err, errBackend) {
+		t.Errorf(operationFailMessage, "Flush", &Args{}, Expected{errBackend}, Expected{err})
+	}
+	store.CheckPuts(t, "k2", "k3")
+
+	// The failure is only reported once
+	if err := lru.Flush(); err != nil {
+		t.Errorf("Flush: %v", err)
+	}
+}
+
+func TestStoreSwitch(t *testing.T) {
+	// desc := "Check that replacing the store flushes dirty bindings to the old one"
+	lru := NewLru(1024)
+	old, store := NewFakeStore(), NewFakeStore()
+	lru.SetStore(old, WriteBack)
+
+	ExecuteOperations(t, lru, []Operation{NewOp(Set, "k1", b("v1"), true)})
+	if err := lru.SetStore(store, WriteBack); err != nil {
+		t.Errorf("SetStore: %v", err)
+	}
+	old.CheckPuts(t, "k1")
+	CheckDirty(t, lru, 0)
+
+	ExecuteOperations(t, lru, []Operation{NewOp(Set, "k2", b("v2"), true)})
+	if err := lru.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	old.CheckPuts(t)
+	store.CheckPuts(t, "k2")
+}
+
+func TestStoreWriteBackLosses(t *testing.T) {
+	// desc := "Check that write-back never holds more unwritten data than fits in the LRU"
+	limit := 64
+	lru := NewLru(limit)
+	store := NewFakeStore()
+	lru.SetStore(store, WriteBack)
+	rng := rand.New(rand.NewSource(316))
+
+	// latest is the last value each key was bound to
+	latest := make(map[string][]byte)
+	for i := 0; i < 2000; i++ {
+		key := fmt.Sprintf("k%d", rng.Intn(32))
+		switch r := rng.Intn(10); {
+		case r < 6:
+			val := b(strings.Repeat("v", 1+rng.Intn(12)))
+			if lru.Set(key, val) {
+				latest[key] = val
+			}
+		case r < 9:
+			lru.Get(key)
+		default:
+			lru.Remove(key)
+		}
+
+		// Everything the store is missing is dirty in the LRU, so if the
+		// process died now, what it lost would fit in the LRU
+		lost := 0
+		for key, val := range latest {
+			if stored := store.values[key]; string(stored) == string(val) {
+				continue
+			}
+			if got, ok := lru.Peek(key); !ok || string(got) != string(val) {
+				t.Fatalf("Op %d: %q = %q is neither stored nor in the LRU", i, key, val)
+			}
+			lost += len(key) + len(val)
+		}
+		if used := limit - lru.RemainingStorage(); lost > used {
+			t.Fatalf("Op %d: %d bytes unwritten, but the LRU only holds %d", i, lost, used)
+		}
+	}
+
+	if err := lru.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	for key, val := range latest {
+		store.CheckStored(t, key, val)
+	}
+}
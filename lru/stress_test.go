@@ -0,0 +1,127 @@
+//go:build stress
+
+package lru
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"testing"
+	"time"
+)
+
+/******************************************************************************
+ *                             Soak tests
+ ******************************************************************************/
+// These tests only build with the "stress" tag, and are meant for final
+// grading passes rather than day-to-day development:
+//
+//	go test -tags stress -run Soak -timeout 2h -lru.soak 1h
+//
+// -lru.capacity sizes the LRU TestSoak churns, which must leave room for a
+// binding of 64 bytes and its key.
+
+var soakDuration = flag.Duration("lru.soak", time.Hour,
+	"how long TestSoak churns the LRU before finishing")
+
+// Number of operations between invariant and memory checkpoints
+const soakCheckpoint = 100000
+
+// Heap growth tolerated over the baseline taken after the LRU first fills
+const soakHeapSlack = 16 << 20
+
+func heapInUse() uint64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// TestSoak continuously inserts, reads, and removes bindings for the duration
+// given by -lru.soak, asserting that the LRU's accounting stays consistent and
+// that its memory use stays bounded by its capacity rather than growing with
+// the number of operations performed.
+func TestSoak(t *testing.T) {
+	// desc := "Churn an LRU for a long time, checking invariants and memory"
+	limit := capacityOr(1 << 20)
+	lru := NewLru(limit)
+
+	var op Operation // for printing errors in event of panic
+	defer CatchPanic(t, op)
+
+	rng := rand.New(rand.NewSource(316))
+	nkeys := 1 << 16
+
+	var baseline uint64
+	start := time.Now()
+
+	for i := 0; time.Since(start) < *soakDuration; i++ {
+		key := fmt.Sprintf("soak-%d", rng.Intn(nkeys))
+		val := make([]byte, 1+rng.Intn(64))
+		rng.Read(val)
+
+		switch r := rng.Intn(10); {
+		case r < 5:
+			// A binding that was just Set must be the most recently used,
+			// so it can never be evicted before the Get that follows it.
+			if !lru.Set(key, val) {
+				t.Fatalf(operationFailMessage, Set, &Args{[]interface{}{key, val}},
+					Expected{true}, Expected{false})
+			}
+			got, ok := lru.Get(key)
+			exp := &Record{val, true}
+			if rec := (&Record{got, ok}); !exp.Equals(rec) {
+				t.Fatalf(operationFailMessage, Get, &Args{[]interface{}{key}},
+					Expected{exp}, Expected{rec})
+			}
+		case r < 8:
+			lru.Get(key)
+		default:
+			lru.Remove(key)
+			if got, ok := lru.Get(key); ok {
+				t.Fatalf(operationFailMessage, Get, &Args{[]interface{}{key}},
+					Expected{&Record{nil, false}}, Expected{&Record{got, ok}})
+			}
+		}
+
+		if i%soakCheckpoint != 0 {
+			continue
+		}
+
+		checkSoakInvariants(t, lru, limit)
+
+		// Take the memory baseline once the LRU has had a chance to fill up,
+		// and require every later checkpoint to stay near it.
+		heap := heapInUse()
+		switch {
+		case i == 10*soakCheckpoint:
+			baseline = heap
+		case baseline != 0 && heap > baseline+soakHeapSlack:
+			t.Fatalf("Heap grew from %d to %d bytes after %d operations; "+
+				"is memory released when bindings are evicted or removed?",
+				baseline, heap, i)
+		}
+	}
+
+	checkSoakInvariants(t, lru, limit)
+}
+
+// checkSoakInvariants asserts properties that must hold for any LRU no matter
+// which bindings it happens to contain.
+func checkSoakInvariants(t *testing.T, lru *LRU, limit int) {
+	max := lru.MaxStorage()
+	rem := lru.RemainingStorage()
+	len := lru.Len()
+
+	switch {
+	case max != limit:
+		t.Fatalf(operationFailMessage, Max, &Args{}, Expected{limit}, Expected{max})
+	case rem < 0 || rem > limit:
+		t.Fatalf("RemainingStorage() returned %d, outside of [0, %d]", rem, limit)
+	case len < 0:
+		t.Fatalf("Len() returned negative length %d", len)
+	case (len == 0) != (rem == limit):
+		t.Fatalf("Len() is %d but RemainingStorage() is %d of %d", len, rem, limit)
+	}
+}
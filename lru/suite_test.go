@@ -0,0 +1,364 @@
+package lru
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Scenario suite tests
+ ******************************************************************************/
+// TestSuites runs every suite of scenarios defined in testdata, so that
+// scenarios can be added without writing Go. Suites are written in JSON
+// (*.json), or in YAML (*.yaml), which is only read when building with the
+// "yaml" tag, since it needs gopkg.in/yaml.v3 (see suite_yaml_test.go):
+//
+//	go test -tags yaml -run Suites
+//
+// A suite names a list of scenarios, each of which builds a cache with a
+// registered constructor (by default, NewLru) and runs a list of operations
+// on it. An operation is a list of the method, its arguments, and the
+// expected result, where a Get or Remove that should miss expects null:
+//
+//	name: basics
+//	scenarios:
+//	  - name: evicts the least recently used
+//	    description: Check that an LRU evicts by recency
+//	    constructor: NewLru
+//	    capacity: 12
+//	    weight: 2
+//	    hints: [Does Get move a binding to the front?]
+//	    ops:
+//	      - [Set, k1, v1, true]
+//	      - [Get, k2, null]
+//	      - [Len, 1]
+//
+// A failing scenario prints its description and hints. Each suite logs the
+// total weight of the scenarios that passed, out of the weight of all that
+// ran; a scenario's weight defaults to 1. When -lru.constructor is given, only
+// the scenarios targeting that constructor run.
+//
+// Before grading, check every suite with -lru.dry-run, which runs no student
+// code. It checks each operation's arity and types and each constructor's
+// name, and checks the expected results of scenarios on LRU constructors
+// against the reference Model:
+//
+//	go test -tags yaml -run Suites -lru.dry-run
+
+var dryRun = flag.Bool("lru.dry-run", false,
+	"validate the suites in testdata against the reference model instead of running them")
+
+// suiteDecoders decode suite files, by extension
+var suiteDecoders = map[string]func(data []byte, v interface{}) error{
+	".json": json.Unmarshal,
+}
+
+// modelConstructors are the constructors whose caches must behave exactly like
+// the reference Model
+var modelConstructors = map[string]bool{"NewLru": true, "NewByteCache": true, "NewSyncLru": true}
+
+// modelCache makes the reference Model a ByteCache
+type modelCache struct {
+	*Model
+}
+
+func (m modelCache) Set(key string, value []byte) bool {
+	ok, _ := m.Model.Set(key, value)
+	return ok
+}
+
+// Suite is a named list of scenarios, read from a JSON or YAML file
+type Suite struct {
+	Name      string      `json:"name" yaml:"name"`
+	Scenarios []SuiteCase `json:"scenarios" yaml:"scenarios"`
+}
+
+// SuiteCase is one scenario of a Suite
+type SuiteCase struct {
+	Name        string          `json:"name" yaml:"name"`
+	Description string          `json:"description" yaml:"description"`
+	Constructor string          `json:"constructor" yaml:"constructor"`
+	Capacity    int             `json:"capacity" yaml:"capacity"`
+	Weight      int             `json:"weight" yaml:"weight"`
+	Hints       []string        `json:"hints" yaml:"hints"`
+	Ops         [][]interface{} `json:"ops" yaml:"ops"`
+}
+
+// ReadSuite reads a suite from the file at path, decoded according to its
+// extension, checking that every scenario targets a registered constructor
+// and that every operation is one a ByteCache supports
+func ReadSuite(path string) (Suite, error) {
+	decode, ok := suiteDecoders[filepath.Ext(path)]
+	if !ok {
+		return Suite{}, fmt.Errorf("%s: no decoder for %s files", path, filepath.Ext(path))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Suite{}, err
+	}
+
+	var suite Suite
+	if err := decode(data, &suite); err != nil {
+		return Suite{}, fmt.Errorf("%s: %v", path, err)
+	}
+	if suite.Name == "" {
+		suite.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	for i := range suite.Scenarios {
+		c := &suite.Scenarios[i]
+		if c.Constructor == "" {
+			c.Constructor = "NewLru"
+		}
+		if c.Weight == 0 {
+			c.Weight = 1
+		}
+		if _, err := LookupConstructor(c.Constructor); err != nil {
+			return Suite{}, fmt.Errorf("%s: scenario %q: %v", path, c.Name, err)
+		}
+		if _, err := c.Operations(); err != nil {
+			return Suite{}, fmt.Errorf("%s: scenario %q: %v", path, c.Name, err)
+		}
+	}
+	return suite, nil
+}
+
+// Operations converts the scenario's operations into Operations for
+// ExecuteCacheOperations
+func (c SuiteCase) Operations() ([]Operation, error) {
+	ops := make([]Operation, len(c.Ops))
+	for i, row := range c.Ops {
+		op, err := suiteOperation(row)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d %v: %v", i+1, row, err)
+		}
+		ops[i] = op
+	}
+	return ops, nil
+}
+
+// suiteOperation converts one row of a scenario's operations, checking the
+// types of its arguments and expected result before NewOp sees them
+func suiteOperation(row []interface{}) (Operation, error) {
+	if len(row) == 0 {
+		return Operation{}, fmt.Errorf("empty operation")
+	}
+	method, ok := row[0].(string)
+	if !ok {
+		return Operation{}, fmt.Errorf("method must be a string")
+	}
+
+	var expected []interface{}
+	switch method {
+	case Get, Remove:
+		if len(row) != 3 {
+			return Operation{}, fmt.Errorf("expected [%s, key, value or null]", method)
+		}
+		key, ok := row[1].(string)
+		if !ok {
+			return Operation{}, fmt.Errorf("key must be a string")
+		}
+		rec := &Record{nil, false}
+		if row[2] != nil {
+			val, ok := row[2].(string)
+			if !ok {
+				return Operation{}, fmt.Errorf("value must be a string or null")
+			}
+			rec = &Record{b(val), true}
+		}
+		expected = []interface{}{key, rec}
+
+	case Set:
+		if len(row) != 4 {
+			return Operation{}, fmt.Errorf("expected [Set, key, value, true or false]")
+		}
+		key, ok1 := row[1].(string)
+		val, ok2 := row[2].(string)
+		stored, ok3 := row[3].(bool)
+		if !ok1 || !ok2 || !ok3 {
+			return Operation{}, fmt.Errorf("expected [Set, key, value, true or false]")
+		}
+		expected = []interface{}{key, b(val), stored}
+
+	case Max, Remaining, Len:
+		if len(row) != 2 {
+			return Operation{}, fmt.Errorf("expected [%s, number]", method)
+		}
+		n, ok := suiteInt(row[1])
+		if !ok {
+			return Operation{}, fmt.Errorf("expected [%s, number]", method)
+		}
+		expected = []interface{}{n}
+
+	default:
+		return Operation{}, fmt.Errorf("%s is not supported by a ByteCache", method)
+	}
+	return NewOp(method, expected...), nil
+}
+
+// suiteInt converts a number decoded from a suite to an int: YAML decodes
+// integers as ints, and JSON decodes every number as a float64
+func suiteInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case float64:
+		return int(n), n == float64(int(n))
+	}
+	return 0, false
+}
+
+// RunSuite runs every scenario of suite as a subtest, and returns the weight
+// of the scenarios that passed and of all that ran
+func RunSuite(t *testing.T, suite Suite) (passed, total int) {
+	for _, c := range suite.Scenarios {
+		if *constructor != "" && c.Constructor != *constructor {
+			continue
+		}
+		factory, _ := LookupConstructor(c.Constructor)
+		ops, _ := c.Operations()
+
+		total += c.Weight
+		ok := t.Run(c.Name, func(t *testing.T) {
+			ExecuteCacheOperations(t, factory(c.Capacity), ops)
+			if t.Failed() {
+				t.Logf("%s, on %s(%d)", c.Description, c.Constructor, c.Capacity)
+				for _, hint := range c.Hints {
+					t.Logf("Hint: %s", hint)
+				}
+			}
+		})
+		if ok {
+			passed += c.Weight
+		}
+	}
+	return passed, total
+}
+
+// DryRunSuite checks every scenario of suite as a subtest, without running
+// student code: scenarios on the constructors in modelConstructors run on the
+// reference Model, and the rest were checked as far as possible by ReadSuite
+func DryRunSuite(t *testing.T, suite Suite) {
+	for _, c := range suite.Scenarios {
+		if !modelConstructors[c.Constructor] {
+			t.Logf("%s: %s caches are not modeled; checked operations only", c.Name, c.Constructor)
+			continue
+		}
+		t.Run(c.Name, func(t *testing.T) {
+			DryRunScenario(t, c)
+		})
+	}
+}
+
+// DryRunScenario runs the operations of c, a scenario on one of the
+// modelConstructors, on the reference Model, failing the test on every result
+// that the scenario expects wrongly
+func DryRunScenario(t testing.TB, c SuiteCase) {
+	ops, _ := c.Operations()
+	model := modelCache{NewModel(c.Capacity)}
+	for _, op := range ops {
+		ExecuteCacheOperation(t, model, op)
+	}
+}
+
+// suitePaths returns the suite files in testdata, sorted, and the extensions
+// of any that cannot be decoded in this build
+func suitePaths() (paths []string, skipped map[string]bool, err error) {
+	skipped = make(map[string]bool)
+	for _, ext := range []string{".json", ".yaml", ".yml"} {
+		matches, err := filepath.Glob(filepath.Join("testdata", "*"+ext))
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := suiteDecoders[ext]; !ok {
+			if len(matches) > 0 {
+				skipped[ext] = true
+			}
+			continue
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+	return paths, skipped, nil
+}
+
+func TestSuites(t *testing.T) {
+	// desc := "Run every scenario suite in testdata"
+	paths, skipped, err := suitePaths()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ext := range skipped {
+		t.Logf("Skipping the %s suites in testdata; build with -tags yaml to run them", ext)
+	}
+	if len(paths) == 0 {
+		t.Skip("no suites in testdata")
+	}
+
+	for _, path := range paths {
+		suite, err := ReadSuite(path)
+		if err != nil {
+			t.Errorf("%v", err)
+			continue
+		}
+		t.Run(suite.Name, func(t *testing.T) {
+			if *dryRun {
+				DryRunSuite(t, suite)
+				return
+			}
+			passed, total := RunSuite(t, suite)
+			t.Logf("%s: %d of %d points", suite.Name, passed, total)
+		})
+	}
+}
+
+func TestReadSuiteErrors(t *testing.T) {
+	// desc := "Check that malformed suites are rejected with the file and scenario named"
+	bad := map[string]string{
+		"constructor": `{"scenarios": [{"name": "a", "constructor": "NewLruN", "ops": []}]}`,
+		"method":      `{"scenarios": [{"name": "a", "ops": [["Peek", "k1", null]]}]}`,
+		"arguments":   `{"scenarios": [{"name": "a", "ops": [["Set", "k1", true]]}]}`,
+		"expected":    `{"scenarios": [{"name": "a", "ops": [["Len", "two"]]}]}`,
+		"number":      `{"scenarios": [{"name": "a", "ops": [["Len", 1.5]]}]}`,
+		"syntax":      `{"scenarios": [`,
+	}
+	dir := t.TempDir()
+	for name, input := range bad {
+		path := filepath.Join(dir, name+".json")
+		if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ReadSuite(path)
+		if err == nil {
+			t.Errorf("Expected an error reading a suite with a bad %s", name)
+		} else if !strings.Contains(err.Error(), path) {
+			t.Errorf("Expected the error reading a suite with a bad %s to name %s, found %q",
+				name, path, err)
+		}
+	}
+}
+
+func TestDryRunSuite(t *testing.T) {
+	// desc := "Check that a dry run checks expected results against the model"
+	scenario := SuiteCase{
+		Name:        "wrong",
+		Constructor: "NewLru",
+		Capacity:    8,
+		Ops:         [][]interface{}{{Set, "k1", "v1", true}, {Len, 2.0}},
+	}
+
+	// The dry run must report the wrong expectation, and pass once it is fixed
+	rec := &recordingTB{TB: t}
+	DryRunScenario(rec, scenario)
+	if report := rec.out.String(); !rec.failed || !strings.Contains(report, "lru.Len()") {
+		t.Errorf("Expected the dry run to report the scenario's wrong Len(), found %q", report)
+	}
+
+	scenario.Ops[1] = []interface{}{Len, 1.0}
+	DryRunSuite(t, Suite{Name: "dry", Scenarios: []SuiteCase{scenario}})
+}
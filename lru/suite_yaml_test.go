@@ -0,0 +1,32 @@
+//go:build yaml
+
+package lru
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Suites may be written in YAML when building with the "yaml" tag
+func init() {
+	suiteDecoders[".yaml"] = yaml.Unmarshal
+	suiteDecoders[".yml"] = yaml.Unmarshal
+}
+
+func TestReadSuiteYAML(t *testing.T) {
+	// desc := "Check that a YAML suite reads like its JSON equivalent"
+	suite, err := ReadSuite("testdata/basics.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suite.Name != "basics" || len(suite.Scenarios) == 0 {
+		t.Fatalf("Expected the basics suite, found %q with %d scenarios", suite.Name, len(suite.Scenarios))
+	}
+	for _, c := range suite.Scenarios {
+		if c.Weight < 1 || c.Constructor == "" {
+			t.Errorf("%s: expected defaults for weight and constructor, found %d and %q",
+				c.Name, c.Weight, c.Constructor)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package lru
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Concurrent wrapper tests
+ ******************************************************************************/
+// The concurrent tests wrap caches that are not safe for concurrent use on
+// their own, so they are most useful run with the race detector:
+//
+//	go test -race -run 'Sync|Striped'
+
+var (
+	_ ByteCache = (*SyncCache)(nil)
+	_ ByteCache = (*StripedCache)(nil)
+)
+
+// newByteCache is NewByteCache as a factory for NewStripedLru
+func newByteCache(limit int) ByteCache {
+	return NewByteCache(limit)
+}
+
+// stripe returns the shard of n that holds key
+func stripe(key string, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+func TestSyncLruBasic(t *testing.T) {
+	// desc := "Check that a synchronized cache behaves exactly like the cache it wraps"
+	limit := 8
+	c := NewSyncLru(NewLru(limit))
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Max, limit),
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "k3", b("v3"), true), // evicts k2
+		NewOp(Get, "k2", &Record{nil, false}),
+		NewOp(Remove, "k1", &Record{b("v1"), true}),
+		NewOp(Remaining, 4),
+		NewOp(Len, 1),
+	})
+}
+
+func TestStripedLruSingleShard(t *testing.T) {
+	// desc := "Check that a striped cache with one shard behaves exactly like an LRU"
+	limit := 8
+	c := NewStripedLru(limit, 1, newByteCache)
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Max, limit),
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Get, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "k3", b("v3"), true), // evicts k2
+		NewOp(Get, "k2", &Record{nil, false}),
+		NewOp(Set, "toolarge", b("v"), false),
+		NewOp(Remaining, 0),
+		NewOp(Len, 2),
+	})
+}
+
+func TestStripedLruShards(t *testing.T) {
+	// desc := "Check that each shard evicts only its own least recently used bindings"
+	shards := 4
+	limit := shards * 16 // room for 4 bindings per shard
+	c := NewStripedLru(limit, shards, newByteCache)
+
+	var keys []string
+	for i := 0; i < 40; i++ {
+		key := fmt.Sprintf("k%02d", i)
+		keys = append(keys, key)
+		ExecuteCacheOperations(t, c, []Operation{NewOp(Set, key, b("v"), true)})
+	}
+
+	// The last 4 keys Set in each shard are the ones it kept
+	seen := make([]int, shards)
+	for i := len(keys) - 1; i >= 0; i-- {
+		s := stripe(keys[i], shards)
+		seen[s]++
+		exp := &Record{nil, false}
+		if seen[s] <= 4 {
+			exp = &Record{b("v"), true}
+		}
+		ExecuteCacheOperations(t, c, []Operation{NewOp(Get, keys[i], exp)})
+	}
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Max, limit),
+		NewOp(Len, 16),
+		NewOp(Remaining, 0),
+	})
+}
+
+func TestStripedLruUnevenShards(t *testing.T) {
+	// desc := "Check that storage left over after dividing the limit goes to the first shards"
+	limit := 10 // shards of 3, 3, 2 and 2 bytes
+	c := NewStripedLru(limit, 4, newByteCache)
+
+	var small, large string
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprint(i)
+		if s := stripe(key, 4); s < 2 {
+			large = key
+		} else {
+			small = key
+		}
+	}
+	if small == "" || large == "" {
+		t.Fatalf("Expected the digits to hash to every shard")
+	}
+
+	ExecuteCacheOperations(t, c, []Operation{
+		NewOp(Max, limit),
+		NewOp(Set, large, b("vv"), true),
+		NewOp(Set, small, b("vv"), false),
+		NewOp(Set, small, b("v"), true),
+		NewOp(Remaining, limit-5),
+	})
+}
+
+// hammer runs workers goroutines that each make ops random Sets, Gets and
+// Removes on c, then checks that c's accounting is still consistent
+func hammer(t *testing.T, c ByteCache, workers, ops int) {
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < ops; i++ {
+				key := fmt.Sprintf("k%03d", rng.Intn(256))
+				switch r := rng.Intn(10); {
+				case r < 3:
+					c.Set(key, []byte(key))
+				case r < 9:
+					if val, ok := c.Get(key); ok && string(val) != key {
+						t.Errorf("Get(%q) returned %q", key, val)
+					}
+				default:
+					c.Remove(key)
+				}
+				c.Len()
+				c.RemainingStorage()
+			}
+		}(int64(w))
+	}
+	wg.Wait()
+
+	max, rem, len := c.MaxStorage(), c.RemainingStorage(), c.Len()
+	if rem < 0 || rem > max || max-rem != 8*len {
+		t.Errorf("After concurrent use, MaxStorage() is %d, RemainingStorage() is %d "+
+			"and Len() is %d, but every binding uses 8 bytes", max, rem, len)
+	}
+}
+
+func TestSyncLruConcurrent(t *testing.T) {
+	// desc := "Check that a synchronized cache survives concurrent use"
+	hammer(t, NewSyncLru(NewByteCache(capacityOr(64*8))), 8, opsOr(2000))
+}
+
+func TestStripedLruConcurrent(t *testing.T) {
+	// desc := "Check that a striped cache survives concurrent use"
+	hammer(t, NewStripedLru(capacityOr(64*8), 8, newByteCache), 8, opsOr(2000))
+}
+
+// benchmarkReadHeavy fills c, then makes 9 Gets for every Set from as many
+// goroutines as GOMAXPROCS allows
+func benchmarkReadHeavy(b *testing.B, c ByteCache) {
+	N := 1 << 12
+	keys := make([]string, N)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%08x", i)
+		c.Set(keys[i], []byte(keys[i]))
+	}
+
+	seed := int64(0)
+	var mu sync.Mutex
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		mu.Lock()
+		seed++
+		rng := rand.New(rand.NewSource(seed))
+		mu.Unlock()
+
+		for i := 0; pb.Next(); i++ {
+			key := keys[rng.Intn(N)]
+			if i%10 == 0 {
+				c.Set(key, []byte(key))
+			} else {
+				c.Get(key)
+			}
+		}
+	})
+}
+
+func BenchmarkSyncLruReadHeavy(b *testing.B) {
+	benchmarkReadHeavy(b, NewSyncLru(NewByteCache(1<<12*16)))
+}
+
+func BenchmarkStripedLruReadHeavy(b *testing.B) {
+	benchmarkReadHeavy(b, NewStripedLru(1<<12*16, 16, newByteCache))
+}
@@ -0,0 +1,193 @@
+package lru
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+/******************************************************************************
+ *                             Tiered cache tests
+ ******************************************************************************/
+
+// CheckTierStats asserts that c's stats are exp
+func CheckTierStats(t *testing.T, c *TieredCache, exp TierStats) {
+	if stats := c.Stats(); stats != exp {
+		t.Errorf(operationFailMessage, "Stats", &Args{},
+			fmt.Sprintf("%+v", exp), fmt.Sprintf("%+v", stats))
+	}
+}
+
+// CheckOnDisk asserts that dir holds the file key with contents exp, or no
+// such file if exp is nil
+func CheckOnDisk(t *testing.T, dir, key string, exp []byte) {
+	val, err := os.ReadFile(filepath.Join(dir, key))
+	switch {
+	case exp == nil && !os.IsNotExist(err):
+		t.Errorf("Expected %q not to be on disk, found %q (%v)", key, val, err)
+	case exp != nil && (err != nil || string(val) != string(exp)):
+		t.Errorf("Expected %q on disk to be %q, found %q (%v)", key, exp, val, err)
+	}
+}
+
+// CheckTieredGet asserts that c.Get(key) returns exp
+func CheckTieredGet(t *testing.T, c *TieredCache, key string, exp *Record) {
+	val, ok := c.Get(key)
+	if got := (&Record{val, ok}); !got.Equals(exp) {
+		t.Errorf(operationFailMessage, "Get", fmt.Sprintf("\"%s\"", key), exp, got)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	store := FileStore{dir}
+
+	if err := store.Put("k1", b("v1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("k1", b("w1")); err != nil {
+		t.Fatal(err)
+	}
+	CheckOnDisk(t, dir, "k1", b("w1"))
+	if val, err := store.Load("k1"); err != nil || string(val) != "w1" {
+		t.Errorf(operationFailMessage, "Load", "\"k1\"", Expected{"w1"}, fmt.Sprintf("%q, %v", val, err))
+	}
+
+	if err := store.Delete("k1"); err != nil {
+		t.Error(err)
+	}
+	if err := store.Delete("k1"); err != nil {
+		t.Errorf("Expected deleting a missing key to succeed, found %v", err)
+	}
+	if _, err := store.Load("k1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf(operationFailMessage, "Load", "\"k1\"", Expected{ErrNotFound}, Expected{err})
+	}
+
+	if err := store.Put("../k1", b("v1")); err == nil {
+		t.Errorf("Expected an error storing \"../k1\"")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("Expected an empty directory, found %d files", len(entries))
+	}
+}
+
+func TestTieredDemotion(t *testing.T) {
+	// desc := "Check that bindings evicted from memory are demoted to disk"
+	limit := 8
+	dir := t.TempDir()
+	c := NewTieredCache(limit, FileStore{dir})
+
+	ExecuteOperations(t, c.Memory(), []Operation{NewOp(Max, limit)})
+	for _, key := range []string{"k1", "k2", "k3"} {
+		if !c.Set(key, b("v"+key[1:])) {
+			t.Errorf(operationFailMessage, "Set", fmt.Sprintf("\"%s\"", key),
+				Expected{true}, Expected{false})
+		}
+	}
+
+	// k1 was evicted from memory to disk
+	CheckOnDisk(t, dir, "k1", b("v1"))
+	CheckOnDisk(t, dir, "k2", nil)
+	ExecuteOperations(t, c.Memory(), []Operation{
+		NewOp(Peek, "k1", &Record{nil, false}),
+		NewOp(Len, 2),
+	})
+	CheckTierStats(t, c, TierStats{Demotions: 1, BytesDemoted: 4})
+}
+
+func TestTieredPromotion(t *testing.T) {
+	// desc := "Check that a disk hit promotes the binding back to memory"
+	limit := 8
+	dir := t.TempDir()
+	c := NewTieredCache(limit, FileStore{dir})
+	c.Set("k1", b("v1"))
+	c.Set("k2", b("v2"))
+	c.Set("k3", b("v3")) // demotes k1
+
+	CheckTieredGet(t, c, "k1", &Record{b("v1"), true}) // promotes k1, demotes k2
+	CheckOnDisk(t, dir, "k1", nil)
+	CheckOnDisk(t, dir, "k2", b("v2"))
+	CheckTieredGet(t, c, "k1", &Record{b("v1"), true})
+	CheckTieredGet(t, c, "k3", &Record{b("v3"), true})
+
+	CheckTierStats(t, c, TierStats{
+		MemoryHits: 2, DiskHits: 1,
+		Promotions: 1, Demotions: 2,
+		BytesPromoted: 4, BytesDemoted: 8,
+	})
+}
+
+func TestTieredLoader(t *testing.T) {
+	// desc := "Check that a miss checks disk before the loader"
+	limit := 8
+	dir := t.TempDir()
+	c := NewTieredCache(limit, FileStore{dir})
+	loader := NewFakeLoader(map[string][]byte{"k1": b("stale"), "k2": b("v2"), "k3": b("v3")})
+	c.SetLoader(loader)
+
+	c.Set("k1", b("v1"))
+	CheckTieredGet(t, c, "k2", &Record{b("v2"), true})
+	CheckTieredGet(t, c, "k3", &Record{b("v3"), true}) // demotes k1
+	CheckTieredGet(t, c, "k1", &Record{b("v1"), true}) // from disk, not the loader
+	CheckTieredGet(t, c, "k4", &Record{nil, false})
+
+	loader.CheckLoads(t, "k1", 0)
+	loader.CheckLoads(t, "k2", 1)
+	loader.CheckLoads(t, "k4", 1)
+	CheckTierStats(t, c, TierStats{
+		DiskHits: 1, Loads: 2, Misses: 1,
+		Promotions: 1, Demotions: 2,
+		BytesPromoted: 4, BytesDemoted: 8,
+	})
+}
+
+func TestTieredTooLarge(t *testing.T) {
+	// desc := "Check that a binding too large for memory is served from disk without promotion"
+	limit := 8
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "big"), b("toolarge"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := NewTieredCache(limit, FileStore{dir})
+	c.Set("k1", b("v1"))
+
+	CheckTieredGet(t, c, "big", &Record{b("toolarge"), true})
+	CheckOnDisk(t, dir, "big", b("toolarge"))
+	ExecuteOperations(t, c.Memory(), []Operation{
+		NewOp(Peek, "k1", &Record{b("v1"), true}),
+		NewOp(Len, 1),
+	})
+	CheckTierStats(t, c, TierStats{DiskHits: 1})
+}
+
+func TestTieredSetRemove(t *testing.T) {
+	// desc := "Check that Set and Remove leave no stale copy on disk"
+	limit := 8
+	dir := t.TempDir()
+	c := NewTieredCache(limit, FileStore{dir})
+	c.Set("k1", b("v1"))
+	c.Set("k2", b("v2"))
+	c.Set("k3", b("v3")) // demotes k1
+	c.Set("k4", b("v4")) // demotes k2
+
+	// Setting a demoted key replaces the copy on disk
+	c.Set("k1", b("w1")) // demotes k3
+	CheckOnDisk(t, dir, "k1", nil)
+	CheckTieredGet(t, c, "k1", &Record{b("w1"), true})
+
+	if val, ok := c.Remove("k2"); !ok || string(val) != "v2" {
+		t.Errorf(operationFailMessage, "Remove", "\"k2\"", &Record{b("v2"), true}, &Record{val, ok})
+	}
+	if val, ok := c.Remove("k1"); !ok || string(val) != "w1" {
+		t.Errorf(operationFailMessage, "Remove", "\"k1\"", &Record{b("w1"), true}, &Record{val, ok})
+	}
+	if _, ok := c.Remove("k1"); ok {
+		t.Errorf(operationFailMessage, "Remove", "\"k1\"", &Record{nil, false}, "cache hit")
+	}
+	CheckOnDisk(t, dir, "k2", nil)
+	CheckTieredGet(t, c, "k2", &Record{nil, false})
+	CheckTieredGet(t, c, "k3", &Record{b("v3"), true})
+}
@@ -0,0 +1,37 @@
+package lru
+
+// Admitter decides whether a new binding is worth the bindings an LRU would
+// have to evict to make room for it
+type Admitter interface {
+	// Record notes one use of key, whether or not it is bound
+	Record(key string)
+
+	// Admit reports whether candidate should displace victim
+	Admit(candidate, victim string) bool
+}
+
+// TinyLfu is an Admitter that estimates how often each key is used with a
+// count-min sketch, behind a doorkeeper bloom filter that absorbs the first
+// use of each key, and admits candidates used more often than their victims
+type TinyLfu struct {
+	// whatever fields you want here
+}
+
+// NewTinyLfu returns a TinyLfu whose sketch has rows of width counters
+func NewTinyLfu(width int) *TinyLfu {
+	return new(TinyLfu)
+}
+
+func (f *TinyLfu) Record(key string) {
+}
+
+func (f *TinyLfu) Estimate(key string) int {
+	return 0
+}
+
+func (f *TinyLfu) Admit(candidate, victim string) bool {
+	return false
+}
+
+func (lru *LRU) SetAdmitter(admitter Admitter) {
+}
@@ -0,0 +1,175 @@
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+/******************************************************************************
+ *                             TinyLFU tests
+ ******************************************************************************/
+
+// CheckEstimate asserts that f estimates key has been used exp times
+func CheckEstimate(t *testing.T, f *TinyLfu, key string, exp int) {
+	if got := f.Estimate(key); got != exp {
+		t.Errorf(operationFailMessage, "Estimate", fmt.Sprintf("\"%s\"", key),
+			Expected{exp}, Expected{got})
+	}
+}
+
+// CheckAdmit asserts whether f lets candidate displace victim
+func CheckAdmit(t *testing.T, f *TinyLfu, candidate, victim string, exp bool) {
+	if got := f.Admit(candidate, victim); got != exp {
+		t.Errorf(operationFailMessage, "Admit",
+			fmt.Sprintf("\"%s\", \"%s\"", candidate, victim), Expected{exp}, Expected{got})
+	}
+}
+
+func record(f *TinyLfu, key string, n int) {
+	for i := 0; i < n; i++ {
+		f.Record(key)
+	}
+}
+
+func TestTinyLfuEstimate(t *testing.T) {
+	// desc := "Check that the doorkeeper counts first uses and the sketch counts the rest"
+	f := NewTinyLfu(1024)
+
+	record(f, "a", 3)
+	record(f, "b", 1)
+	record(f, "c", 20)
+
+	CheckEstimate(t, f, "a", 3)
+	CheckEstimate(t, f, "b", 1)
+	CheckEstimate(t, f, "c", 16) // counters saturate at 15
+	CheckEstimate(t, f, "d", 0)
+}
+
+func TestTinyLfuReset(t *testing.T) {
+	// desc := "Check that the sketch halves its counters and clears the doorkeeper periodically"
+	width := 16
+	f := NewTinyLfu(width)
+
+	record(f, "a", 10*width-1)
+	CheckEstimate(t, f, "a", 16)
+
+	f.Record("a") // the reset comes after recording the last use of the period
+	CheckEstimate(t, f, "a", 7)
+
+	f.Record("a")
+	CheckEstimate(t, f, "a", 8)
+}
+
+func TestTinyLfuAdmit(t *testing.T) {
+	// desc := "Check that only candidates used strictly more often than their victims are admitted"
+	f := NewTinyLfu(1024)
+
+	record(f, "hot", 5)
+	record(f, "cold", 1)
+	record(f, "tepid", 1)
+
+	CheckAdmit(t, f, "hot", "cold", true)
+	CheckAdmit(t, f, "cold", "hot", false)
+	CheckAdmit(t, f, "cold", "tepid", false)
+	CheckAdmit(t, f, "unseen", "cold", false)
+}
+
+func TestAdmitterRejectsNewcomer(t *testing.T) {
+	// desc := "Check that a newcomer used less often than the LRU binding is turned away"
+	limit := 16 // room for 4 bindings
+	lru := NewLru(limit)
+	lru.SetAdmitter(NewTinyLfu(1024))
+
+	ops := []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+	}
+	for i := 0; i < 4; i++ {
+		ops = append(ops, NewOp(Get, "k1", &Record{b("v1"), true}))
+	}
+	ops = append(ops,
+		NewOp(Get, "k2", &Record{b("v2"), true}),
+		NewOp(Get, "k3", &Record{b("v3"), true}),
+		NewOp(Get, "k4", &Record{b("v4"), true}),
+
+		// k1 is least recently used, but also the most frequently used
+		NewOp(SetE, "k5", b("v5"), ErrRejected),
+		NewOp(Set, "k5", b("v5"), false),
+		NewOp(Peek, "k1", &Record{b("v1"), true}),
+		NewOp(Peek, "k5", &Record{nil, false}),
+		NewOp(Len, 4),
+	)
+	for i := 0; i < 5; i++ {
+		ops = append(ops, NewOp(Get, "k5", &Record{nil, false}))
+	}
+	ops = append(ops,
+		// Misses count as uses too, so k5 has now overtaken k1
+		NewOp(Set, "k5", b("v5"), true),
+		NewOp(Peek, "k1", &Record{nil, false}),
+		NewOp(Peek, "k5", &Record{b("v5"), true}),
+		NewOp(Len, 4),
+	)
+	ExecuteOperations(t, lru, ops)
+}
+
+func TestAdmitterEveryVictim(t *testing.T) {
+	// desc := "Check that a newcomer must be worth every binding it would evict"
+	limit := 16 // room for 4 bindings
+	lru := NewLru(limit)
+	lru.SetAdmitter(NewTinyLfu(1024))
+
+	ops := []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+	}
+	for i := 0; i < 4; i++ {
+		ops = append(ops, NewOp(Get, "k2", &Record{b("v2"), true}))
+	}
+	ops = append(ops,
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Get, "k5", &Record{nil, false}),
+		NewOp(Get, "k5", &Record{nil, false}),
+
+		// k5 is used more than k1 but less than k2, and would evict both
+		NewOp(Set, "k5", b("v5v5v5"), false),
+		NewOp(Peek, "k1", &Record{b("v1"), true}),
+		NewOp(Peek, "k2", &Record{b("v2"), true}),
+
+		// Ties favour the binding already in the LRU
+		NewOp(Set, "k6", b("v6"), false),
+		NewOp(Peek, "k1", &Record{b("v1"), true}),
+		NewOp(Len, 4),
+		NewOp(Remaining, 0),
+	)
+	ExecuteOperations(t, lru, ops)
+}
+
+func TestAdmitterBypassed(t *testing.T) {
+	// desc := "Check that bindings that evict nothing, and overwrites, are always stored"
+	limit := 16 // room for 4 bindings
+	lru := NewLru(limit)
+	lru.SetAdmitter(NewTinyLfu(1024))
+
+	ops := []Operation{NewOp(Set, "k1", b("v1"), true)}
+	for i := 0; i < 4; i++ {
+		ops = append(ops, NewOp(Get, "k1", &Record{b("v1"), true}))
+	}
+	ops = append(ops,
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Set, "k4", b("v4"), true),
+		NewOp(Set, "k4", b("v4v4"), true), // evicts k1
+		NewOp(Peek, "k1", &Record{nil, false}),
+	)
+	ExecuteOperations(t, lru, ops)
+
+	lru.SetAdmitter(nil)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k5", b("v5"), true), // evicts k2
+		NewOp(Peek, "k2", &Record{nil, false}),
+		NewOp(Peek, "k5", &Record{b("v5"), true}),
+	})
+}
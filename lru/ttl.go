@@ -0,0 +1,43 @@
+package lru
+
+import "time"
+
+// Clock reports the current time to an LRU, so that tests can control when
+// bindings expire
+type Clock interface {
+	Now() time.Time
+}
+
+func (lru *LRU) SetClock(clock Clock) {
+}
+
+func (lru *LRU) SetWithTTL(key string, value []byte, ttl time.Duration) bool {
+	return false
+}
+
+// Ticker delivers the ticks on which the janitor sweeps expired bindings, so
+// that tests can decide exactly when sweeps happen
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// NewTicker returns a Ticker that ticks every period, backed by a time.Ticker
+func NewTicker(period time.Duration) Ticker {
+	return timeTicker{time.NewTicker(period)}
+}
+
+type timeTicker struct {
+	ticker *time.Ticker
+}
+
+func (t timeTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t timeTicker) Stop() {
+	t.ticker.Stop()
+}
+
+func (lru *LRU) StartJanitor(ticker Ticker) {
+}
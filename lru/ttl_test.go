@@ -0,0 +1,293 @@
+package lru
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+/******************************************************************************
+ *                             Fake clock
+ ******************************************************************************/
+
+// FakeClock is a Clock that only moves when told to. It is safe for
+// concurrent use, since a janitor may read it while a test advances it.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFakeClock() *FakeClock {
+	return &FakeClock{now: time.Date(2020, time.February, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// NewLruWithClock returns a new LRU that tells the time with a fake clock
+func NewLruWithClock(limit int) (*LRU, *FakeClock) {
+	lru := NewLru(limit)
+	clock := NewFakeClock()
+	lru.SetClock(clock)
+	return lru, clock
+}
+
+// CheckSetWithTTL fails the test unless lru.SetWithTTL returns expected
+func CheckSetWithTTL(t *testing.T, lru *LRU, key string, val []byte, ttl time.Duration, expected bool) {
+	if got := lru.SetWithTTL(key, val, ttl); got != expected {
+		args := fmt.Sprintf("\"%s\",'%s',%v", key, val, ttl)
+		t.Errorf(operationFailMessage, "SetWithTTL", args, Expected{expected}, Expected{got})
+	}
+}
+
+/******************************************************************************
+ *                             TTL tests
+ ******************************************************************************/
+
+func TestTTLBasic(t *testing.T) {
+	// desc := "Check that bindings expire once their ttl has passed"
+	limit := 1024
+	lru, clock := NewLruWithClock(limit)
+
+	key := "key"
+	val := b("value")
+
+	CheckSetWithTTL(t, lru, key, val, 10*time.Second, true)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Get, key, &Record{val, true}),
+		NewOp(Len, 1),
+		NewOp(Remaining, limit-len(key)-len(val)),
+	})
+
+	clock.Advance(9 * time.Second)
+	ExecuteOperations(t, lru, []Operation{NewOp(Get, key, &Record{val, true})})
+
+	// Get must not have extended the ttl
+	clock.Advance(time.Second)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Get, key, &Record{nil, false}),
+		NewOp(Len, 0),
+		NewOp(Remaining, limit),
+	})
+}
+
+func TestTTLNeverExpires(t *testing.T) {
+	// desc := "Check that plain Sets and non-positive ttls never expire"
+	lru, clock := NewLruWithClock(1024)
+
+	ExecuteOperations(t, lru, []Operation{NewOp(Set, "set", b("forever"), true)})
+	CheckSetWithTTL(t, lru, "zero", b("forever"), 0, true)
+	CheckSetWithTTL(t, lru, "negative", b("forever"), -time.Second, true)
+
+	clock.Advance(24 * 365 * time.Hour)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Get, "set", &Record{b("forever"), true}),
+		NewOp(Get, "zero", &Record{b("forever"), true}),
+		NewOp(Get, "negative", &Record{b("forever"), true}),
+		NewOp(Len, 3),
+	})
+}
+
+func TestTTLOverwrite(t *testing.T) {
+	// desc := "Check that replacing a binding replaces its expiry"
+	lru, clock := NewLruWithClock(1024)
+
+	CheckSetWithTTL(t, lru, "key", b("v1"), 10*time.Second, true)
+	clock.Advance(5 * time.Second)
+	CheckSetWithTTL(t, lru, "key", b("v2"), 10*time.Second, true)
+
+	clock.Advance(6 * time.Second) // 11s after the first Set
+	ExecuteOperations(t, lru, []Operation{NewOp(Get, "key", &Record{b("v2"), true})})
+
+	// A plain Set clears the ttl altogether
+	ExecuteOperations(t, lru, []Operation{NewOp(Set, "key", b("v3"), true)})
+	clock.Advance(time.Hour)
+	ExecuteOperations(t, lru, []Operation{NewOp(Get, "key", &Record{b("v3"), true})})
+
+	// and a later SetWithTTL restores one
+	CheckSetWithTTL(t, lru, "key", b("v4"), time.Minute, true)
+	clock.Advance(time.Minute)
+	ExecuteOperations(t, lru, []Operation{NewOp(Get, "key", &Record{nil, false})})
+}
+
+func TestTTLExpiredAccessors(t *testing.T) {
+	// desc := "Check that every accessor treats expired bindings as missing"
+	limit := 1024
+	lru, clock := NewLruWithClock(limit)
+
+	for _, key := range []string{"get", "peek", "remove", "contains", "touch"} {
+		CheckSetWithTTL(t, lru, key, b("value"), time.Second, true)
+	}
+	clock.Advance(time.Second)
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Get, "get", &Record{nil, false}),
+		NewOp(Peek, "peek", &Record{nil, false}),
+		NewOp(Remove, "remove", &Record{nil, false}),
+	})
+	CheckContains(t, lru, "contains", false)
+	CheckTouch(t, lru, "touch", false)
+}
+
+func TestTTLReleasesStorage(t *testing.T) {
+	// desc := "Check that expired bindings release their storage"
+	limit := 40 // room for 10 bindings
+	lru, clock := NewLruWithClock(limit)
+
+	keys := make([]string, 10)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%02d", i)
+		CheckSetWithTTL(t, lru, keys[i], b(keys[i]), time.Duration(i+1)*time.Second, true)
+	}
+	ExecuteOperations(t, lru, []Operation{NewOp(Remaining, 0)})
+
+	// Half of the bindings have expired
+	clock.Advance(5 * time.Second)
+	ops := []Operation{}
+	for i, key := range keys {
+		if i < 5 {
+			ops = append(ops, NewOp(Get, key, &Record{nil, false}))
+		} else {
+			ops = append(ops, NewOp(Get, key, &Record{b(key), true}))
+		}
+	}
+	ops = append(ops,
+		NewOp(Len, 5),
+		NewOp(Remaining, limit/2),
+	)
+	ExecuteOperations(t, lru, ops)
+
+	// The freed space is usable without evicting live bindings
+	ops = []Operation{}
+	for i := 10; i < 15; i++ {
+		key := fmt.Sprintf("%02d", i)
+		ops = append(ops, NewOp(Set, key, b(key), true))
+	}
+	ops = append(ops,
+		NewOp(Len, 10),
+		NewOp(Remaining, 0),
+	)
+	for _, key := range keys[5:] {
+		ops = append(ops, NewOp(Get, key, &Record{b(key), true}))
+	}
+	ExecuteOperations(t, lru, ops)
+}
+
+/******************************************************************************
+ *                             Janitor tests
+ ******************************************************************************/
+
+// How long to wait for the janitor before deciding it is stuck or gone
+const janitorTimeout = time.Second
+
+// FakeTicker is a Ticker that only ticks when told to
+type FakeTicker struct {
+	c       chan time.Time
+	stopped chan struct{}
+	once    sync.Once
+}
+
+func NewFakeTicker() *FakeTicker {
+	return &FakeTicker{c: make(chan time.Time), stopped: make(chan struct{})}
+}
+
+func (f *FakeTicker) C() <-chan time.Time {
+	return f.c
+}
+
+func (f *FakeTicker) Stop() {
+	f.once.Do(func() { close(f.stopped) })
+}
+
+// Tick delivers a tick and waits until the janitor has finished sweeping.
+// The channel is unbuffered, so once a second tick has been received the
+// janitor must be done handling the first one. The sweep for the second tick
+// may still be running when Tick returns, but it sweeps at the same time as
+// the first, so it finds nothing left to remove unless the test advances the
+// clock, which FakeClock makes safe.
+func (f *FakeTicker) Tick(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		select {
+		case f.c <- time.Time{}:
+		case <-time.After(janitorTimeout):
+			t.Fatalf("Janitor did not receive a tick within %v", janitorTimeout)
+		}
+	}
+}
+
+func TestJanitorReclaimsStorage(t *testing.T) {
+	// desc := "Check that the janitor removes expired bindings without any Gets"
+	limit := 40 // room for 10 bindings
+	lru, clock := NewLruWithClock(limit)
+	ticker := NewFakeTicker()
+	lru.StartJanitor(ticker)
+	defer lru.Close()
+
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("%02d", i)
+		if i%2 == 0 {
+			CheckSetWithTTL(t, lru, key, b(key), time.Duration(i+1)*time.Second, true)
+		} else {
+			ExecuteOperations(t, lru, []Operation{NewOp(Set, key, b(key), true)})
+		}
+	}
+
+	// Nothing has expired yet
+	ticker.Tick(t)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Len, 10),
+		NewOp(Remaining, 0),
+	})
+
+	// Bindings 00 and 02 have expired
+	clock.Advance(3 * time.Second)
+	ticker.Tick(t)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Len, 8),
+		NewOp(Remaining, 8),
+	})
+
+	// All bindings with a ttl have expired, only plain Sets are left
+	clock.Advance(time.Hour)
+	ticker.Tick(t)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Len, 5),
+		NewOp(Remaining, limit/2),
+		NewOp(Get, "01", &Record{b("01"), true}),
+	})
+}
+
+func TestJanitorStopsOnClose(t *testing.T) {
+	// desc := "Check that Close stops the janitor goroutine and its ticker"
+	lru, clock := NewLruWithClock(1024)
+	ticker := NewFakeTicker()
+	lru.StartJanitor(ticker)
+
+	CheckSetWithTTL(t, lru, "key", b("value"), time.Second, true)
+	ticker.Tick(t)
+
+	lru.Close()
+	select {
+	case <-ticker.stopped:
+	case <-time.After(janitorTimeout):
+		t.Fatalf("Close did not stop the janitor's ticker")
+	}
+
+	// No janitor is left to receive ticks or sweep expired bindings
+	clock.Advance(time.Second)
+	select {
+	case ticker.c <- time.Time{}:
+		t.Errorf("Janitor received a tick after Close")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,168 @@
+package lru
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+/******************************************************************************
+ *                             Write-ahead log tests
+ ******************************************************************************/
+
+// CheckRecover asserts that recovering lru from log replays exp records and
+// returns an error matching expErr, which may be nil
+func CheckRecover(t *testing.T, lru *LRU, log []byte, exp int, expErr error) {
+	n, err := lru.Recover(bytes.NewReader(log))
+	if n != exp || !errors.Is(err, expErr) || (err == nil) != (expErr == nil) {
+		t.Errorf(operationFailMessage, "Recover", fmt.Sprintf("%d bytes", len(log)),
+			fmt.Sprintf("%d, %v", exp, Expected{expErr}), fmt.Sprintf("%d, %v", n, Expected{err}))
+	}
+}
+
+func TestLogRecover(t *testing.T) {
+	// desc := "Check that recovering from a log rebuilds the bindings it recorded"
+	var log bytes.Buffer
+	lru := NewLru(1024)
+	lru.SetLog(&log)
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "k3", b("v3"), true),
+		NewOp(Remove, "k1", &Record{b("v1"), true}),
+		NewOp(Set, "k2", b("w2"), true),
+		NewOp(Get, "k3", &Record{b("v3"), true}), // not logged
+		NewOp(Remove, "k9", &Record{nil, false}), // not logged
+	})
+	lru.Append("k3", b("3"))
+
+	recovered := NewLru(1024)
+	CheckRecover(t, recovered, log.Bytes(), 6, nil)
+	CheckKeys(t, recovered, []string{"k2", "k3"})
+	ExecuteOperations(t, recovered, []Operation{
+		NewOp(Peek, "k2", &Record{b("w2"), true}),
+		NewOp(Peek, "k3", &Record{b("v33"), true}),
+	})
+
+	// Recovering writes nothing to the log, and logging can carry on after
+	recovered.SetLog(&log)
+	CheckRecover(t, NewLru(1024), log.Bytes(), 6, nil)
+	recovered.Set("k4", b("v4"))
+	recovered.Purge()
+	recovered.Set("k5", b("v5"))
+
+	again := NewLru(1024)
+	CheckRecover(t, again, log.Bytes(), 11, nil)
+	CheckKeys(t, again, []string{"k5"})
+}
+
+func TestLogBounded(t *testing.T) {
+	// desc := "Check that recovery keeps within capacity, as the Sets it replays would"
+	var log bytes.Buffer
+	lru := NewLru(1024)
+	lru.SetLog(&log)
+	for i := 0; i < 10; i++ {
+		lru.Set(fmt.Sprintf("k%d", i), b("v"))
+	}
+	lru.Set("toolarge", make([]byte, 1024)) // fails, so is not logged
+
+	limit := 9 // room for 3 bindings
+	recovered := NewLru(limit)
+	CheckRecover(t, recovered, log.Bytes(), 10, nil)
+	CheckKeys(t, recovered, []string{"k7", "k8", "k9"})
+	ExecuteOperations(t, recovered, []Operation{NewOp(Remaining, 0)})
+}
+
+func TestLogTruncated(t *testing.T) {
+	// desc := "Check that a log cut off partway through a record recovers every whole record"
+	var log bytes.Buffer
+	lru := NewLru(1024)
+	lru.SetLog(&log)
+	clock := NewFakeClock()
+	lru.SetClock(clock)
+
+	// ends[i] is the length of the log after its first i records
+	ends := []int{0}
+	for _, op := range []func(){
+		func() { lru.Set("k1", b("v1")) },
+		func() { lru.SetWithTTL("k2", b("v2"), time.Hour) },
+		func() { lru.Remove("k1") },
+		func() { lru.Set("k3", b("")) },
+	} {
+		op()
+		ends = append(ends, log.Len())
+	}
+	expected := [][]string{{}, {"k1"}, {"k1", "k2"}, {"k2"}, {"k2", "k3"}}
+
+	whole := 0
+	for n := 0; n <= log.Len(); n++ {
+		if whole+1 < len(ends) && n == ends[whole+1] {
+			whole++
+		}
+		recovered := NewLru(1024)
+		recovered.SetClock(clock)
+		CheckRecover(t, recovered, log.Bytes()[:n], whole, nil)
+		CheckKeys(t, recovered, expected[whole])
+	}
+}
+
+func TestLogCorrupt(t *testing.T) {
+	// desc := "Check that recovery stops at a damaged record, reporting ErrCorrupt"
+	var log bytes.Buffer
+	lru := NewLru(1024)
+	lru.SetLog(&log)
+	lru.Set("k1", b("v1"))
+	mid := log.Len()
+	lru.Set("k2", b("v2"))
+	lru.Set("k3", b("v3"))
+	if log.Len() <= mid+5 {
+		t.Fatalf("Expected SetLog to record three Sets, found a log of %d bytes", log.Len())
+	}
+
+	damaged := append([]byte(nil), log.Bytes()...)
+	damaged[mid+5] ^= 0x10 // in k2's value
+	recovered := NewLru(1024)
+	CheckRecover(t, recovered, damaged, 1, ErrCorrupt)
+	CheckKeys(t, recovered, []string{"k1"})
+
+	damaged = append([]byte(nil), log.Bytes()...)
+	damaged[mid] = 'X' // not an operation
+	CheckRecover(t, NewLru(1024), damaged, 1, ErrCorrupt)
+}
+
+func TestLogFormat(t *testing.T) {
+	// desc := "Check that the log records exactly the documented format"
+	var log bytes.Buffer
+	lru := NewLru(1024)
+	lru.SetLog(&log)
+	lru.Set("k1", b("v1"))
+	lru.Remove("k1")
+
+	expected := "53" + "026b31" + "027631" + "00" + "eb4e8f11" + // Set "k1" = "v1", no expiry
+		"52" + "026b31" + "94137e0d" // Remove "k1"
+	if got := hex.EncodeToString(log.Bytes()); got != expected {
+		t.Errorf("Expected the log\n%s\nfound\n%s", expected, got)
+	}
+}
+
+func TestLogWriteErrors(t *testing.T) {
+	// desc := "Check that an operation the log fails to record does not happen"
+	lru := NewLru(1024)
+	lru.Set("k1", b("v1"))
+	errDisk := errors.New("disk on fire")
+	lru.SetLog(failingWriter{errDisk})
+
+	if err := lru.SetE("k2", b("v2")); !errors.Is(err, errDisk) {
+		t.Errorf(operationFailMessage, "SetE", "\"k2\", \"v2\"", Expected{errDisk}, Expected{err})
+	}
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", b("w1"), false),
+		NewOp(Remove, "k1", &Record{nil, false}),
+		NewOp(Peek, "k1", &Record{b("v1"), true}),
+		NewOp(Len, 1),
+	})
+}
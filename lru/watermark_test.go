@@ -0,0 +1,120 @@
+package lru
+
+import (
+	"fmt"
+	"testing"
+)
+
+/******************************************************************************
+ *                              Watermark tests
+ ******************************************************************************/
+
+// watermarkLru returns an LRU holding 100 bytes with the given watermarks,
+// and a pointer to the number of evictions it has made
+func watermarkLru(high, low float64) (*LRU, *int) {
+	lru := NewLruWithOptions(100, Options{HighWatermark: high, LowWatermark: low})
+	evictions := new(int)
+	lru.SetEvictedCallback(func(key string, val []byte) { *evictions++ })
+	return lru, evictions
+}
+
+// setTen binds the i-th of the keys "a0", "a1", ..., "b0", ... to an 8-byte
+// value, charging it 10 bytes
+func setTen(lru *LRU, i int) bool {
+	return lru.Set(fmt.Sprintf("%c%d", 'a'+i/10, i%10), b("12345678"))
+}
+
+func TestWatermarkHysteresis(t *testing.T) {
+	// desc := "Check that crossing the high watermark evicts down to the low one, then nothing until it is crossed again"
+	lru, evictions := watermarkLru(0.9, 0.6)
+
+	for i := 0; i < 9; i++ {
+		setTen(lru, i)
+	}
+	if *evictions != 0 || lru.RemainingStorage() != 10 {
+		t.Errorf("Expected no evictions up to the high watermark, found %d", *evictions)
+	}
+
+	setTen(lru, 9) // 100 bytes: evict down to 60
+	if *evictions != 4 || lru.RemainingStorage() != 40 {
+		t.Errorf("Expected a batch of 4 evictions, leaving 40 bytes, found %d leaving %d",
+			*evictions, lru.RemainingStorage())
+	}
+	CheckKeys(t, lru, []string{"a4", "a5", "a6", "a7", "a8", "a9"})
+
+	for i := 10; i < 13; i++ {
+		setTen(lru, i) // back up to 90 bytes, with nothing evicted
+	}
+	if *evictions != 4 {
+		t.Errorf("Expected no evictions between the watermarks, found %d", *evictions-4)
+	}
+	setTen(lru, 13)
+	if *evictions != 8 || lru.RemainingStorage() != 40 {
+		t.Errorf("Expected a second batch of 4 evictions, found %d", *evictions-4)
+	}
+	CheckKeys(t, lru, []string{"a8", "a9", "b0", "b1", "b2", "b3"})
+}
+
+func TestWatermarkDefaults(t *testing.T) {
+	// desc := "Check that without watermarks, or with only a high one, Sets evict just enough"
+	lru, evictions := watermarkLru(0, 0)
+	for i := 0; i < 12; i++ {
+		setTen(lru, i)
+	}
+	if *evictions != 2 || lru.RemainingStorage() != 0 {
+		t.Errorf("Expected 2 evictions leaving nothing, found %d leaving %d", *evictions, lru.RemainingStorage())
+	}
+
+	// Only a high watermark: keep at most 50 bytes, one eviction at a time
+	lru, evictions = watermarkLru(0.5, 0)
+	for i := 0; i < 8; i++ {
+		setTen(lru, i)
+	}
+	if *evictions != 3 || lru.RemainingStorage() != 50 {
+		t.Errorf("Expected 3 evictions leaving 50 bytes, found %d leaving %d", *evictions, lru.RemainingStorage())
+	}
+
+	// A low watermark above the high one is the high one
+	lru, evictions = watermarkLru(0.5, 0.8)
+	for i := 0; i < 8; i++ {
+		setTen(lru, i)
+	}
+	if *evictions != 3 || lru.RemainingStorage() != 50 {
+		t.Errorf("Expected 3 evictions leaving 50 bytes, found %d leaving %d", *evictions, lru.RemainingStorage())
+	}
+}
+
+func TestWatermarkLargeBinding(t *testing.T) {
+	// desc := "Check that a binding above the low watermark is stored, and evicts everything else"
+	lru, evictions := watermarkLru(0.9, 0.5)
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "k1", b("v1"), true),
+		NewOp(Set, "k2", b("v2"), true),
+		NewOp(Set, "toolarge", make([]byte, 93), false),
+		NewOp(Set, "large", make([]byte, 85), true), // evicts k1 and k2
+		NewOp(Set, "k3", b("v3"), true),             // evicts large
+		NewOp(Get, "large", &Record{nil, false}),
+		NewOp(Len, 1),
+	})
+	if *evictions != 3 {
+		t.Errorf(operationFailMessage, "Stats().Evictions", &Args{}, Expected{3}, Expected{*evictions})
+	}
+
+	ExecuteOperations(t, lru, []Operation{
+		NewOp(Set, "large", make([]byte, 90), true), // evicts k3
+		NewOp(Get, "k3", &Record{nil, false}),
+		NewOp(Remaining, 5),
+	})
+}
+
+func TestWatermarkPinned(t *testing.T) {
+	// desc := "Check that batch eviction skips pinned bindings"
+	lru, _ := watermarkLru(0.9, 0.3)
+	for i := 0; i < 9; i++ {
+		setTen(lru, i)
+	}
+	lru.Pin("a0")
+	lru.Pin("a1")
+	setTen(lru, 9)
+	CheckKeys(t, lru, []string{"a0", "a1", "a9"})
+}
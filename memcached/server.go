@@ -0,0 +1,357 @@
+// Package memcached serves a cache over TCP with the memcached text protocol,
+// so that caches can be benchmarked with standard memcached tools such as
+// memtier_benchmark:
+//
+//	memtier_benchmark -p 11211 -P memcache_text --ratio 1:9
+//
+// Only the commands those tools need are supported: get, set, delete, stats,
+// version and quit. Flags and expiry times are accepted but not stored, so
+// every value is returned with flags 0 and never expires. No cas uniques are
+// kept either, so gets and cas are unknown commands.
+package memcached
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// Cache is the subset of lru.ByteCache that the server uses
+type Cache interface {
+	MaxStorage() int
+	RemainingStorage() int
+	Get(key string) ([]byte, bool)
+	Remove(key string) ([]byte, bool)
+	Set(key string, value []byte) bool
+	Len() int
+}
+
+// Version is reported by the version command
+const Version = "lrumemcached 1.0"
+
+// memcached refuses keys longer than 250 bytes, and so does the server
+const maxKeyLength = 250
+
+// maxLine bounds the length of a command line, so that a client cannot make
+// the server buffer without limit
+const maxLine = 4096
+
+var errTooLong = errors.New("line too long")
+
+// Stats counts the commands a Server has handled
+type Stats struct {
+	CmdGet, CmdSet         int
+	GetHits, GetMisses     int
+	DeleteHits, DeleteMiss int
+	CurrConns, TotalConns  int
+}
+
+// Server serves Cache to memcached clients
+type Server struct {
+	Cache Cache
+
+	// Concurrent says that Cache is safe for concurrent use, so commands from
+	// different connections may reach it at once rather than taking turns
+	Concurrent bool
+
+	cacheMu sync.Mutex // serializes requests to Cache unless Concurrent
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// ListenAndServe listens on the TCP address addr and serves connections on it
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l, serving each on its own goroutine, until
+// accepting fails, as it does once l is closed
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			s.ServeConn(conn)
+		}()
+	}
+}
+
+// ServeConn serves requests read from conn until the client quits or conn
+// fails, returning the error that ended the connection, or nil if the client
+// quit or closed it
+func (s *Server) ServeConn(conn io.ReadWriter) error {
+	s.count(func(st *Stats) { st.CurrConns++; st.TotalConns++ })
+	defer s.count(func(st *Stats) { st.CurrConns-- })
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := readLine(r)
+		if err == errTooLong {
+			fmt.Fprint(w, "CLIENT_ERROR line too long\r\n")
+			w.Flush()
+			return err
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		quit, err := s.handle(line, r, w)
+		if err != nil {
+			return err
+		}
+		// Flush only once the client has no more pipelined requests waiting
+		if r.Buffered() == 0 || quit {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// readLine reads a line ending in "\r\n" (or just "\n") from r, without the
+// line ending
+func readLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > maxLine {
+			return nil, errTooLong
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		line = bytes.TrimSuffix(line[:len(line)-1], []byte("\r"))
+		return line, nil
+	}
+}
+
+// handle carries out one command, writing its response to w. It returns
+// whether the client asked to quit, and any error reading the command's data
+// from r.
+func (s *Server) handle(line []byte, r *bufio.Reader, w *bufio.Writer) (bool, error) {
+	fields := bytes.Fields(line)
+	if len(fields) == 0 {
+		fmt.Fprint(w, "ERROR\r\n")
+		return false, nil
+	}
+	args := fields[1:]
+
+	switch string(fields[0]) {
+	case "get":
+		s.get(args, w)
+	case "set":
+		return false, s.set(args, r, w)
+	case "delete":
+		s.delete(args, w)
+	case "stats":
+		s.writeStats(w)
+	case "version":
+		fmt.Fprintf(w, "VERSION %s\r\n", Version)
+	case "quit":
+		return true, nil
+	default:
+		fmt.Fprint(w, "ERROR\r\n")
+	}
+	return false, nil
+}
+
+func (s *Server) get(keys [][]byte, w *bufio.Writer) {
+	if len(keys) == 0 {
+		fmt.Fprint(w, "ERROR\r\n")
+		return
+	}
+	for _, key := range keys {
+		if len(key) > maxKeyLength {
+			fmt.Fprint(w, "CLIENT_ERROR bad command line format\r\n")
+			return
+		}
+	}
+
+	for _, key := range keys {
+		var val []byte
+		var ok bool
+		s.withCache(func(c Cache) { val, ok = c.Get(string(key)) })
+		s.count(func(st *Stats) {
+			st.CmdGet++
+			if ok {
+				st.GetHits++
+			} else {
+				st.GetMisses++
+			}
+		})
+		if ok {
+			fmt.Fprintf(w, "VALUE %s 0 %d\r\n", key, len(val))
+			w.Write(val)
+			w.WriteString("\r\n")
+		}
+	}
+	w.WriteString("END\r\n")
+}
+
+// set handles "set <key> <flags> <exptime> <bytes> [noreply]", followed by a
+// data block of the given number of bytes and "\r\n"
+func (s *Server) set(args [][]byte, r *bufio.Reader, w *bufio.Writer) error {
+	noreply := len(args) == 5 && string(args[4]) == "noreply"
+	if len(args) != 4 && !noreply {
+		fmt.Fprint(w, "ERROR\r\n")
+		return nil
+	}
+	_, flagsErr := strconv.ParseUint(string(args[1]), 10, 32)
+	_, expErr := strconv.ParseInt(string(args[2]), 10, 64)
+	n, lenErr := strconv.ParseInt(string(args[3]), 10, 64)
+	if len(args[0]) > maxKeyLength || flagsErr != nil || expErr != nil || lenErr != nil || n < 0 {
+		fmt.Fprint(w, "CLIENT_ERROR bad command line format\r\n")
+		return nil
+	}
+
+	// Refuse a value that could never fit before reading it, so that a client
+	// cannot make the server allocate however much it claims to send, then
+	// skip its data block
+	var limit int
+	s.withCache(func(c Cache) { limit = c.MaxStorage() })
+	if n > int64(limit) {
+		s.count(func(st *Stats) { st.CmdSet++ })
+		if !noreply {
+			fmt.Fprint(w, "SERVER_ERROR object too large for cache\r\n")
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+		if _, err := io.CopyN(io.Discard, r, n); err != nil {
+			return err
+		}
+		_, err := r.Discard(2)
+		return err
+	}
+
+	data := make([]byte, n+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+	if !bytes.HasSuffix(data, []byte("\r\n")) {
+		fmt.Fprint(w, "CLIENT_ERROR bad data chunk\r\n")
+		return nil
+	}
+
+	var ok bool
+	s.withCache(func(c Cache) { ok = c.Set(string(args[0]), data[:n]) })
+	s.count(func(st *Stats) { st.CmdSet++ })
+	switch {
+	case noreply:
+	case ok:
+		fmt.Fprint(w, "STORED\r\n")
+	default:
+		fmt.Fprint(w, "SERVER_ERROR object too large for cache\r\n")
+	}
+	return nil
+}
+
+// delete handles "delete <key> [noreply]"
+func (s *Server) delete(args [][]byte, w *bufio.Writer) {
+	noreply := len(args) == 2 && string(args[1]) == "noreply"
+	if len(args) != 1 && !noreply {
+		fmt.Fprint(w, "ERROR\r\n")
+		return
+	}
+	if len(args[0]) > maxKeyLength {
+		fmt.Fprint(w, "CLIENT_ERROR bad command line format\r\n")
+		return
+	}
+
+	var ok bool
+	s.withCache(func(c Cache) { _, ok = c.Remove(string(args[0])) })
+	s.count(func(st *Stats) {
+		if ok {
+			st.DeleteHits++
+		} else {
+			st.DeleteMiss++
+		}
+	})
+	switch {
+	case noreply:
+	case ok:
+		fmt.Fprint(w, "DELETED\r\n")
+	default:
+		fmt.Fprint(w, "NOT_FOUND\r\n")
+	}
+}
+
+func (s *Server) writeStats(w *bufio.Writer) {
+	var items, used, limit int
+	s.withCache(func(c Cache) {
+		items, limit = c.Len(), c.MaxStorage()
+		used = limit - c.RemainingStorage()
+	})
+	st := s.Stats()
+
+	for _, stat := range []struct {
+		name  string
+		value int
+	}{
+		{"curr_connections", st.CurrConns},
+		{"total_connections", st.TotalConns},
+		{"cmd_get", st.CmdGet},
+		{"cmd_set", st.CmdSet},
+		{"get_hits", st.GetHits},
+		{"get_misses", st.GetMisses},
+		{"delete_hits", st.DeleteHits},
+		{"delete_misses", st.DeleteMiss},
+		{"curr_items", items},
+		{"bytes", used},
+		{"limit_maxbytes", limit},
+	} {
+		fmt.Fprintf(w, "STAT %s %d\r\n", stat.name, stat.value)
+	}
+	w.WriteString("END\r\n")
+}
+
+// Stats returns the counts of the commands the server has handled
+func (s *Server) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+func (s *Server) count(f func(*Stats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f(&s.stats)
+}
+
+// withCache calls f with the cache, one command at a time across every
+// connection unless Concurrent
+func (s *Server) withCache(f func(Cache)) {
+	if !s.Concurrent {
+		s.cacheMu.Lock()
+		defer s.cacheMu.Unlock()
+	}
+	f(s.Cache)
+}
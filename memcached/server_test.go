@@ -0,0 +1,191 @@
+package memcached
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cos316gradertest/assignment3-test/internal/cachetest"
+)
+
+// converse sends request to server, asks it to quit, and checks that it
+// responded with exactly expected
+func converse(t *testing.T, server *Server, request, expected string) {
+	t.Helper()
+	client, conn := net.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- server.ServeConn(conn)
+		conn.Close()
+	}()
+
+	go func() {
+		io.WriteString(client, request)
+		io.WriteString(client, "quit\r\n")
+	}()
+	response, _ := io.ReadAll(client)
+	client.Close()
+	if err := <-done; err != nil {
+		t.Errorf("ServeConn: %v", err)
+	}
+	if string(response) != expected {
+		t.Errorf("Request %q: expected response %q, found %q", request, expected, response)
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	server := &Server{Cache: cachetest.NewMapCache(64)}
+	converse(t, server,
+		"get k1\r\n"+
+			"set k1 0 0 2\r\nv1\r\n"+
+			"set k2 5 3600 0 noreply\r\n\r\n"+
+			"get k1 k9 k2\r\n"+
+			"gets k1\r\n", // there are no cas uniques to send
+		"END\r\n"+
+			"STORED\r\n"+
+			"VALUE k1 0 2\r\nv1\r\nVALUE k2 0 0\r\n\r\nEND\r\n"+
+			"ERROR\r\n")
+
+	// Values may hold any bytes, including line endings
+	converse(t, server,
+		"set k3 0 0 6\r\na\r\nb\r\n\r\nget k3\r\n",
+		"STORED\r\nVALUE k3 0 6\r\na\r\nb\r\n\r\nEND\r\n")
+}
+
+func TestSetTooLarge(t *testing.T) {
+	server := &Server{Cache: cachetest.NewMapCache(8)}
+	converse(t, server,
+		"set k1 0 0 7\r\n1234567\r\nget k1\r\n",
+		"SERVER_ERROR object too large for cache\r\nEND\r\n")
+
+	// Values larger than the cache are skipped without being read into memory
+	converse(t, server,
+		"set k1 0 0 9\r\n123456789\r\nset k2 0 0 9 noreply\r\n123456789\r\nget k1 k2\r\n",
+		"SERVER_ERROR object too large for cache\r\nEND\r\n")
+}
+
+func TestSetHugeLength(t *testing.T) {
+	server := &Server{Cache: cachetest.NewMapCache(64)}
+	client, conn := net.Pipe()
+	done := make(chan error, 1)
+	go func() { done <- server.ServeConn(conn) }()
+
+	io.WriteString(client, "set k1 0 0 9223372036854775807\r\n")
+	response, err := bufio.NewReader(client).ReadString('\n')
+	if err != nil || response != "SERVER_ERROR object too large for cache\r\n" {
+		t.Errorf("Expected the set to be refused, found %q, %v", response, err)
+	}
+	// The server is left skipping the data block, until the client gives up
+	client.Close()
+	if err := <-done; err == nil {
+		t.Errorf("Expected ServeConn to report the truncated data block")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	server := &Server{Cache: cachetest.NewMapCache(64)}
+	converse(t, server,
+		"set k1 0 0 2\r\nv1\r\nset k2 0 0 2\r\nv2\r\n"+
+			"delete k1\r\ndelete k1\r\ndelete k2 noreply\r\nget k1 k2\r\n",
+		"STORED\r\nSTORED\r\nDELETED\r\nNOT_FOUND\r\nEND\r\n")
+}
+
+func TestErrors(t *testing.T) {
+	server := &Server{Cache: cachetest.NewMapCache(64)}
+	long := strings.Repeat("k", maxKeyLength+1)
+	converse(t, server,
+		"\r\n"+
+			"flush_all\r\n"+
+			"get\r\n"+
+			"get "+long+"\r\n"+
+			"set k1 0 0\r\n"+
+			"set k1 x 0 2\r\n"+
+			"set k1 0 0 -1\r\n"+
+			"set k1 0 0 2\r\nv12\r\n"+ // the data chunk runs over
+			"delete\r\n"+
+			"version\r\n",
+		"ERROR\r\n"+
+			"ERROR\r\n"+
+			"ERROR\r\n"+
+			"CLIENT_ERROR bad command line format\r\n"+
+			"ERROR\r\n"+
+			"CLIENT_ERROR bad command line format\r\n"+
+			"CLIENT_ERROR bad command line format\r\n"+
+			"CLIENT_ERROR bad data chunk\r\n"+
+			"ERROR\r\n"+ // the rest of the chunk, "\n", is an empty line
+			"ERROR\r\n"+
+			"VERSION "+Version+"\r\n")
+}
+
+func TestStats(t *testing.T) {
+	server := &Server{Cache: cachetest.NewMapCache(64)}
+	converse(t, server,
+		"set k1 0 0 2\r\nv1\r\nget k1 k2\r\ndelete k2\r\nstats\r\n",
+		"STORED\r\nVALUE k1 0 2\r\nv1\r\nEND\r\nNOT_FOUND\r\n"+
+			"STAT curr_connections 1\r\n"+
+			"STAT total_connections 1\r\n"+
+			"STAT cmd_get 2\r\n"+
+			"STAT cmd_set 1\r\n"+
+			"STAT get_hits 1\r\n"+
+			"STAT get_misses 1\r\n"+
+			"STAT delete_hits 0\r\n"+
+			"STAT delete_misses 1\r\n"+
+			"STAT curr_items 1\r\n"+
+			"STAT bytes 4\r\n"+
+			"STAT limit_maxbytes 64\r\n"+
+			"END\r\n")
+
+	st := server.Stats()
+	if st.CurrConns != 0 || st.TotalConns != 1 {
+		t.Errorf("Expected 0 current and 1 total connections, found %d and %d",
+			st.CurrConns, st.TotalConns)
+	}
+}
+
+func TestServeConcurrentClients(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen: %v", err)
+	}
+	server := &Server{Cache: cachetest.NewMapCache(1 << 20)}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(l) }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := net.Dial("tcp", l.Addr().String())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			for j := 0; j < 50; j++ {
+				key := fmt.Sprintf("k%d-%d", i, j)
+				fmt.Fprintf(conn, "set %s 0 0 %d\r\n%s\r\nget %s\r\n", key, len(key), key, key)
+				expected := fmt.Sprintf("STORED\r\nVALUE %s 0 %d\r\n%s\r\nEND\r\n", key, len(key), key)
+				response := make([]byte, len(expected))
+				if _, err := io.ReadFull(r, response); err != nil || string(response) != expected {
+					t.Errorf("Expected response %q, found %q (%v)", expected, response, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	l.Close()
+	if err := <-served; err == nil {
+		t.Errorf("Expected Serve to fail once the listener is closed")
+	}
+	if st := server.Stats(); st.CmdSet != 8*50 || st.GetHits != 8*50 {
+		t.Errorf("Expected %d sets and hits, found %d and %d", 8*50, st.CmdSet, st.GetHits)
+	}
+}
@@ -0,0 +1,451 @@
+// Package rpccache serves a cache with net/rpc, usually over a Unix socket, so
+// that it can run in a process of its own:
+//
+//	go (&rpccache.Server{Cache: lru.NewLru(1 << 20)}).ListenAndServe("/tmp/lru.sock")
+//
+//	client, err := rpccache.Dial("/tmp/lru.sock")
+//	client.Set("k1", []byte("v1"))
+//
+// Client has the same methods as Cache, so it can stand in for the cache it
+// calls. Because those methods cannot return an error, a Client that fails to
+// reach its server returns zero values and reports the failure from Err.
+// Errors the cache itself returns, as from Incr, come back as rpc.ServerError
+// and so lose their identity: compare them with err.Error().
+package rpccache
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/rpc"
+	"sync"
+	"time"
+)
+
+// Cache is the subset of *lru.LRU's methods that can be called remotely:
+// every one whose arguments and results can be sent over a connection
+type Cache interface {
+	MaxStorage() int
+	RemainingStorage() int
+	Get(key string) ([]byte, bool)
+	Peek(key string) ([]byte, bool)
+	GetMulti(keys []string) ([][]byte, []bool)
+	Set(key string, value []byte) bool
+	SetE(key string, value []byte) error
+	SetWithTTL(key string, value []byte, ttl time.Duration) bool
+	Add(key string, value []byte) bool
+	CAS(key string, old, new []byte) bool
+	Append(key string, suffix []byte) bool
+	Prepend(key string, prefix []byte) bool
+	Incr(key string, delta int64) (int64, error)
+	Remove(key string) ([]byte, bool)
+	Contains(key string) bool
+	Touch(key string) bool
+	Pin(key string) bool
+	Unpin(key string) bool
+	Keys() []string
+	Oldest() (string, bool)
+	Newest() (string, bool)
+	Len() int
+	Purge()
+	Resize(newMax int) int
+}
+
+// serviceName is the name the cache is registered under with net/rpc
+const serviceName = "Cache"
+
+// Server serves Cache to Clients
+type Server struct {
+	Cache Cache
+
+	// Concurrent says that Cache is safe for concurrent use, so calls from
+	// different Clients, or from one Client's goroutines, may reach it at once
+	Concurrent bool
+
+	once sync.Once
+	rpc  *rpc.Server
+}
+
+// ListenAndServe listens on the Unix socket path and serves connections on it
+func (s *Server) ListenAndServe(path string) error {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l, serving each on its own goroutine, until
+// accepting fails, as it does once l is closed
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn serves requests read from conn until the client hangs up, then
+// closes conn
+func (s *Server) ServeConn(conn io.ReadWriteCloser) {
+	s.once.Do(func() {
+		s.rpc = rpc.NewServer()
+		if err := s.rpc.RegisterName(serviceName, &Service{server: s}); err != nil {
+			panic(err)
+		}
+	})
+	s.rpc.ServeConn(conn)
+}
+
+// The arguments and replies of Service's methods
+
+type KeyArgs struct{ Key string }
+
+type KeysArgs struct{ Keys []string }
+
+type SetArgs struct {
+	Key   string
+	Value []byte
+	TTL   time.Duration
+}
+
+type CASArgs struct {
+	Key      string
+	Old, New []byte
+}
+
+type IncrArgs struct {
+	Key   string
+	Delta int64
+}
+
+// Empty stands in for no arguments or no reply, since gob cannot send an empty
+// struct
+type Empty bool
+
+type ValueReply struct {
+	Value []byte
+	OK    bool
+}
+
+type MultiReply struct {
+	Values [][]byte
+	OK     []bool
+}
+
+type KeyReply struct {
+	Key string
+	OK  bool
+}
+
+// Service is what a Server registers with net/rpc. Its methods are called by
+// Clients, not directly.
+type Service struct {
+	server *Server
+	mu     sync.Mutex // serializes requests to the cache unless Concurrent
+}
+
+// do calls f with the cache, holding mu for the call unless the Server is
+// Concurrent. It returns nil, so that methods can return its result.
+func (s *Service) do(f func(Cache)) error {
+	if !s.server.Concurrent {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+	f(s.server.Cache)
+	return nil
+}
+
+func (s *Service) MaxStorage(_ Empty, reply *int) error {
+	return s.do(func(c Cache) { *reply = c.MaxStorage() })
+}
+
+func (s *Service) RemainingStorage(_ Empty, reply *int) error {
+	return s.do(func(c Cache) { *reply = c.RemainingStorage() })
+}
+
+func (s *Service) Get(args KeyArgs, reply *ValueReply) error {
+	return s.do(func(c Cache) { reply.Value, reply.OK = c.Get(args.Key) })
+}
+
+func (s *Service) Peek(args KeyArgs, reply *ValueReply) error {
+	return s.do(func(c Cache) { reply.Value, reply.OK = c.Peek(args.Key) })
+}
+
+func (s *Service) GetMulti(args KeysArgs, reply *MultiReply) error {
+	return s.do(func(c Cache) { reply.Values, reply.OK = c.GetMulti(args.Keys) })
+}
+
+func (s *Service) Set(args SetArgs, reply *bool) error {
+	return s.do(func(c Cache) { *reply = c.Set(args.Key, args.Value) })
+}
+
+func (s *Service) SetE(args SetArgs, _ *Empty) error {
+	var err error
+	s.do(func(c Cache) { err = c.SetE(args.Key, args.Value) })
+	return err
+}
+
+func (s *Service) SetWithTTL(args SetArgs, reply *bool) error {
+	return s.do(func(c Cache) { *reply = c.SetWithTTL(args.Key, args.Value, args.TTL) })
+}
+
+func (s *Service) Add(args SetArgs, reply *bool) error {
+	return s.do(func(c Cache) { *reply = c.Add(args.Key, args.Value) })
+}
+
+func (s *Service) CAS(args CASArgs, reply *bool) error {
+	return s.do(func(c Cache) { *reply = c.CAS(args.Key, args.Old, args.New) })
+}
+
+func (s *Service) Append(args SetArgs, reply *bool) error {
+	return s.do(func(c Cache) { *reply = c.Append(args.Key, args.Value) })
+}
+
+func (s *Service) Prepend(args SetArgs, reply *bool) error {
+	return s.do(func(c Cache) { *reply = c.Prepend(args.Key, args.Value) })
+}
+
+func (s *Service) Incr(args IncrArgs, reply *int64) error {
+	var err error
+	s.do(func(c Cache) { *reply, err = c.Incr(args.Key, args.Delta) })
+	return err
+}
+
+func (s *Service) Remove(args KeyArgs, reply *ValueReply) error {
+	return s.do(func(c Cache) { reply.Value, reply.OK = c.Remove(args.Key) })
+}
+
+func (s *Service) Contains(args KeyArgs, reply *bool) error {
+	return s.do(func(c Cache) { *reply = c.Contains(args.Key) })
+}
+
+func (s *Service) Touch(args KeyArgs, reply *bool) error {
+	return s.do(func(c Cache) { *reply = c.Touch(args.Key) })
+}
+
+func (s *Service) Pin(args KeyArgs, reply *bool) error {
+	return s.do(func(c Cache) { *reply = c.Pin(args.Key) })
+}
+
+func (s *Service) Unpin(args KeyArgs, reply *bool) error {
+	return s.do(func(c Cache) { *reply = c.Unpin(args.Key) })
+}
+
+func (s *Service) Keys(_ Empty, reply *[]string) error {
+	return s.do(func(c Cache) { *reply = c.Keys() })
+}
+
+func (s *Service) Oldest(_ Empty, reply *KeyReply) error {
+	return s.do(func(c Cache) { reply.Key, reply.OK = c.Oldest() })
+}
+
+func (s *Service) Newest(_ Empty, reply *KeyReply) error {
+	return s.do(func(c Cache) { reply.Key, reply.OK = c.Newest() })
+}
+
+func (s *Service) Len(_ Empty, reply *int) error {
+	return s.do(func(c Cache) { *reply = c.Len() })
+}
+
+func (s *Service) Purge(_ Empty, _ *Empty) error {
+	return s.do(func(c Cache) { c.Purge() })
+}
+
+func (s *Service) Resize(newMax int, reply *int) error {
+	return s.do(func(c Cache) { *reply = c.Resize(newMax) })
+}
+
+// Client calls a Cache served by a Server
+type Client struct {
+	rpc *rpc.Client
+
+	mu  sync.Mutex
+	err error
+}
+
+// Dial connects to the Server listening on the Unix socket path
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient returns a Client that calls the Server on the other end of conn
+func NewClient(conn io.ReadWriteCloser) *Client {
+	return &Client{rpc: rpc.NewClient(conn)}
+}
+
+// Close hangs up on the server
+func (c *Client) Close() error {
+	return c.rpc.Close()
+}
+
+// Err returns the first error that kept the client from reaching its server,
+// or nil if there has been none
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// call calls method on the server. If the server could not be reached, it
+// records the error for Err and returns it; errors the cache returned are
+// only returned.
+func (c *Client) call(method string, args, reply interface{}) error {
+	err := c.rpc.Call(serviceName+"."+method, args, reply)
+	var serverErr rpc.ServerError
+	if err != nil && !errors.As(err, &serverErr) {
+		c.mu.Lock()
+		if c.err == nil {
+			c.err = err
+		}
+		c.mu.Unlock()
+	}
+	return err
+}
+
+func (c *Client) MaxStorage() int {
+	var reply int
+	c.call("MaxStorage", new(Empty), &reply)
+	return reply
+}
+
+func (c *Client) RemainingStorage() int {
+	var reply int
+	c.call("RemainingStorage", new(Empty), &reply)
+	return reply
+}
+
+func (c *Client) Get(key string) ([]byte, bool) {
+	var reply ValueReply
+	c.call("Get", KeyArgs{key}, &reply)
+	return reply.Value, reply.OK
+}
+
+func (c *Client) Peek(key string) ([]byte, bool) {
+	var reply ValueReply
+	c.call("Peek", KeyArgs{key}, &reply)
+	return reply.Value, reply.OK
+}
+
+func (c *Client) GetMulti(keys []string) ([][]byte, []bool) {
+	var reply MultiReply
+	c.call("GetMulti", KeysArgs{keys}, &reply)
+	return reply.Values, reply.OK
+}
+
+func (c *Client) Set(key string, value []byte) bool {
+	var reply bool
+	c.call("Set", SetArgs{Key: key, Value: value}, &reply)
+	return reply
+}
+
+func (c *Client) SetE(key string, value []byte) error {
+	return c.call("SetE", SetArgs{Key: key, Value: value}, new(Empty))
+}
+
+func (c *Client) SetWithTTL(key string, value []byte, ttl time.Duration) bool {
+	var reply bool
+	c.call("SetWithTTL", SetArgs{key, value, ttl}, &reply)
+	return reply
+}
+
+func (c *Client) Add(key string, value []byte) bool {
+	var reply bool
+	c.call("Add", SetArgs{Key: key, Value: value}, &reply)
+	return reply
+}
+
+func (c *Client) CAS(key string, old, new []byte) bool {
+	var reply bool
+	c.call("CAS", CASArgs{key, old, new}, &reply)
+	return reply
+}
+
+func (c *Client) Append(key string, suffix []byte) bool {
+	var reply bool
+	c.call("Append", SetArgs{Key: key, Value: suffix}, &reply)
+	return reply
+}
+
+func (c *Client) Prepend(key string, prefix []byte) bool {
+	var reply bool
+	c.call("Prepend", SetArgs{Key: key, Value: prefix}, &reply)
+	return reply
+}
+
+func (c *Client) Incr(key string, delta int64) (int64, error) {
+	var reply int64
+	err := c.call("Incr", IncrArgs{key, delta}, &reply)
+	return reply, err
+}
+
+func (c *Client) Remove(key string) ([]byte, bool) {
+	var reply ValueReply
+	c.call("Remove", KeyArgs{key}, &reply)
+	return reply.Value, reply.OK
+}
+
+func (c *Client) Contains(key string) bool {
+	var reply bool
+	c.call("Contains", KeyArgs{key}, &reply)
+	return reply
+}
+
+func (c *Client) Touch(key string) bool {
+	var reply bool
+	c.call("Touch", KeyArgs{key}, &reply)
+	return reply
+}
+
+func (c *Client) Pin(key string) bool {
+	var reply bool
+	c.call("Pin", KeyArgs{key}, &reply)
+	return reply
+}
+
+func (c *Client) Unpin(key string) bool {
+	var reply bool
+	c.call("Unpin", KeyArgs{key}, &reply)
+	return reply
+}
+
+func (c *Client) Keys() []string {
+	var reply []string
+	c.call("Keys", new(Empty), &reply)
+	return reply
+}
+
+func (c *Client) Oldest() (string, bool) {
+	var reply KeyReply
+	c.call("Oldest", new(Empty), &reply)
+	return reply.Key, reply.OK
+}
+
+func (c *Client) Newest() (string, bool) {
+	var reply KeyReply
+	c.call("Newest", new(Empty), &reply)
+	return reply.Key, reply.OK
+}
+
+func (c *Client) Len() int {
+	var reply int
+	c.call("Len", new(Empty), &reply)
+	return reply
+}
+
+func (c *Client) Purge() {
+	c.call("Purge", new(Empty), new(Empty))
+}
+
+func (c *Client) Resize(newMax int) int {
+	var reply int
+	c.call("Resize", newMax, &reply)
+	return reply
+}
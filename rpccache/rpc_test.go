@@ -0,0 +1,315 @@
+package rpccache
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// listCache is a Cache without eviction that keeps its keys in recency order,
+// least recently used first, charging each binding the lengths of its key and
+// value. It records the TTLs it is given rather than honoring them.
+type listCache struct {
+	limit    int
+	keys     []string
+	bindings map[string][]byte
+	pinned   map[string]bool
+	ttls     map[string]time.Duration
+}
+
+var errNotNumber = errors.New("not a number")
+
+func newListCache(limit int) *listCache {
+	return &listCache{limit, nil, map[string][]byte{}, map[string]bool{}, map[string]time.Duration{}}
+}
+
+func (c *listCache) MaxStorage() int { return c.limit }
+
+func (c *listCache) RemainingStorage() int {
+	remaining := c.limit
+	for key, val := range c.bindings {
+		remaining -= len(key) + len(val)
+	}
+	return remaining
+}
+
+func (c *listCache) unlink(key string) {
+	for i, k := range c.keys {
+		if k == key {
+			c.keys = append(c.keys[:i], c.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *listCache) Get(key string) ([]byte, bool) {
+	if c.Touch(key) {
+		return c.bindings[key], true
+	}
+	return nil, false
+}
+
+func (c *listCache) Peek(key string) ([]byte, bool) {
+	val, ok := c.bindings[key]
+	return val, ok
+}
+
+func (c *listCache) GetMulti(keys []string) ([][]byte, []bool) {
+	values, ok := make([][]byte, len(keys)), make([]bool, len(keys))
+	for i, key := range keys {
+		values[i], ok[i] = c.Get(key)
+	}
+	return values, ok
+}
+
+func (c *listCache) Set(key string, value []byte) bool {
+	return c.SetE(key, value) == nil
+}
+
+func (c *listCache) SetE(key string, value []byte) error {
+	remaining := c.RemainingStorage()
+	if old, ok := c.bindings[key]; ok {
+		remaining += len(key) + len(old)
+	}
+	if len(key)+len(value) > remaining {
+		return errors.New("too large")
+	}
+	c.unlink(key)
+	c.keys = append(c.keys, key)
+	c.bindings[key] = append([]byte(nil), value...)
+	delete(c.ttls, key)
+	return nil
+}
+
+func (c *listCache) SetWithTTL(key string, value []byte, ttl time.Duration) bool {
+	if !c.Set(key, value) {
+		return false
+	}
+	c.ttls[key] = ttl
+	return true
+}
+
+func (c *listCache) Add(key string, value []byte) bool {
+	return !c.Contains(key) && c.Set(key, value)
+}
+
+func (c *listCache) CAS(key string, old, new []byte) bool {
+	val, ok := c.bindings[key]
+	return ok && bytes.Equal(val, old) && c.Set(key, new)
+}
+
+func (c *listCache) Append(key string, suffix []byte) bool {
+	val, ok := c.bindings[key]
+	return ok && c.Set(key, append(append([]byte(nil), val...), suffix...))
+}
+
+func (c *listCache) Prepend(key string, prefix []byte) bool {
+	val, ok := c.bindings[key]
+	return ok && c.Set(key, append(append([]byte(nil), prefix...), val...))
+}
+
+func (c *listCache) Incr(key string, delta int64) (int64, error) {
+	val, ok := c.bindings[key]
+	if !ok {
+		return 0, errors.New("not found")
+	}
+	n, err := strconv.ParseInt(string(val), 10, 64)
+	if err != nil {
+		return 0, errNotNumber
+	}
+	n += delta
+	c.Set(key, []byte(strconv.FormatInt(n, 10)))
+	return n, nil
+}
+
+func (c *listCache) Remove(key string) ([]byte, bool) {
+	val, ok := c.bindings[key]
+	c.unlink(key)
+	delete(c.bindings, key)
+	return val, ok
+}
+
+func (c *listCache) Contains(key string) bool {
+	_, ok := c.bindings[key]
+	return ok
+}
+
+func (c *listCache) Touch(key string) bool {
+	if !c.Contains(key) {
+		return false
+	}
+	c.unlink(key)
+	c.keys = append(c.keys, key)
+	return true
+}
+
+func (c *listCache) Pin(key string) bool {
+	ok := c.Contains(key)
+	if ok {
+		c.pinned[key] = true
+	}
+	return ok
+}
+
+func (c *listCache) Unpin(key string) bool {
+	ok := c.pinned[key]
+	delete(c.pinned, key)
+	return ok
+}
+
+func (c *listCache) Keys() []string {
+	return append([]string(nil), c.keys...)
+}
+
+func (c *listCache) Oldest() (string, bool) {
+	if len(c.keys) == 0 {
+		return "", false
+	}
+	return c.keys[0], true
+}
+
+func (c *listCache) Newest() (string, bool) {
+	if len(c.keys) == 0 {
+		return "", false
+	}
+	return c.keys[len(c.keys)-1], true
+}
+
+func (c *listCache) Len() int { return len(c.keys) }
+
+func (c *listCache) Purge() {
+	c.keys, c.bindings = nil, map[string][]byte{}
+}
+
+func (c *listCache) Resize(newMax int) int {
+	c.limit = newMax
+	return 0
+}
+
+var _ Cache = (*Client)(nil)
+
+// connect serves cache over an in-memory connection and returns a client
+// for it
+func connect(t *testing.T, cache Cache) *Client {
+	client, server := net.Pipe()
+	go (&Server{Cache: cache}).ServeConn(server)
+	c := NewClient(client)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClientCalls(t *testing.T) {
+	cache := newListCache(64)
+	c := connect(t, cache)
+
+	check := func(method string, got, expected interface{}) {
+		t.Helper()
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected %v, found %v", method, expected, got)
+		}
+	}
+
+	check("Set", c.Set("k1", []byte("v1")), true)
+	check("SetE", c.SetE("k2", []byte("v2")), nil)
+	check("SetWithTTL", c.SetWithTTL("k3", []byte("3"), time.Hour), true)
+	check("TTL", cache.ttls["k3"], time.Hour)
+	check("Add", c.Add("k1", []byte("x")), false)
+	check("Len", c.Len(), 3)
+	check("MaxStorage", c.MaxStorage(), 64)
+	check("RemainingStorage", c.RemainingStorage(), 64-len("k1v1k2v2k33"))
+
+	val, ok := c.Get("k1")
+	check("Get", []interface{}{string(val), ok}, []interface{}{"v1", true})
+	val, ok = c.Peek("k9")
+	check("Peek", []interface{}{val, ok}, []interface{}{[]byte(nil), false})
+	values, oks := c.GetMulti([]string{"k2", "k9"})
+	check("GetMulti", []interface{}{values, oks}, []interface{}{[][]byte{[]byte("v2"), nil}, []bool{true, false}})
+	check("Keys", c.Keys(), []string{"k3", "k1", "k2"})
+	oldest, _ := c.Oldest()
+	newest, _ := c.Newest()
+	check("Oldest and Newest", []string{oldest, newest}, []string{"k3", "k2"})
+	check("Touch", c.Touch("k3"), true)
+	check("Contains", c.Contains("k3"), true)
+
+	check("CAS", c.CAS("k1", []byte("v1"), []byte("w1")), true)
+	check("Append", c.Append("k1", []byte("!")), true)
+	check("Prepend", c.Prepend("k1", []byte("<")), true)
+	val, _ = c.Peek("k1")
+	check("Peek", string(val), "<w1!")
+
+	n, err := c.Incr("k3", 39)
+	check("Incr", []interface{}{n, err}, []interface{}{int64(42), nil})
+	check("Pin", c.Pin("k2"), true)
+	check("Unpin", c.Unpin("k2"), true)
+
+	val, ok = c.Remove("k2")
+	check("Remove", []interface{}{string(val), ok}, []interface{}{"v2", true})
+	check("Resize", c.Resize(32), 0)
+	check("MaxStorage", c.MaxStorage(), 32)
+	c.Purge()
+	check("Len after Purge", c.Len(), 0)
+	check("Err", c.Err(), nil)
+}
+
+func TestCacheErrors(t *testing.T) {
+	c := connect(t, newListCache(64))
+	c.Set("k1", []byte("v1"))
+
+	if _, err := c.Incr("k1", 1); err == nil || err.Error() != errNotNumber.Error() {
+		t.Errorf("Incr: expected %v, found %v", errNotNumber, err)
+	}
+	if err := c.SetE("k2", make([]byte, 100)); err == nil {
+		t.Errorf("SetE: expected an error for a value too large for the cache")
+	}
+	// Errors from the cache leave the client working
+	if c.Err() != nil || c.Len() != 1 {
+		t.Errorf("Expected the client to keep working, found Err() = %v", c.Err())
+	}
+}
+
+func TestServerGone(t *testing.T) {
+	client, server := net.Pipe()
+	go (&Server{Cache: newListCache(64)}).ServeConn(server)
+	c := NewClient(client)
+	c.Set("k1", []byte("v1"))
+	server.Close()
+
+	if val, ok := c.Get("k1"); val != nil || ok {
+		t.Errorf("Get: expected a miss once the server is gone, found %q, %v", val, ok)
+	}
+	if c.Err() == nil {
+		t.Errorf("Expected Err to report that the server is gone")
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lru.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("cannot listen on a Unix socket: %v", err)
+	}
+	defer l.Close()
+	go (&Server{Cache: newListCache(64)}).Serve(l)
+
+	// Clients share the cache
+	first, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	second, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+
+	first.Set("k1", []byte("v1"))
+	if val, ok := second.Get("k1"); !ok || string(val) != "v1" {
+		t.Errorf("Get from a second client: expected v1, found %q, %v", val, ok)
+	}
+}